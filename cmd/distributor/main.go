@@ -11,7 +11,9 @@ import (
 	"dearcode.net/crab/log"
 
 	"dearcode.net/doodle/pkg/distributor"
+	"dearcode.net/doodle/pkg/distributor/config"
 	"dearcode.net/doodle/pkg/util"
+	"dearcode.net/doodle/pkg/util/reload"
 )
 
 var (
@@ -47,6 +49,12 @@ func main() {
 
 	log.Infof("listener %s", ln.Addr())
 
+	go reload.Watch(config.Path(), time.Duration(config.Distributor.Reload.Watch)*time.Second, func() {
+		if _, err := distributor.Reload(); err != nil {
+			log.Errorf("reload config error:%v", err)
+		}
+	})
+
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGUSR1)
 
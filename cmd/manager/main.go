@@ -13,6 +13,7 @@ import (
 	"dearcode.net/doodle/pkg/manager"
 	"dearcode.net/doodle/pkg/manager/config"
 	"dearcode.net/doodle/pkg/util"
+	"dearcode.net/doodle/pkg/util/reload"
 )
 
 var (
@@ -47,6 +48,12 @@ func main() {
 
 	log.Infof("listener %s", ln.Addr())
 
+	go reload.Watch(config.Path(), time.Duration(config.Manager.Reload.Watch)*time.Second, func() {
+		if _, err := manager.Reload(); err != nil {
+			log.Errorf("reload config error:%v", err)
+		}
+	})
+
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGUSR1)
 
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"flag"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,10 +10,13 @@ import (
 	"time"
 
 	"dearcode.net/crab/log"
+	"github.com/juju/errors"
 
 	"dearcode.net/doodle/pkg/repeater"
 	"dearcode.net/doodle/pkg/repeater/config"
 	"dearcode.net/doodle/pkg/util"
+	"dearcode.net/doodle/pkg/util/graceful"
+	"dearcode.net/doodle/pkg/util/reload"
 )
 
 var (
@@ -37,7 +39,7 @@ func main() {
 		log.SetRolling(true)
 	}
 
-	ln, err := net.Listen("tcp", *addr)
+	ln, err := graceful.Listen("http", *addr)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -46,25 +48,119 @@ func main() {
 		panic(err.Error())
 	}
 
-	as := http.Server{Handler: repeater.Server}
+	var handler http.Handler = repeater.Server
+	var ts *http.Server
+
+	if a := config.Repeater.TLS.Addr; a != "none" {
+		tc, err := repeater.TLSConfig()
+		if err != nil {
+			panic(err.Error())
+		}
+
+		tln, err := graceful.Listen("https", a)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		ts = repeater.NewServer(repeater.Server)
+		ts.TLSConfig = tc
+
+		go func() {
+			if err := ts.ServeTLS(repeater.LimitListener(tln, false), "", ""); err != nil {
+				log.Error(err)
+			}
+		}()
+
+		log.Infof("tls listen addr:%v", tln.Addr().String())
+
+		if config.Repeater.TLS.Redirect {
+			handler = repeater.RedirectHTTPS()
+		}
+	}
+
+	if config.Repeater.GRPC.H2C {
+		handler = repeater.H2C(handler)
+	}
+
+	as := repeater.NewServer(handler)
 
 	go func() {
-		if err = as.Serve(ln); err != nil {
+		if err = as.Serve(repeater.LimitListener(ln, true)); err != nil {
 			log.Error(err)
 		}
 	}()
 
 	log.Infof("listen addr:%v", ln.Addr().String())
 
+	var ads *http.Server
+
+	if a := config.Repeater.Admin.Addr; a != "none" {
+		aln, err := graceful.Listen("admin", a)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		ads = &http.Server{Handler: repeater.Admin}
+
+		go func() {
+			log.Infof("admin listen addr:%v", aln.Addr().String())
+			if err := ads.Serve(aln); err != nil {
+				log.Errorf("admin serve %s error:%v", a, err)
+			}
+		}()
+	}
+
+	if err = graceful.Ready(); err != nil {
+		log.Errorf("notify parent error:%s", errors.ErrorStack(err))
+	}
+
+	go reload.Watch(config.Path(), time.Duration(config.Repeater.Reload.Watch)*time.Second, func() {
+		if _, err := repeater.Reload(); err != nil {
+			log.Errorf("reload config error:%v", err)
+		}
+	})
+
 	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, syscall.SIGUSR1)
+	signal.Notify(shutdown, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	for s := range shutdown {
+		if s == syscall.SIGUSR1 {
+			log.Warningf("recv signal %v, close.", s)
+			break
+		}
 
-	s := <-shutdown
-	log.Warningf("recv signal %v, close.", s)
+		//新进程准备好后会发SIGUSR1过来, 那时再退出
+		pid, err := graceful.Restart()
+		if err != nil {
+			log.Errorf("restart error:%s", errors.ErrorStack(err))
+			continue
+		}
+		log.Warningf("recv signal %v, new process:%d started, wait for it ready", s, pid)
+	}
 
-	as.Shutdown(context.Background())
-	repeater.Stop()
+	drain([]*http.Server{as, ts, ads})
 
-	time.Sleep(time.Duration(config.Repeater.Cache.Timeout) * time.Second)
 	log.Warningf("server exit")
 }
+
+// drain 停止接收新连接, 在超时前等待正在处理的请求, 最后同步写入统计并关闭etcd.
+func drain(ss []*http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.Repeater.Shutdown.Timeout)*time.Second)
+	defer cancel()
+
+	for _, s := range ss {
+		if s == nil {
+			continue
+		}
+		if err := s.Shutdown(ctx); err != nil {
+			log.Errorf("shutdown error:%v, close connections", err)
+			s.Close()
+		}
+	}
+
+	if err := repeater.Drain(ctx); err != nil {
+		log.Errorf("drain error:%v", errors.ErrorStack(err))
+	}
+
+	repeater.Stop()
+}
@@ -4,18 +4,25 @@ import (
 	"encoding/binary"
 	"flag"
 	"fmt"
+	"strings"
+	"time"
 
 	"dearcode.net/crab/util/aes"
+
+	"dearcode.net/doodle/pkg/util/token"
 )
 
 var (
-	token = flag.String("token", "", "decode token.")
-	appID = flag.Int64("app_id", 0, "generate rbac app key.")
-	key   = flag.String("key", "", "secret key.")
+	tk     = flag.String("token", "", "decode token.")
+	appID  = flag.Int64("app_id", 0, "generate rbac app key.")
+	key    = flag.String("key", "", "secret key.")
+	kid    = flag.String("kid", "", "key id, generate v2 token when set.")
+	expire = flag.Int64("expire", 86400*30, "v2 token expire seconds.")
+	scopes = flag.String("scopes", "", "v2 token scopes, separated by comma.")
 )
 
 func parseToken() (int64, error) {
-	buf, err := aes.Decrypt(*token, *key)
+	buf, err := aes.Decrypt(*tk, *key)
 	if err != nil {
 		return 0, err
 	}
@@ -28,17 +35,60 @@ func parseToken() (int64, error) {
 	return id, nil
 }
 
+// parseTokenV2 解析新格式token, 指定了key时同时验证签名.
+func parseTokenV2() (*token.Claims, error) {
+	if *key == "" {
+		_, c, err := token.Parse(*tk)
+		return &c, err
+	}
+
+	return token.Verify(*tk, time.Now(), func(string) (string, error) {
+		return *key, nil
+	})
+}
+
+func signTokenV2() (string, error) {
+	now := time.Now()
+	c := token.Claims{
+		AppID:     *appID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Unix() + *expire,
+		ID:        token.NewID(),
+	}
+
+	for _, s := range strings.Split(*scopes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			c.Scopes = append(c.Scopes, s)
+		}
+	}
+
+	return token.Sign(*kid, *key, c)
+}
+
 func main() {
 	flag.Parse()
 
 	switch {
-	case *token != "":
+	case *tk != "" && token.IsV2(*tk):
+		c, err := parseTokenV2()
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("app:%v, jti:%v, iat:%v, exp:%v, scopes:%v\n", c.AppID, c.ID, time.Unix(c.IssuedAt, 0), time.Unix(c.ExpiresAt, 0), c.Scopes)
+	case *tk != "":
 		id, err := parseToken()
 		if err != nil {
 			panic(err)
 		}
 
 		fmt.Printf("project:%v\n", id)
+	case *appID != 0 && *kid != "":
+		t, err := signTokenV2()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("token:%v\n", t)
 	case *appID != 0:
 		as := make([]byte, 8)
 		binary.PutVarint(as, *appID)
@@ -22,6 +22,10 @@ CREATE TABLE `application` (
   `user` varchar(32) NOT NULL COMMENT '用户名中文，来自erp',
   `email` varchar(64) NOT NULL COMMENT '创建这个应用的用户邮箱，来自erp',
   `token` varchar(64) NOT NULL DEFAULT ' ' COMMENT 'app key',
+  `secret` varchar(64) NOT NULL DEFAULT '' COMMENT '请求签名密钥',
+  `auth_mode` tinyint(4) NOT NULL DEFAULT '0' COMMENT '认证方式:\r\n0:token\r\n1:签名\r\n2:token或签名',
+  `allow_cidr` varchar(1024) NOT NULL DEFAULT '' COMMENT '允许调用的来源网段,逗号分隔,为空不限制',
+  `cert_fingerprint` varchar(64) NOT NULL DEFAULT '' COMMENT '客户端证书sha256指纹,双向TLS时识别应用',
   `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
   `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   `comments` varchar(512) DEFAULT NULL,
@@ -81,11 +85,20 @@ CREATE TABLE `interface` (
   `email` varchar(64) NOT NULL DEFAULT '',
   `state` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT '状态0:未发布，1：发布,2:后端异常',
   `version` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '0:原接口平台转发类接口\r\n1:faas类自注册接口',
-  `method` tinyint(1) unsigned NOT NULL COMMENT '请求方式:0:get, 1:post,2:put,3:delete',
-  `path` varchar(64) NOT NULL COMMENT '接口路径',
+  `method` tinyint(1) unsigned NOT NULL COMMENT '请求方式:0:get, 1:post,2:put,3:delete,4:restful,5:grpc',
+  `path` varchar(255) NOT NULL COMMENT '接口路径,也可以是/orders/{id},/static/*或~开头的正则',
   `backend` varchar(64) NOT NULL COMMENT '实际接口地址',
   `comments` varchar(512) NOT NULL DEFAULT '',
   `level` tinyint(1) NOT NULL DEFAULT '0' COMMENT '0:重要,1:普通',
+  `internal` tinyint(1) NOT NULL DEFAULT '0' COMMENT '1:只允许内网调用',
+  `mock` tinyint(1) NOT NULL DEFAULT '0' COMMENT '1:根据返回值字段生成模拟数据,不调用后端',
+  `mock_latency` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '模拟延迟,单位毫秒',
+  `mock_error_rate` tinyint(3) unsigned NOT NULL DEFAULT '0' COMMENT '模拟出错的百分比',
+  `redact_fields` varchar(512) NOT NULL DEFAULT '' COMMENT '日志中脱敏的字段名或json路径,逗号分隔',
+  `redact_headers` varchar(512) NOT NULL DEFAULT '' COMMENT '日志中脱敏的头,逗号分隔',
+  `log_max_size` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '日志中body的最大长度,0使用默认值',
+  `max_body_size` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '请求body的最大长度,单位KB,0使用全局配置',
+  `max_header_size` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '请求头的最大长度,单位KB,0使用全局配置',
   `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
   `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY (`id`),
@@ -149,14 +162,281 @@ DROP TABLE IF EXISTS `variable`;
 CREATE TABLE `variable` (
   `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
   `interface_id` bigint(20) NOT NULL COMMENT '接口id',
-  `postion` tinyint(1) unsigned NOT NULL COMMENT '0:url参数\r\n1:header参数2:post body',
+  `postion` tinyint(1) unsigned NOT NULL COMMENT '0:url参数\r\n1:header参数\r\n3:form参数\r\n4:请求json\r\n14:返回json',
   `name` varchar(64) NOT NULL COMMENT '字段名',
-  `is_number` tinyint(1) NOT NULL COMMENT '0:string, 1:number',
-  `is_required` tinyint(1) NOT NULL COMMENT '0:可选，1：必选',
-  `example` varchar(64) NOT NULL COMMENT '示例',
-  `comments` varchar(512) DEFAULT NULL,
+  `type` varchar(64) NOT NULL DEFAULT '' COMMENT '字段类型',
+  `level` int(10) unsigned NOT NULL DEFAULT '0' COMMENT 'json字段的层级',
+  `parent` varchar(64) NOT NULL DEFAULT '' COMMENT 'json字段的父字段类型',
+  `required` tinyint(1) NOT NULL DEFAULT '0' COMMENT '0:可选，1：必选',
+  `sensitive` tinyint(1) NOT NULL DEFAULT '0' COMMENT '1:敏感字段,日志中脱敏',
+  `example` varchar(64) NOT NULL DEFAULT '' COMMENT '示例',
+  `comment` varchar(512) DEFAULT NULL,
+  `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_interface_id` (`interface_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for transform
+-- ----------------------------
+DROP TABLE IF EXISTS `transform`;
+CREATE TABLE `transform` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `interface_id` bigint(20) unsigned NOT NULL COMMENT '接口id',
+  `target` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT '0:请求header\r\n1:url参数\r\n2:form参数\r\n3:后端路径\r\n4:返回header',
+  `action` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT '0:添加\r\n1:设置\r\n2:删除\r\n3:改名',
+  `name` varchar(64) NOT NULL DEFAULT '' COMMENT '参数名',
+  `value` varchar(256) NOT NULL DEFAULT '' COMMENT '参数值或新名字，支持{app.id},{app.name},{session},{path},{header.X},{query.x}模板变量',
+  `comment` varchar(512) NOT NULL DEFAULT '',
+  `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_interface_id` (`interface_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for canary
+-- ----------------------------
+DROP TABLE IF EXISTS `canary`;
+CREATE TABLE `canary` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `service_id` bigint(20) unsigned NOT NULL COMMENT '服务id',
+  `version` varchar(64) NOT NULL DEFAULT '' COMMENT '灰度实例的GitHash前缀或GitTime',
+  `percent` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '按比例转发到灰度实例, 0-100',
+  `app_ids` varchar(512) NOT NULL DEFAULT '' COMMENT '指定应用转发到灰度实例, 逗号分隔',
+  `header_name` varchar(64) NOT NULL DEFAULT '' COMMENT '指定header转发到灰度实例',
+  `header_value` varchar(128) NOT NULL DEFAULT '',
+  `state` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT '0:关闭, 1:开启',
+  `comment` varchar(512) NOT NULL DEFAULT '',
+  `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_service_id` (`service_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for stats_version
+-- ----------------------------
+DROP TABLE IF EXISTS `stats_version`;
+CREATE TABLE `stats_version` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `iface_id` bigint(20) unsigned NOT NULL,
+  `version` varchar(64) NOT NULL DEFAULT '' COMMENT '后端实例GitHash',
+  `cnt` bigint(20) unsigned NOT NULL DEFAULT '0',
+  `err` bigint(20) unsigned NOT NULL DEFAULT '0',
+  `cost` bigint(20) unsigned NOT NULL DEFAULT '0' COMMENT '总耗时,毫秒',
+  `event_time` varchar(16) NOT NULL DEFAULT '' COMMENT '精确到分钟',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_version` (`iface_id`,`version`,`event_time`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for token_key
+-- ----------------------------
+DROP TABLE IF EXISTS `token_key`;
+CREATE TABLE `token_key` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `kid` varchar(32) NOT NULL COMMENT '密钥id, 写在token中',
+  `secret` varchar(128) NOT NULL COMMENT 'hmac-sha256签名密钥',
+  `state` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT '0:停用\r\n1:签发及验证\r\n2:只验证',
+  `comment` varchar(512) NOT NULL DEFAULT '',
+  `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_kid` (`kid`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for app_token
+-- ----------------------------
+DROP TABLE IF EXISTS `app_token`;
+CREATE TABLE `app_token` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `application_id` bigint(20) unsigned NOT NULL,
+  `jti` varchar(32) NOT NULL COMMENT 'token id',
+  `kid` varchar(32) NOT NULL COMMENT '签名密钥id',
+  `scopes` varchar(1024) NOT NULL DEFAULT '' COMMENT '允许访问的服务路径, 逗号分隔, 为空不限制',
+  `expire` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '过期时间',
+  `revoked` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT '1:已吊销',
+  `comment` varchar(512) NOT NULL DEFAULT '',
+  `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_jti` (`jti`) USING BTREE,
+  KEY `idx_application_id` (`application_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for backend_policy
+-- ----------------------------
+DROP TABLE IF EXISTS `backend_policy`;
+CREATE TABLE `backend_policy` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `interface_id` bigint(20) unsigned NOT NULL COMMENT '接口id',
+  `connect_timeout` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '连接超时,毫秒,0使用默认值',
+  `timeout` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '请求超时,毫秒,0使用默认值',
+  `attempts` int(10) unsigned NOT NULL DEFAULT '1' COMMENT '总尝试次数,1表示不重试',
+  `retry_on` varchar(128) NOT NULL DEFAULT '' COMMENT '可重试的错误,逗号分隔:\r\nerror:请求出错\r\ntimeout:超时\r\n5xx:所有5xx状态码\r\n502:指定状态码\r\n为空时是error,502,503,504',
+  `non_idempotent` tinyint(1) NOT NULL DEFAULT '0' COMMENT '1:POST等非幂等方法也重试',
+  `backoff` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '第一次重试前等待时间,毫秒,之后每次翻倍',
+  `max_backoff` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '最大等待时间,毫秒',
+  `comment` varchar(512) NOT NULL DEFAULT '',
+  `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_interface_id` (`interface_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for stats_retry
+-- ----------------------------
+DROP TABLE IF EXISTS `stats_retry`;
+CREATE TABLE `stats_retry` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `iface_id` bigint(20) unsigned NOT NULL,
+  `backend` varchar(128) NOT NULL DEFAULT '' COMMENT '后端地址或faas服务名',
+  `cnt` bigint(20) unsigned NOT NULL DEFAULT '0' COMMENT '重试次数',
+  `event_time` varchar(16) NOT NULL DEFAULT '' COMMENT '精确到分钟',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_retry` (`iface_id`,`backend`,`event_time`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for mirror
+-- ----------------------------
+DROP TABLE IF EXISTS `mirror`;
+CREATE TABLE `mirror` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `interface_id` bigint(20) unsigned NOT NULL COMMENT '接口id',
+  `target` varchar(128) NOT NULL DEFAULT '' COMMENT '影子后端地址,scheme://host:port',
+  `percent` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '镜像比例, 0-100',
+  `diff` tinyint(1) NOT NULL DEFAULT '0' COMMENT '1:记录与主后端的差异',
+  `state` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT '0:关闭, 1:开启',
+  `comment` varchar(512) NOT NULL DEFAULT '',
   `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
   `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_interface_id` (`interface_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for mirror_diff
+-- ----------------------------
+DROP TABLE IF EXISTS `mirror_diff`;
+CREATE TABLE `mirror_diff` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `interface_id` bigint(20) unsigned NOT NULL COMMENT '接口id',
+  `session` varchar(64) NOT NULL DEFAULT '',
+  `primary_status` int(10) NOT NULL DEFAULT '0' COMMENT '主后端状态码',
+  `mirror_status` int(10) NOT NULL DEFAULT '0' COMMENT '影子后端状态码',
+  `primary_hash` varchar(64) NOT NULL DEFAULT '' COMMENT '主后端返回body的sha256',
+  `mirror_hash` varchar(64) NOT NULL DEFAULT '' COMMENT '影子后端返回body的sha256',
+  `primary_cost` bigint(20) unsigned NOT NULL DEFAULT '0' COMMENT '主后端耗时,毫秒',
+  `mirror_cost` bigint(20) unsigned NOT NULL DEFAULT '0' COMMENT '影子后端耗时,毫秒',
+  `same` tinyint(1) NOT NULL DEFAULT '0' COMMENT '1:状态码及body一致',
+  `error` varchar(512) NOT NULL DEFAULT '' COMMENT '影子后端请求错误',
+  `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
+  PRIMARY KEY (`id`),
   KEY `idx_interface_id` (`interface_id`) USING BTREE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for stats_latency
+-- ----------------------------
+DROP TABLE IF EXISTS `stats_latency`;
+CREATE TABLE `stats_latency` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `iface_id` bigint(20) unsigned NOT NULL,
+  `app_id` bigint(20) unsigned NOT NULL,
+  `buckets` varchar(1024) NOT NULL DEFAULT '' COMMENT '耗时分布, 桶下标:个数,逗号分隔, 同一分钟多行查询时合并',
+  `event_time` varchar(16) NOT NULL DEFAULT '' COMMENT '精确到分钟',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_iface_time` (`iface_id`,`event_time`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for certificate
+-- ----------------------------
+DROP TABLE IF EXISTS `certificate`;
+CREATE TABLE `certificate` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `domain` varchar(255) NOT NULL DEFAULT '' COMMENT '域名,支持*.example.com,default为默认证书',
+  `cert` text NOT NULL COMMENT 'PEM格式证书链',
+  `key` text NOT NULL COMMENT 'PEM格式私钥',
+  `not_after` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '证书过期时间',
+  `state` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT '0:关闭, 1:启用',
+  `comment` varchar(512) NOT NULL DEFAULT '',
+  `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_domain` (`domain`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for cors
+-- ----------------------------
+DROP TABLE IF EXISTS `cors`;
+CREATE TABLE `cors` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `service_id` bigint(20) unsigned NOT NULL COMMENT '服务id',
+  `origins` varchar(1024) NOT NULL DEFAULT '' COMMENT '允许的来源,逗号分隔,支持*及https://*.example.com',
+  `methods` varchar(128) NOT NULL DEFAULT '' COMMENT '允许的方法,逗号分隔,为空时GET,POST,PUT,DELETE',
+  `headers` varchar(512) NOT NULL DEFAULT '' COMMENT '允许的请求头,逗号分隔,*表示全部',
+  `credentials` tinyint(1) NOT NULL DEFAULT '0' COMMENT '1:允许携带cookie',
+  `max_age` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '预检结果缓存时间,秒',
+  `state` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT '0:关闭, 1:开启',
+  `comment` varchar(512) NOT NULL DEFAULT '',
+  `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_service_id` (`service_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for stats_limit
+-- ----------------------------
+DROP TABLE IF EXISTS `stats_limit`;
+CREATE TABLE `stats_limit` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `iface_id` bigint(20) unsigned NOT NULL COMMENT '找不到接口时为0',
+  `kind` varchar(16) NOT NULL DEFAULT '' COMMENT 'body,header,timeout,conn,shed',
+  `cnt` bigint(20) unsigned NOT NULL DEFAULT '0' COMMENT '超出限制被拒绝的请求数',
+  `event_time` varchar(16) NOT NULL DEFAULT '' COMMENT '精确到分钟',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_limit` (`iface_id`,`kind`,`event_time`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for quota
+-- ----------------------------
+DROP TABLE IF EXISTS `quota`;
+CREATE TABLE `quota` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `application_id` bigint(20) unsigned NOT NULL COMMENT '应用id',
+  `interface_id` bigint(20) unsigned NOT NULL COMMENT '接口id',
+  `period` varchar(8) NOT NULL DEFAULT 'day' COMMENT 'day:按自然日, month:按自然月',
+  `calls` bigint(20) unsigned NOT NULL DEFAULT '0' COMMENT '每个周期允许的调用次数',
+  `comment` varchar(512) NOT NULL DEFAULT '',
+  `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_quota` (`application_id`,`interface_id`,`period`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for quota_usage
+-- ----------------------------
+DROP TABLE IF EXISTS `quota_usage`;
+CREATE TABLE `quota_usage` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `quota_id` bigint(20) unsigned NOT NULL COMMENT '配额id',
+  `period_key` varchar(16) NOT NULL DEFAULT '' COMMENT '周期, 如2006-01-02或2006-01',
+  `cnt` bigint(20) unsigned NOT NULL DEFAULT '0' COMMENT '所有网关的调用次数',
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_usage` (`quota_id`,`period_key`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -15,6 +15,16 @@ type managerConfig struct {
 	URL string
 }
 
+type logConfig struct {
+	// Level 日志级别, 如debug, info, warning, error, none表示不修改.
+	Level string `cfg_default:"none"`
+}
+
+type reloadConfig struct {
+	// Watch 检查配置文件修改的间隔, 单位秒, 0表示只在收到SIGHUP时重新加载.
+	Watch int `cfg_default:"0"`
+}
+
 type serverConfig struct {
 	SecretKey string
 	BuildPath string
@@ -27,6 +37,8 @@ type Config struct {
 	ETCD    etcdConfig
 	Server  serverConfig
 	Manager managerConfig
+	Log     logConfig
+	Reload  reloadConfig
 }
 
 var (
@@ -37,3 +49,17 @@ var (
 func Load() error {
 	return dcfg.LoadConfig(*cfgPath, &Distributor)
 }
+
+// Path 配置文件路径.
+func Path() string {
+	return *cfgPath
+}
+
+// Parse 读取配置文件到新的结构中, 不修改当前配置, 用于重新加载前的检查.
+func Parse() (*Config, error) {
+	var c Config
+	if err := dcfg.LoadConfig(*cfgPath, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -23,6 +23,8 @@ func Init() error {
 
 	mdb = &config.Distributor.DB
 
+	setLogLevel()
+
 	server.RegisterPath(&distributor{}, "/distributor/")
 
 	if w, err = newWatcher(); err != nil {
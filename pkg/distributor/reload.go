@@ -0,0 +1,57 @@
+package distributor
+
+import (
+	"sync"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/distributor/config"
+	"dearcode.net/doodle/pkg/util/reload"
+)
+
+var (
+	// hotFields 不需要重启就能生效的配置, SecretKey与manager共用, 修改需要重启.
+	hotFields = []string{"DB", "ETCD", "Manager", "Log", "Server.BuildPath", "Server.Script", "Server.Timeout"}
+
+	reloaders = map[string]func(){
+		"ETCD": func() { w.etcd.SetEndpoints(config.Distributor.ETCD.Hosts) },
+		"Log":  setLogLevel,
+	}
+
+	reloadMu sync.Mutex
+)
+
+// setLogLevel 配置了日志级别时使用它, 否则保持启动时的级别.
+func setLogLevel() {
+	if l := config.Distributor.Log.Level; l != "none" {
+		log.SetLevelByString(l)
+	}
+}
+
+// Reload 重新读取配置文件, 替换可以在线修改的配置, 返回生效及需要重启的字段.
+func Reload() (reload.Report, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	nc, err := config.Parse()
+	if err != nil {
+		return reload.Report{}, errors.Trace(err)
+	}
+
+	r := reload.Apply(&config.Distributor, nc, hotFields)
+	for _, s := range r.Sections() {
+		if fn, ok := reloaders[s]; ok {
+			fn()
+		}
+	}
+
+	for _, f := range r.Applied {
+		log.Infof("reload config %s applied", f)
+	}
+	for _, f := range r.Restart {
+		log.Warningf("reload config %s changed, need restart", f)
+	}
+
+	return r, nil
+}
@@ -8,11 +8,14 @@ import (
 	"strings"
 
 	"dearcode.net/crab/log"
+	"dearcode.net/crab/orm"
 	"dearcode.net/crab/util/aes"
 	"github.com/juju/errors"
 
 	"dearcode.net/doodle/pkg/meta"
 	"dearcode.net/doodle/pkg/util"
+	"dearcode.net/doodle/pkg/util/cidr"
+	"dearcode.net/doodle/pkg/util/token"
 )
 
 type application struct {
@@ -208,6 +211,7 @@ func (a *app) DELETE(w http.ResponseWriter, r *http.Request) {
 		util.SendResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	notify("application", vars.ID, 0)
 	util.SendResponse(w, 0, "")
 
 	log.Debugf("delete service:%v, success", vars.ID)
@@ -298,7 +302,203 @@ func (a *app) PUT(w http.ResponseWriter, r *http.Request) {
 		util.SendResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	notify("application", vars.ID, 0)
 	util.SendResponse(w, 0, "")
 
 	log.Debugf("update service success, new:%+v", vars)
 }
+
+type appSecret struct {
+}
+
+// POST 重新生成应用的签名密钥, 密钥只返回这一次, 旧密钥立即失效.
+func (a *appSecret) POST(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID int64 `json:"id" valid:"Required"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = assertApp(u, vars.ID); err != nil {
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	secret := token.NewSecret()
+
+	if _, err = orm.NewStmt(db, "application").Exec("update application set secret=? where id=?", secret, vars.ID); err != nil {
+		log.Errorf("update application:%d secret error:%v", vars.ID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("application", vars.ID, 0)
+	util.SendResponse(w, 0, `{"id":%d, "secret":"%s"}`, vars.ID, secret)
+
+	log.Debugf("%s reset application:%d secret", u.Email, vars.ID)
+}
+
+// PUT 修改应用的认证方式, 0:token, 1:签名, 2:两种都可以.
+func (a *appSecret) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID       int64 `json:"id" valid:"Required"`
+		AuthMode int   `json:"authMode"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mode := meta.AuthMode(vars.AuthMode)
+	if mode != meta.AuthToken && mode != meta.AuthSignature && mode != meta.AuthAny {
+		util.SendResponse(w, http.StatusBadRequest, "invalid authMode:%d", vars.AuthMode)
+		return
+	}
+
+	if err = assertApp(u, vars.ID); err != nil {
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	if _, err = orm.NewStmt(db, "application").Exec("update application set auth_mode=? where id=?", vars.AuthMode, vars.ID); err != nil {
+		log.Errorf("update application:%d auth_mode error:%v", vars.ID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("application", vars.ID, 0)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("%s set application:%d auth mode:%d", u.Email, vars.ID, vars.AuthMode)
+}
+
+type appNetwork struct {
+}
+
+// GET 查询应用允许调用的来源网段.
+func (a *appNetwork) GET(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID int64 `json:"id" valid:"Required"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = assertApp(u, vars.ID); err != nil {
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	var allow string
+	if err = db.QueryRow("select allow_cidr from application where id=?", vars.ID).Scan(&allow); err != nil {
+		log.Errorf("query application:%d allow_cidr error:%v", vars.ID, err)
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	util.SendResponseJSON(w, struct {
+		ID        int64  `json:"id"`
+		AllowCIDR string `json:"allowCIDR"`
+	}{vars.ID, allow})
+}
+
+// PUT 修改应用允许调用的来源网段, 逗号分隔, 为空不限制.
+func (a *appNetwork) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID        int64  `json:"id" valid:"Required"`
+		AllowCIDR string `json:"allowCIDR"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	nets, err := cidr.Parse(vars.AllowCIDR)
+	if err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = assertApp(u, vars.ID); err != nil {
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	if _, err = orm.NewStmt(db, "application").Exec("update application set allow_cidr=? where id=?", nets.String(), vars.ID); err != nil {
+		log.Errorf("update application:%d allow_cidr error:%v", vars.ID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("application", vars.ID, 0)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("%s set application:%d allow cidr:%s", u.Email, vars.ID, nets)
+}
@@ -0,0 +1,180 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+
+	"dearcode.net/crab/http/server"
+	"dearcode.net/crab/log"
+	"dearcode.net/crab/orm"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util"
+)
+
+type canary struct {
+}
+
+// GET 查询服务的灰度策略.
+func (c *canary) GET(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ServiceID int64 `json:"serviceID" valid:"Required"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var cs []meta.Canary
+
+	total, err := query("canary", fmt.Sprintf("service_id=%d", vars.ServiceID), "id", "desc", 0, 0, &cs)
+	if err != nil {
+		log.Errorf("query canary:%d error:%s", vars.ServiceID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	server.SendRows(w, total, cs)
+}
+
+// POST 添加灰度策略, 同一个服务只有最新一条开启的策略生效.
+func (c *canary) POST(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ServiceID   int64  `json:"serviceID" valid:"Required"`
+		Version     string `json:"version" valid:"Required"`
+		Percent     int    `json:"percent" valid:"Range(0, 100)"`
+		AppIDs      string `json:"appIDs" db:"app_ids"`
+		HeaderName  string `json:"headerName"`
+		HeaderValue string `json:"headerValue"`
+		State       int    `json:"state"`
+		Comment     string `json:"comment"`
+		Ctime       string `db_default:"now()"`
+		Mtime       string `db_default:"now()"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		log.Errorf("invalid req:%+v", r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := assertService(w, r, vars.ServiceID); err != nil {
+		log.Errorf("service:%d, vars:%+v, err:%v", vars.ServiceID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	id, err := orm.NewStmt(db, "canary").Insert(&vars)
+	if err != nil {
+		log.Errorf("insert canary:%+v error:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("canary", id, vars.ServiceID)
+	util.SendResponse(w, 0, `{"id":%d}`, id)
+
+	log.Debugf("add canary success, id:%v, %+v", id, vars)
+}
+
+// PUT 修改灰度策略, 调整比例或者开关.
+func (c *canary) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID          int64  `json:"id" valid:"Required" db_auto:""`
+		ServiceID   int64  `json:"serviceID" valid:"Required" db_auto:""`
+		Version     string `json:"version" valid:"Required"`
+		Percent     int    `json:"percent" valid:"Range(0, 100)"`
+		AppIDs      string `json:"appIDs" db:"app_ids"`
+		HeaderName  string `json:"headerName"`
+		HeaderValue string `json:"headerValue"`
+		State       int    `json:"state"`
+		Comment     string `json:"comment"`
+		Mtime       string `db_const:"now()"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		log.Errorf("invalid req:%+v", r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := assertService(w, r, vars.ServiceID); err != nil {
+		log.Errorf("service:%d, vars:%+v, err:%v", vars.ServiceID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	if _, err = orm.NewStmt(db, "canary").Where("id=%d and service_id=%d", vars.ID, vars.ServiceID).Update(&vars); err != nil {
+		log.Errorf("update canary:%+v error:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("canary", vars.ID, vars.ServiceID)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("update canary success, new:%+v", vars)
+}
+
+// DELETE 删除灰度策略, 流量回到所有实例.
+func (c *canary) DELETE(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID        int64 `json:"id" valid:"Required"`
+		ServiceID int64 `json:"serviceID" valid:"Required"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := assertService(w, r, vars.ServiceID); err != nil {
+		log.Errorf("service:%d, vars:%+v, err:%v", vars.ServiceID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	//带上service_id, 不能通过自己的服务删除别人的灰度策略
+	res, err := db.Exec("delete from canary where id=? and service_id=?", vars.ID, vars.ServiceID)
+	if err != nil {
+		log.Errorf("delete canary:%+v error:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		util.SendResponse(w, http.StatusNotFound, "canary:%d not found in service:%d", vars.ID, vars.ServiceID)
+		return
+	}
+
+	notify("canary", vars.ID, vars.ServiceID)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("delete canary:%v, success", vars.ID)
+}
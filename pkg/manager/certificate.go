@@ -0,0 +1,255 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"dearcode.net/crab/http/server"
+	"dearcode.net/crab/log"
+	"dearcode.net/crab/orm"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/util"
+	"dearcode.net/doodle/pkg/util/cert"
+)
+
+var (
+	// domainSearchExp 查询证书时域名的关键字.
+	domainSearchExp = regexp.MustCompile(`^[a-z0-9.*-]+$`)
+)
+
+type certificate struct {
+}
+
+// GET 查询网关证书, 不返回私钥.
+func (c *certificate) GET(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		Domain string `json:"domain"`
+		Sort   string `json:"sort"`
+		Order  string `json:"order"`
+		Page   int    `json:"offset"`
+		Size   int    `json:"limit"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !u.IsAdmin {
+		util.SendResponse(w, http.StatusForbidden, "only admin can query certificate")
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var where string
+	if vars.Domain != "" {
+		//拼到like里的, 只允许域名中的字符
+		domain := strings.ToLower(strings.TrimSpace(vars.Domain))
+		if !domainSearchExp.MatchString(domain) {
+			util.SendResponse(w, http.StatusBadRequest, "invalid domain:%s", vars.Domain)
+			return
+		}
+		where = fmt.Sprintf("domain like '%%%s%%'", domain)
+	}
+
+	var cs []certificateInfo
+
+	total, err := query("certificate", where, vars.Sort, vars.Order, vars.Page, vars.Size, &cs)
+	if err != nil {
+		log.Errorf("query certificate error:%s", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if len(cs) == 0 {
+		log.Infof("certificate not found")
+		util.SendResponse(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	server.SendRows(w, total, cs)
+}
+
+// POST 上传证书及私钥, 同一个域名只有一个, 重复上传时覆盖, 域名为default时作为默认证书.
+func (c *certificate) POST(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		Domain  string `json:"domain" valid:"Required"`
+		Cert    string `json:"cert" valid:"Required"`
+		Key     string `json:"key" valid:"Required"`
+		State   int    `json:"state"`
+		Comment string `json:"comment"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !u.IsAdmin {
+		util.SendResponse(w, http.StatusForbidden, "only admin can upload certificate")
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	vars.Domain = strings.ToLower(strings.TrimSpace(vars.Domain))
+
+	kp, err := cert.KeyPair([]byte(vars.Cert), []byte(vars.Key))
+	if err != nil {
+		util.SendResponse(w, http.StatusBadRequest, "invalid certificate or key:%v", err)
+		return
+	}
+
+	if vars.Domain != "default" {
+		host := vars.Domain
+		if strings.HasPrefix(host, "*.") {
+			host = "x" + host[1:]
+		}
+		if err = kp.Leaf.VerifyHostname(host); err != nil {
+			util.SendResponse(w, http.StatusBadRequest, "certificate not match domain:%s, %v", vars.Domain, err)
+			return
+		}
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	sql := "insert into certificate (domain, cert, `key`, not_after, state, comment, ctime) values (?,?,?,?,?,?,now()) " +
+		"ON DUPLICATE KEY UPDATE cert=values(cert), `key`=values(`key`), not_after=values(not_after), state=values(state), comment=values(comment)"
+
+	res, err := db.Exec(sql, vars.Domain, vars.Cert, vars.Key, kp.Leaf.NotAfter.Local().Format("2006-01-02 15:04:05"), vars.State, vars.Comment)
+	if err != nil {
+		log.Errorf("update certificate:%s error:%v", vars.Domain, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, _ := res.LastInsertId()
+
+	notify("certificate", id, 0)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("%s update certificate:%s, not after:%v", u.Email, vars.Domain, kp.Leaf.NotAfter)
+}
+
+// DELETE 删除证书.
+func (c *certificate) DELETE(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID int64 `json:"id" valid:"Required"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !u.IsAdmin {
+		util.SendResponse(w, http.StatusForbidden, "only admin can delete certificate")
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("delete from certificate where id=?", vars.ID); err != nil {
+		log.Errorf("delete certificate:%d error:%v", vars.ID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("certificate", vars.ID, 0)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("%s delete certificate:%d", u.Email, vars.ID)
+}
+
+type appCert struct {
+}
+
+// PUT 绑定应用的客户端证书, 双向TLS时用证书识别应用, 证书为空时解除绑定.
+func (a *appCert) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID   int64  `json:"id" valid:"Required"`
+		Cert string `json:"cert"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var fp string
+	if vars.Cert != "" {
+		c, err := cert.ParsePEM(vars.Cert)
+		if err != nil {
+			util.SendResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		fp = cert.Fingerprint(c.Raw)
+	}
+
+	if err = assertApp(u, vars.ID); err != nil {
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	if _, err = orm.NewStmt(db, "application").Exec("update application set cert_fingerprint=? where id=?", fp, vars.ID); err != nil {
+		log.Errorf("update application:%d cert_fingerprint error:%v", vars.ID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("application", vars.ID, 0)
+	util.SendResponseJSON(w, struct {
+		ID              int64  `json:"id"`
+		CertFingerprint string `json:"certFingerprint"`
+	}{vars.ID, fp})
+
+	log.Debugf("%s set application:%d cert fingerprint:%s", u.Email, vars.ID, fp)
+}
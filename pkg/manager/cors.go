@@ -0,0 +1,134 @@
+package manager
+
+import (
+	"net/http"
+
+	"dearcode.net/crab/log"
+	"dearcode.net/crab/orm"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util"
+)
+
+type cors struct {
+}
+
+// GET 查询服务的跨域配置.
+func (c *cors) GET(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ServiceID int64 `json:"serviceID" valid:"Required"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	var cc meta.Cors
+	if err = orm.NewStmt(db, "cors").Where("service_id=%d", vars.ServiceID).Query(&cc); err != nil {
+		log.Errorf("query cors:%d error:%s", vars.ServiceID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	util.SendResponseJSON(w, &cc)
+}
+
+// PUT 设置服务的跨域配置, 每个服务只有一条.
+func (c *cors) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ServiceID   int64  `json:"serviceID" valid:"Required"`
+		Origins     string `json:"origins" valid:"Required"`
+		Methods     string `json:"methods"`
+		Headers     string `json:"headers"`
+		Credentials bool   `json:"credentials"`
+		MaxAge      int    `json:"maxAge" valid:"Min(0)"`
+		State       int    `json:"state"`
+		Comment     string `json:"comment"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		log.Errorf("invalid req:%+v", r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if vars.Credentials && vars.Origins == "*" {
+		util.SendResponse(w, http.StatusBadRequest, "credentials not allowed with origins *")
+		return
+	}
+
+	if err := assertService(w, r, vars.ServiceID); err != nil {
+		log.Errorf("service:%d, vars:%+v, err:%v", vars.ServiceID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	sql := "insert into cors (service_id, origins, methods, headers, credentials, max_age, state, comment, ctime) values (?,?,?,?,?,?,?,?,now()) " +
+		"ON DUPLICATE KEY UPDATE origins=values(origins), methods=values(methods), headers=values(headers), credentials=values(credentials), max_age=values(max_age), state=values(state), comment=values(comment)"
+
+	if _, err = db.Exec(sql, vars.ServiceID, vars.Origins, vars.Methods, vars.Headers, vars.Credentials, vars.MaxAge, vars.State, vars.Comment); err != nil {
+		log.Errorf("update cors:%+v error:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("cors", 0, vars.ServiceID)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("update cors success, new:%+v", vars)
+}
+
+// DELETE 删除跨域配置.
+func (c *cors) DELETE(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ServiceID int64 `json:"serviceID" valid:"Required"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := assertService(w, r, vars.ServiceID); err != nil {
+		log.Errorf("service:%d, vars:%+v, err:%v", vars.ServiceID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("delete from cors where service_id=?", vars.ServiceID); err != nil {
+		log.Errorf("delete cors:%d error:%v", vars.ServiceID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("cors", 0, vars.ServiceID)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("delete cors:%d success", vars.ServiceID)
+}
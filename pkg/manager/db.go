@@ -2,6 +2,7 @@ package manager
 
 import (
 	"bytes"
+	"database/sql"
 	"fmt"
 	"reflect"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/juju/errors"
 
 	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util/route"
 )
 
 // result 必需是一个指向切片的指针
@@ -164,17 +166,94 @@ func deployInterface(id int64) error {
 	return errors.Trace(err)
 }
 
-func updateInterface(id int64, method, level int, name, path, backend, comment, user, email string) error {
-	sql := "update interface set name=?, method=?,level=?, path=?, backend=?, comment=?, mtime=now(), user=?, email=? where id=?"
+// updateInterface internal无效时不修改原来的值.
+func updateInterface(id int64, method, level int, internal sql.NullBool, name, path, backend, comment, user, email string) error {
+	query := "update interface set name=?, method=?,level=?, internal=coalesce(?, internal), path=?, backend=?, comment=?, mtime=now(), user=?, email=? where id=?"
 	db, err := mdb.GetConnection()
 	if err != nil {
 		return errors.Trace(err)
 	}
 	defer db.Close()
-	_, err = db.Exec(sql, name, method, level, path, backend, comment, user, email, id)
+	_, err = db.Exec(query, name, method, level, internal, path, backend, comment, user, email, id)
 	return errors.Trace(err)
 }
 
+func updateInterfaceMock(id int64, mock bool, latency, errorRate int) error {
+	sql := "update interface set mock=?, mock_latency=?, mock_error_rate=?, mtime=now() where id=?"
+	db, err := mdb.GetConnection()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(sql, mock, latency, errorRate, id)
+	return errors.Trace(err)
+}
+
+func updateInterfaceRedact(id int64, fields, headers string, maxSize int) error {
+	sql := "update interface set redact_fields=?, redact_headers=?, log_max_size=?, mtime=now() where id=?"
+	db, err := mdb.GetConnection()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(sql, fields, headers, maxSize, id)
+	return errors.Trace(err)
+}
+
+func updateInterfaceLimit(id int64, bodySize, headerSize int) error {
+	sql := "update interface set max_body_size=?, max_header_size=?, mtime=now() where id=?"
+	db, err := mdb.GetConnection()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(sql, bodySize, headerSize, id)
+	return errors.Trace(err)
+}
+
+// getServiceRoutes 服务下所有按模式匹配的接口路径.
+func getServiceRoutes(serviceID int64) ([]meta.Route, error) {
+	db, err := mdb.GetConnection()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("select id, path from interface where service_id=?", serviceID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	var rs []meta.Route
+	for rows.Next() {
+		var r meta.Route
+		if err = rows.Scan(&r.ID, &r.Path); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if route.IsPattern(r.Path) {
+			rs = append(rs, r)
+		}
+	}
+
+	return rs, nil
+}
+
+func getInterfaceServiceID(id int64) (int64, error) {
+	db, err := mdb.GetConnection()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer db.Close()
+
+	var sid int64
+	if err = db.QueryRow("select service_id from interface where id=?", id).Scan(&sid); err != nil {
+		return 0, errors.Annotatef(err, "interface:%d", id)
+	}
+
+	return sid, nil
+}
+
 func updateVariable(id int64, postion int, name, Type string, required int, example, comment string) error {
 	sql := "update variable set postion=?, name =?, type=?, required=?, example=?, comment=?, mtime=now() where id=?"
 	db, err := mdb.GetConnection()
@@ -407,6 +486,35 @@ func selectStats(id int64) ([]statsSum, error) {
 	return sss, nil
 }
 
+// selectVersionStats 接口最近一小时按后端版本统计的调用情况.
+func selectVersionStats(id int64) ([]statsVersion, error) {
+	sql := "SELECT event_time, version, cnt, err, ROUND(cost / cnt) FROM stats_version where iface_id = ? and event_time > now() - interval 1 hour order by event_time desc, version"
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(sql, id)
+	if err != nil {
+		return nil, errors.Annotatef(err, "%s", sql)
+	}
+	defer rows.Close()
+
+	svs := []statsVersion{}
+
+	for rows.Next() {
+		var sv statsVersion
+		if err = rows.Scan(&sv.Date, &sv.Version, &sv.Sum, &sv.Err, &sv.Avg); err != nil {
+			return nil, errors.Annotatef(err, "%s", sql)
+		}
+		svs = append(svs, sv)
+	}
+
+	return svs, nil
+}
+
 func selectTopIface() ([]statsTopIface, error) {
 	sql := "SELECT i.id, p.name,i.name,i.user,sum(cnt) from stats as s,interface as i, service as p  where s.iface_id = i.id and  i.service_id = p.id and s.mtime > CURDATE()-interval 1 day GROUP BY iface_id ORDER BY sum(cnt) desc limit 10"
 	db, err := mdb.GetConnection()
@@ -464,3 +572,81 @@ func selectTopApp(ifaceID int64) ([]statsTopApp, error) {
 
 	return tas, nil
 }
+
+// latencyRow 耗时分布的一行原始记录.
+type latencyRow struct {
+	date    string
+	buckets string
+}
+
+// selectLatencyStats 查询接口在时间范围内的耗时分布, app为0时查所有应用, 按时间排序.
+func selectLatencyStats(iface, app int64, begin, end string) ([]latencyRow, error) {
+	sql := "SELECT event_time, buckets FROM stats_latency where iface_id = ? and event_time >= ? and event_time <= ?"
+	args := []interface{}{iface, begin, end}
+	if app != 0 {
+		sql += " and app_id = ?"
+		args = append(args, app)
+	}
+	sql += " order by event_time"
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(sql, args...)
+	if err != nil {
+		return nil, errors.Annotatef(err, "%s", sql)
+	}
+	defer rows.Close()
+
+	var lrs []latencyRow
+
+	for rows.Next() {
+		var lr latencyRow
+		if err = rows.Scan(&lr.date, &lr.buckets); err != nil {
+			return nil, errors.Annotatef(err, "%s", sql)
+		}
+		lrs = append(lrs, lr)
+	}
+
+	return lrs, nil
+}
+
+// selectQuotaUsage 应用在[begin, end)内按天或按月调用每个接口的次数, 以及对应周期的配额.
+func selectQuotaUsage(app int64, period, begin, end string) ([]quotaUsage, error) {
+	n := 10
+	if period == meta.QuotaMonth {
+		n = 7
+	}
+
+	sql := fmt.Sprintf("SELECT left(s.event_time, %d) as p, i.id, i.name, v.name, sum(s.cnt), ifnull(q.calls, 0) FROM stats as s "+
+		"join interface as i on s.iface_id = i.id join service as v on i.service_id = v.id "+
+		"left join quota as q on q.application_id = s.app_id and q.interface_id = s.iface_id and q.period = ? "+
+		"where s.app_id = ? and s.event_time >= ? and s.event_time < ? group by p, i.id order by p, i.id", n)
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(sql, period, app, begin, end)
+	if err != nil {
+		return nil, errors.Annotatef(err, "%s", sql)
+	}
+	defer rows.Close()
+
+	us := []quotaUsage{}
+
+	for rows.Next() {
+		var u quotaUsage
+		if err = rows.Scan(&u.Period, &u.InterfaceID, &u.InterfaceName, &u.ServiceName, &u.Calls, &u.Quota); err != nil {
+			return nil, errors.Annotatef(err, "%s", sql)
+		}
+		us = append(us, u)
+	}
+
+	return us, nil
+}
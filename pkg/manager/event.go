@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"encoding/json"
+	"sync"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/manager/config"
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util/etcd"
+)
+
+var (
+	eventClient *etcd.Client
+	eventMu     sync.Mutex
+)
+
+func getEventClient() (*etcd.Client, error) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+
+	if eventClient != nil {
+		return eventClient, nil
+	}
+
+	c, err := etcd.New(config.Manager.ETCD.Hosts)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	eventClient = c
+	return c, nil
+}
+
+// notify 修改配置后通知repeater清理缓存, 每次写入都会生成新的版本号.
+// 失败只记录日志, repeater的缓存还会按超时时间过期.
+func notify(table string, id, parent int64) {
+	publish(meta.Event{Table: table, ID: id, Parent: parent})
+}
+
+// notifyRoutes 推送服务下所有路径模式的快照, repeater直接编译使用, 不用再查数据库.
+func notifyRoutes(serviceID int64) {
+	rs, err := getServiceRoutes(serviceID)
+	if err != nil {
+		log.Errorf("get service:%d routes error:%v", serviceID, errors.ErrorStack(err))
+		return
+	}
+
+	publish(meta.Event{Table: "route", ID: serviceID, Routes: rs})
+}
+
+func publish(e meta.Event) {
+	buf, err := json.Marshal(&e)
+	if err != nil {
+		log.Errorf("marshal event:%+v error:%v", e, err)
+		return
+	}
+
+	c, err := getEventClient()
+	if err != nil {
+		log.Errorf("connect etcd:%v error:%v", config.Manager.ETCD.Hosts, errors.ErrorStack(err))
+		return
+	}
+
+	if err = c.Put(meta.EventPrefix+e.Table, string(buf)); err != nil {
+		log.Errorf("put event:%+v error:%v", e, errors.ErrorStack(err))
+		return
+	}
+
+	log.Debugf("notify event:%+v", e)
+}
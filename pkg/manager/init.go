@@ -23,6 +23,8 @@ func ServerInit() error {
 	}
 	mdb = &config.Manager.DB
 
+	setLogLevel()
+
 	rbacClient = rbac.New(config.Manager.RBAC.Host, config.Manager.RBAC.Token)
 
 	httpClient = client.New().SetLogger(log.GetLogger())
@@ -49,6 +51,7 @@ func ServerInit() error {
 
 	server.RegisterPathMust(&serviceInfo{}, "/service/info/")
 	server.RegisterPathMust(&service{}, "/service/")
+	server.RegisterPathMust(&cors{}, "/service/cors/")
 
 	server.RegisterPathMust(&nodes{}, "/nodes/")
 
@@ -57,22 +60,44 @@ func ServerInit() error {
 	server.RegisterPathMust(&interfaceRun{}, "/interface/run")
 	server.RegisterPathMust(&interfaceInfo{}, "/interface/info")
 	server.RegisterPathMust(&interfaceDeploy{}, "/interface/deploy")
+	server.RegisterPathMust(&interfaceMock{}, "/interface/mock")
+	server.RegisterPathMust(&interfaceRedact{}, "/interface/redact")
+	server.RegisterPathMust(&interfaceLimit{}, "/interface/limit")
+	server.RegisterPathMust(&backendPolicy{}, "/interface/policy")
+	server.RegisterPathMust(&mirror{}, "/interface/mirror")
+	server.RegisterPathMust(&mirrorDiff{}, "/interface/mirror/diff")
 
 	server.RegisterPathMust(&variableInfo{}, "/variable/infos")
 	server.RegisterPathMust(&variable{}, "/variable/")
 
+	server.RegisterPathMust(&transform{}, "/transform/")
+	server.RegisterPathMust(&canary{}, "/canary/")
+
 	server.RegisterPathMust(&appInfo{}, "/application/info")
 	server.RegisterPathMust(&appInfos{}, "/application/infos")
 	server.RegisterPathMust(&app{}, "/application/")
+	server.RegisterPathMust(&appSecret{}, "/application/secret/")
+	server.RegisterPathMust(&appNetwork{}, "/application/network/")
+	server.RegisterPathMust(&appCert{}, "/application/cert/")
+	server.RegisterPathMust(&certificate{}, "/certificate/")
 
 	server.RegisterPathMust(&relation{}, "/relation/")
 
+	server.RegisterPathMust(&tokenAction{}, "/token/")
+	server.RegisterPathMust(&tokenRevoke{}, "/token/revoke/")
+	server.RegisterPathMust(&tokenKey{}, "/token/key/")
+
 	server.RegisterPathMust(&docs{}, "/docs/")
 
 	server.RegisterPathMust(&statsSumAction{}, "/stats/sum/")
 	server.RegisterPathMust(&statsTopApplication{}, "/stats/top/app/")
 	server.RegisterPathMust(&statsTopInterface{}, "/stats/top/iface/")
 	server.RegisterPathMust(&statsErrors{}, "/stats/error/")
+	server.RegisterPathMust(&statsVersionAction{}, "/stats/version/")
+	server.RegisterPathMust(&statsLatencyAction{}, "/stats/latency/")
+
+	server.RegisterPathMust(&quota{}, "/quota/")
+	server.RegisterPathMust(&quotaReport{}, "/quota/report")
 
 	return nil
 }
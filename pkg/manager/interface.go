@@ -20,6 +20,7 @@ import (
 	"dearcode.net/doodle/pkg/meta"
 	"dearcode.net/doodle/pkg/meta/document"
 	"dearcode.net/doodle/pkg/util"
+	"dearcode.net/doodle/pkg/util/route"
 )
 
 type interfaceRun struct {
@@ -237,12 +238,21 @@ func (i *interfaceAction) DELETE(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := del("interface", i.ID); err != nil {
+	sid, err := getInterfaceServiceID(i.ID)
+	if err != nil {
 		log.Errorf("%v interface error:%v", i, errors.ErrorStack(err))
 		util.SendResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	if err = del("interface", i.ID); err != nil {
+		log.Errorf("%v interface error:%v", i, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("interface", i.ID, 0)
+	notifyRoutes(sid)
 	util.SendResponse(w, 0, "")
 
 	log.Debugf("delete Interface:%v, success", i.ID)
@@ -264,6 +274,11 @@ func (i *interfaceAction) POST(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err = checkPath(vars.Path); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	resID, err := getServiceResourceID(vars.ServiceID)
 	if err != nil {
 		log.Errorf("invalid req:%+v", r)
@@ -299,6 +314,7 @@ func (i *interfaceAction) POST(w http.ResponseWriter, r *http.Request) {
 		util.SendResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	notifyRoutes(vars.ServiceID)
 	util.SendResponseJSON(w, &id)
 
 	log.Debugf("add Interface success, id:%v", id)
@@ -306,15 +322,16 @@ func (i *interfaceAction) POST(w http.ResponseWriter, r *http.Request) {
 
 func (i *interfaceAction) PUT(w http.ResponseWriter, r *http.Request) {
 	vars := struct {
-		ID      int64  `json:"id" valid:"Required"`
-		Name    string `json:"name"  valid:"Required"`
-		User    string `json:"user"`
-		Email   string `json:"email"`
-		Method  int    `json:"method"`
-		Path    string `json:"path"  valid:"AlphaNumeric"`
-		Backend string `json:"backend"  valid:"Required"`
-		Comment string `json:"comment"  valid:"Required"`
-		Level   int    `json:"level"`
+		ID       int64  `json:"id" valid:"Required"`
+		Name     string `json:"name"  valid:"Required"`
+		User     string `json:"user"`
+		Email    string `json:"email"`
+		Method   int    `json:"method"`
+		Path     string `json:"path"`
+		Backend  string `json:"backend"  valid:"Required"`
+		Comment  string `json:"comment"  valid:"Required"`
+		Level    int    `json:"level"`
+		Internal bool   `json:"internal"`
 	}{}
 
 	if err := util.DecodeRequestValue(r, &vars); err != nil {
@@ -323,15 +340,43 @@ func (i *interfaceAction) PUT(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := updateInterface(vars.ID, vars.Method, vars.Level, vars.Name, vars.Path, vars.Backend, vars.Comment, vars.User, vars.Email); err != nil {
+	if err := checkPath(vars.Path); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	//页面上没有提交internal时保持原来的值
+	_, ok := r.Form["internal"]
+	internal := sql.NullBool{Bool: vars.Internal, Valid: ok}
+
+	if err := updateInterface(vars.ID, vars.Method, vars.Level, internal, vars.Name, vars.Path, vars.Backend, vars.Comment, vars.User, vars.Email); err != nil {
 		util.SendResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	notify("interface", vars.ID, 0)
+	if sid, err := getInterfaceServiceID(vars.ID); err == nil {
+		notifyRoutes(sid)
+	}
 	util.SendResponse(w, 0, "")
 
 	log.Debugf("update Interface success, new:%+v", vars)
 }
 
+// checkPath 精确路径只能是字母数字, 路径模式按路由规则检查.
+func checkPath(path string) error {
+	if route.IsPattern(path) {
+		return route.Validate(path)
+	}
+
+	for _, c := range path {
+		if ('Z' < c || c < 'A') && ('z' < c || c < 'a') && ('9' < c || c < '0') {
+			return errors.Errorf("path:%s must be alpha or numeric characters", path)
+		}
+	}
+
+	return nil
+}
+
 type interfaceDeploy struct {
 }
 
@@ -350,11 +395,128 @@ func (id *interfaceDeploy) PUT(w http.ResponseWriter, r *http.Request) {
 		util.SendResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	notify("interface", vars.ID, 0)
 	util.SendResponse(w, 0, "")
 
 	log.Debugf("deploy Interface:%d success", vars.ID)
 }
 
+type interfaceMock struct {
+}
+
+// PUT 开关接口的mock模式, 开启后repeater根据返回值字段生成结果, 不调用后端.
+func (im *interfaceMock) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID        int64 `json:"id" valid:"Required"`
+		Mock      bool  `json:"mock"`
+		Latency   int   `json:"latency"`
+		ErrorRate int   `json:"errorRate"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		log.Errorf("invalid req:%+v", r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if vars.Latency < 0 || vars.ErrorRate < 0 || vars.ErrorRate > 100 {
+		util.SendResponse(w, http.StatusBadRequest, "invalid latency:%d or errorRate:%d", vars.Latency, vars.ErrorRate)
+		return
+	}
+
+	if err := assertInterface(w, r, vars.ID); err != nil {
+		log.Errorf("interface:%d, vars:%+v, err:%v", vars.ID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	if err := updateInterfaceMock(vars.ID, vars.Mock, vars.Latency, vars.ErrorRate); err != nil {
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	notify("interface", vars.ID, 0)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("update Interface mock success, new:%+v", vars)
+}
+
+type interfaceRedact struct {
+}
+
+// PUT 修改接口日志的脱敏规则, 字段名或json路径及头都是逗号分隔.
+func (ir *interfaceRedact) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID      int64  `json:"id" valid:"Required"`
+		Fields  string `json:"fields"`
+		Headers string `json:"headers"`
+		MaxSize int    `json:"maxSize"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		log.Errorf("invalid req:%+v", r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if vars.MaxSize < 0 {
+		util.SendResponse(w, http.StatusBadRequest, "invalid maxSize:%d", vars.MaxSize)
+		return
+	}
+
+	if err := assertInterface(w, r, vars.ID); err != nil {
+		log.Errorf("interface:%d, vars:%+v, err:%v", vars.ID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	if err := updateInterfaceRedact(vars.ID, vars.Fields, vars.Headers, vars.MaxSize); err != nil {
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	notify("interface", vars.ID, 0)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("update Interface redact success, new:%+v", vars)
+}
+
+type interfaceLimit struct {
+}
+
+// PUT 修改接口的请求大小限制, 单位KB, 0使用repeater的全局配置.
+func (il *interfaceLimit) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID         int64 `json:"id" valid:"Required"`
+		BodySize   int   `json:"bodySize"`
+		HeaderSize int   `json:"headerSize"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		log.Errorf("invalid req:%+v", r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if vars.BodySize < 0 || vars.HeaderSize < 0 {
+		util.SendResponse(w, http.StatusBadRequest, "invalid bodySize:%d or headerSize:%d", vars.BodySize, vars.HeaderSize)
+		return
+	}
+
+	if err := assertInterface(w, r, vars.ID); err != nil {
+		log.Errorf("interface:%d, vars:%+v, err:%v", vars.ID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	if err := updateInterfaceLimit(vars.ID, vars.BodySize, vars.HeaderSize); err != nil {
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	notify("interface", vars.ID, 0)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("update Interface limit success, new:%+v", vars)
+}
+
 type interfaceRegister struct {
 }
 
@@ -366,6 +528,7 @@ type jsonVariable struct {
 	Parent      string
 	Type        string
 	Required    bool
+	Sensitive   bool
 	Example     string
 	Comment     string
 }
@@ -383,6 +546,7 @@ func (ir *interfaceRegister) addVariable(level int, parent string, db *sql.DB, i
 		vars.Comment = v.Comment
 		vars.Type = v.Type
 		vars.Required = v.Required
+		vars.Sensitive = v.Sensitive
 
 		id, err := orm.NewStmt(db, "variable").Insert(&vars)
 		if err != nil {
@@ -403,8 +567,8 @@ func (ir *interfaceRegister) addVariable(level int, parent string, db *sql.DB, i
 }
 
 const (
-	postionRequestJSON  = 4
-	postionResponseJSON = 14
+	postionRequestJSON  = int(meta.PostionRequestJSON)
+	postionResponseJSON = int(meta.PostionResponseJSON)
 )
 
 func (ir *interfaceRegister) POST(w http.ResponseWriter, r *http.Request) {
@@ -494,6 +658,9 @@ func (ir *interfaceRegister) POST(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//重新注册时接口及字段都可能有变化
+	notify("interface", id, 0)
+	notifyRoutes(vars.ServiceID)
 	server.SendResponseData(w, id)
 	log.Debugf("new interface:%+v, id:%v", vars, id)
 }
@@ -0,0 +1,176 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"dearcode.net/crab/http/server"
+	"dearcode.net/crab/log"
+	"dearcode.net/crab/orm"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util"
+)
+
+type mirror struct {
+}
+
+// GET 查询接口的流量镜像配置.
+func (m *mirror) GET(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		InterfaceID int64 `json:"interfaceID" valid:"Required"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	var mc meta.Mirror
+	if err = orm.NewStmt(db, "mirror").Where("interface_id=%d", vars.InterfaceID).Query(&mc); err != nil {
+		log.Errorf("query mirror:%d error:%s", vars.InterfaceID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	util.SendResponseJSON(w, &mc)
+}
+
+// PUT 设置接口的影子后端及镜像比例, 每个接口只有一条.
+func (m *mirror) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		InterfaceID int64  `json:"interfaceID" valid:"Required"`
+		Target      string `json:"target" valid:"Required"`
+		Percent     int    `json:"percent" valid:"Range(0, 100)"`
+		Diff        bool   `json:"diff"`
+		State       int    `json:"state"`
+		Comment     string `json:"comment"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		log.Errorf("invalid req:%+v", r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if u, err := url.Parse(vars.Target); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		util.SendResponse(w, http.StatusBadRequest, "invalid target:%s, need scheme://host:port", vars.Target)
+		return
+	}
+
+	if err := assertInterface(w, r, vars.InterfaceID); err != nil {
+		log.Errorf("interface:%d, vars:%+v, err:%v", vars.InterfaceID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	sql := "insert into mirror (interface_id, target, percent, diff, state, comment, ctime) values (?,?,?,?,?,?,now()) " +
+		"ON DUPLICATE KEY UPDATE target=values(target), percent=values(percent), diff=values(diff), state=values(state), comment=values(comment)"
+
+	if _, err = db.Exec(sql, vars.InterfaceID, vars.Target, vars.Percent, vars.Diff, vars.State, vars.Comment); err != nil {
+		log.Errorf("update mirror:%+v error:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("mirror", 0, vars.InterfaceID)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("update mirror success, new:%+v", vars)
+}
+
+// DELETE 删除镜像配置.
+func (m *mirror) DELETE(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		InterfaceID int64 `json:"interfaceID" valid:"Required"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := assertInterface(w, r, vars.InterfaceID); err != nil {
+		log.Errorf("interface:%d, vars:%+v, err:%v", vars.InterfaceID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("delete from mirror where interface_id=?", vars.InterfaceID); err != nil {
+		log.Errorf("delete mirror:%d error:%v", vars.InterfaceID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("mirror", 0, vars.InterfaceID)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("delete mirror interface:%d success", vars.InterfaceID)
+}
+
+type mirrorDiff struct {
+}
+
+// GET 查询镜像请求与主后端的对比结果, onlyDiff只返回不一致的.
+func (m *mirrorDiff) GET(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		InterfaceID int64  `json:"interfaceID" valid:"Required"`
+		OnlyDiff    bool   `json:"onlyDiff"`
+		Sort        string `json:"sort"`
+		Order       string `json:"order"`
+		Page        int    `json:"offset"`
+		Size        int    `json:"limit"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	where := fmt.Sprintf("interface_id=%d", vars.InterfaceID)
+	if vars.OnlyDiff {
+		where += " and same=0"
+	}
+
+	if vars.Sort == "" {
+		vars.Sort = "id"
+		vars.Order = "desc"
+	}
+
+	var ds []meta.MirrorDiff
+
+	total, err := query("mirror_diff", where, vars.Sort, vars.Order, vars.Page, vars.Size, &ds)
+	if err != nil {
+		log.Errorf("query mirror_diff:%d error:%s", vars.InterfaceID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	server.SendRows(w, total, ds)
+}
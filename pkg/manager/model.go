@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"dearcode.net/doodle/pkg/util/histogram"
 )
 
 // userinfo erp中用户信息
@@ -26,6 +28,14 @@ type statsSum struct {
 	Avg  int64
 }
 
+type statsVersion struct {
+	Date    string
+	Version string
+	Sum     int64
+	Err     int64
+	Avg     int64
+}
+
 type statsTopApp struct {
 	AppID         int64
 	AppName       string
@@ -38,6 +48,16 @@ type statsTopApp struct {
 	Value         int64
 }
 
+// quotaUsage 应用在一个周期内调用接口的次数, Quota为0表示没有配置配额.
+type quotaUsage struct {
+	Period        string
+	InterfaceID   int64
+	InterfaceName string
+	ServiceName   string
+	Calls         int64
+	Quota         int64
+}
+
 type statsTopIface struct {
 	ID            int64  `json:"id"`
 	ServiceName   string `json:"service"`
@@ -72,10 +92,11 @@ type iface struct {
 	User      string `json:"user"`
 	Email     string `json:"email"`
 	State     int
-	Path      string `json:"path"  valid:"AlphaNumeric"`
+	Path      string `json:"path"`
 	Backend   string `json:"backend"  valid:"Required"`
 	Comment   string `json:"comment"  valid:"Required"`
 	Level     int    `json:"level"`
+	Internal  bool   `json:"internal"`
 	CTime     string `db_default:"now()"`
 	Mtime     string `db_default:"now()"`
 }
@@ -91,3 +112,34 @@ type statsError struct {
 	Info        string
 	Ctime       string
 }
+
+// statsQuantile 百分位耗时, 单位毫秒.
+type statsQuantile struct {
+	Quantile float64
+	Value    float64
+}
+
+// statsLatencyPoint 按时间段合并的耗时分布.
+type statsLatencyPoint struct {
+	Date   string
+	Count  int64
+	Values []float64
+}
+
+type statsLatency struct {
+	Count     int64
+	Quantiles []statsQuantile
+	Buckets   []histogram.Bucket
+	Series    []statsLatencyPoint
+}
+
+// certificateInfo 证书列表, 不包含私钥.
+type certificateInfo struct {
+	ID       int64
+	Domain   string
+	NotAfter string `db:"not_after"`
+	State    int
+	Comment  string
+	Ctime    string
+	Mtime    string
+}
@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"net/http"
+
+	"dearcode.net/crab/log"
+	"dearcode.net/crab/orm"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util"
+)
+
+type backendPolicy struct {
+}
+
+// GET 查询接口的超时及重试策略.
+func (bp *backendPolicy) GET(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		InterfaceID int64 `json:"interfaceID" valid:"Required"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	var p meta.BackendPolicy
+	if err = orm.NewStmt(db, "backend_policy").Where("interface_id=%d", vars.InterfaceID).Query(&p); err != nil {
+		log.Errorf("query backend_policy:%d error:%s", vars.InterfaceID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	util.SendResponseJSON(w, &p)
+}
+
+// PUT 设置接口的超时及重试策略, 每个接口只有一条.
+func (bp *backendPolicy) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		InterfaceID    int64  `json:"interfaceID" valid:"Required"`
+		ConnectTimeout int    `json:"connectTimeout"`
+		Timeout        int    `json:"timeout"`
+		Attempts       int    `json:"attempts" valid:"Range(1, 10)"`
+		RetryOn        string `json:"retryOn"`
+		NonIdempotent  bool   `json:"nonIdempotent"`
+		Backoff        int    `json:"backoff"`
+		MaxBackoff     int    `json:"maxBackoff"`
+		Comment        string `json:"comment"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		log.Errorf("invalid req:%+v", r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if vars.ConnectTimeout < 0 || vars.Timeout < 0 || vars.Backoff < 0 || vars.MaxBackoff < 0 {
+		util.SendResponse(w, http.StatusBadRequest, "timeout and backoff can't be negative")
+		return
+	}
+
+	if err := assertInterface(w, r, vars.InterfaceID); err != nil {
+		log.Errorf("interface:%d, vars:%+v, err:%v", vars.InterfaceID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	sql := "insert into backend_policy (interface_id, connect_timeout, timeout, attempts, retry_on, non_idempotent, backoff, max_backoff, comment, ctime) values (?,?,?,?,?,?,?,?,?,now()) " +
+		"ON DUPLICATE KEY UPDATE connect_timeout=values(connect_timeout), timeout=values(timeout), attempts=values(attempts), retry_on=values(retry_on), " +
+		"non_idempotent=values(non_idempotent), backoff=values(backoff), max_backoff=values(max_backoff), comment=values(comment)"
+
+	if _, err = db.Exec(sql, vars.InterfaceID, vars.ConnectTimeout, vars.Timeout, vars.Attempts, vars.RetryOn, vars.NonIdempotent, vars.Backoff, vars.MaxBackoff, vars.Comment); err != nil {
+		log.Errorf("update backend_policy:%+v error:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("backend_policy", 0, vars.InterfaceID)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("update backend_policy success, new:%+v", vars)
+}
+
+// DELETE 删除策略, 恢复默认超时且不重试.
+func (bp *backendPolicy) DELETE(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		InterfaceID int64 `json:"interfaceID" valid:"Required"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := assertInterface(w, r, vars.InterfaceID); err != nil {
+		log.Errorf("interface:%d, vars:%+v, err:%v", vars.InterfaceID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("delete from backend_policy where interface_id=?", vars.InterfaceID); err != nil {
+		log.Errorf("delete backend_policy:%d error:%v", vars.InterfaceID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("backend_policy", 0, vars.InterfaceID)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("delete backend_policy interface:%d success", vars.InterfaceID)
+}
@@ -0,0 +1,268 @@
+package manager
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dearcode.net/crab/log"
+	"dearcode.net/crab/orm"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util"
+)
+
+const (
+	quotaDateLayout = "2006-01-02"
+)
+
+type quota struct {
+}
+
+// GET 查询应用或接口的调用配额.
+func (q *quota) GET(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		AppID       int64 `json:"applicationID"`
+		InterfaceID int64 `json:"interfaceID"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if vars.AppID == 0 && vars.InterfaceID == 0 {
+		util.SendResponse(w, http.StatusBadRequest, "need applicationID or interfaceID")
+		return
+	}
+
+	//按应用查时需要是应用的负责人, 只按接口查时需要有接口的权限
+	if vars.AppID != 0 {
+		err = assertApp(u, vars.AppID)
+	} else {
+		err = assertInterface(w, r, vars.InterfaceID)
+	}
+	if err != nil {
+		log.Errorf("vars:%+v, err:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	where := "1=1"
+	if vars.AppID != 0 {
+		where += fmt.Sprintf(" and application_id=%d", vars.AppID)
+	}
+	if vars.InterfaceID != 0 {
+		where += fmt.Sprintf(" and interface_id=%d", vars.InterfaceID)
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	qs := []meta.Quota{}
+	if err = orm.NewStmt(db, "quota").Where(where).Query(&qs); err != nil && !orm.IsNotFound(err) {
+		log.Errorf("query quota:%+v error:%s", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response(w, QueryResponse{Total: len(qs), Rows: qs})
+}
+
+// POST 设置应用调用接口的配额, 同一应用及接口每个周期只有一条.
+func (q *quota) POST(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		AppID       int64  `json:"applicationID" valid:"Required"`
+		InterfaceID int64  `json:"interfaceID" valid:"Required"`
+		Period      string `json:"period" valid:"Required"`
+		Calls       int64  `json:"calls" valid:"Min(1)"`
+		Comment     string `json:"comment"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		log.Errorf("invalid req:%+v", r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if vars.Period != meta.QuotaDay && vars.Period != meta.QuotaMonth {
+		util.SendResponse(w, http.StatusBadRequest, "invalid period:%s, need day or month", vars.Period)
+		return
+	}
+
+	if err := assertInterface(w, r, vars.InterfaceID); err != nil {
+		log.Errorf("interface:%d, vars:%+v, err:%v", vars.InterfaceID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	sql := "insert into quota (application_id, interface_id, period, calls, comment, ctime) values (?,?,?,?,?,now()) " +
+		"ON DUPLICATE KEY UPDATE calls=values(calls), comment=values(comment)"
+
+	if _, err = db.Exec(sql, vars.AppID, vars.InterfaceID, vars.Period, vars.Calls, vars.Comment); err != nil {
+		log.Errorf("update quota:%+v error:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("quota", 0, vars.AppID)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("update quota success, new:%+v", vars)
+}
+
+// DELETE 删除配额及已经使用的计数.
+func (q *quota) DELETE(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID int64 `json:"id" valid:"Required"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	var qt meta.Quota
+	if err = orm.NewStmt(db, "quota").Where("id=%d", vars.ID).Query(&qt); err != nil {
+		log.Errorf("query quota:%d error:%s", vars.ID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if err = assertInterface(w, r, qt.InterfaceID); err != nil {
+		log.Errorf("interface:%d, quota:%d, err:%v", qt.InterfaceID, vars.ID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	if _, err = db.Exec("delete from quota where id=?", vars.ID); err != nil {
+		log.Errorf("delete quota:%d error:%v", vars.ID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if _, err = db.Exec("delete from quota_usage where quota_id=?", vars.ID); err != nil {
+		log.Errorf("delete quota_usage:%d error:%v", vars.ID, errors.ErrorStack(err))
+	}
+
+	notify("quota", vars.ID, qt.ApplicationID)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("delete quota:%+v success", qt)
+}
+
+type quotaReport struct {
+}
+
+// GET 按天或按月统计应用调用每个接口的次数及配额, 数据来自stats表, format为csv时导出文件.
+func (qr *quotaReport) GET(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		AppID  int64  `json:"applicationID" valid:"Required"`
+		Period string `json:"period"`
+		Begin  string `json:"begin"`
+		End    string `json:"end"`
+		Format string `json:"format"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = assertApp(u, vars.AppID); err != nil {
+		log.Errorf("app:%d, vars:%+v, err:%v", vars.AppID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	if vars.Period == "" {
+		vars.Period = meta.QuotaDay
+	}
+	if vars.Period != meta.QuotaDay && vars.Period != meta.QuotaMonth {
+		util.SendResponse(w, http.StatusBadRequest, "invalid period:%s, need day or month", vars.Period)
+		return
+	}
+
+	//默认最近30天
+	now := time.Now()
+	if vars.End == "" {
+		vars.End = now.Format(quotaDateLayout)
+	}
+	if vars.Begin == "" {
+		vars.Begin = now.AddDate(0, 0, -30).Format(quotaDateLayout)
+	}
+
+	end, err := time.ParseInLocation(quotaDateLayout, vars.End, time.Local)
+	if err != nil {
+		util.SendResponse(w, http.StatusBadRequest, "invalid end:%s, layout:%s", vars.End, quotaDateLayout)
+		return
+	}
+	if _, err = time.ParseInLocation(quotaDateLayout, vars.Begin, time.Local); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, "invalid begin:%s, layout:%s", vars.Begin, quotaDateLayout)
+		return
+	}
+
+	us, err := selectQuotaUsage(vars.AppID, vars.Period, vars.Begin, end.AddDate(0, 0, 1).Format(quotaDateLayout))
+	if err != nil {
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		log.Errorf("selectQuotaUsage error:%v", errors.ErrorStack(err))
+		return
+	}
+
+	if vars.Format != "csv" {
+		response(w, QueryResponse{Total: len(us), Rows: us})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=quota_%d_%s_%s.csv", vars.AppID, vars.Begin, vars.End))
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"period", "interface_id", "interface", "service", "calls", "quota"})
+	for _, u := range us {
+		cw.Write([]string{u.Period, strconv.FormatInt(u.InterfaceID, 10), u.InterfaceName, u.ServiceName, strconv.FormatInt(u.Calls, 10), strconv.FormatInt(u.Quota, 10)})
+	}
+	cw.Flush()
+
+	if err = cw.Error(); err != nil {
+		log.Errorf("write csv error:%v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQuotaNeedLogin(t *testing.T) {
+	cases := []struct {
+		url string
+		fn  func(http.ResponseWriter, *http.Request)
+	}{
+		{"/quota/?applicationID=1", (&quota{}).GET},
+		{"/quota/?interfaceID=1", (&quota{}).GET},
+		{"/quota/report/?applicationID=1", (&quotaReport{}).GET},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		c.fn(w, httptest.NewRequest(http.MethodGet, c.url, nil))
+
+		resp := struct{ Status int }{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Status != http.StatusBadRequest {
+			t.Fatalf("%s without session expect:%d, got:%s", c.url, http.StatusBadRequest, w.Body.String())
+		}
+	}
+}
@@ -104,6 +104,8 @@ func (ra *relation) PUT(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	notify("relation", vars.ID, 0)
+
 	util.SendResponse(w, 0, "")
 
 	log.Debugf("update relation success, vars:%+v", vars)
@@ -124,6 +126,7 @@ func (ra *relation) DELETE(w http.ResponseWriter, r *http.Request) {
 		util.SendResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	notify("relation", vars.ID, 0)
 	util.SendResponse(w, 0, "")
 
 	log.Debugf("delete service:%v, success", vars.ID)
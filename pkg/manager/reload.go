@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"sync"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/manager/config"
+	"dearcode.net/doodle/pkg/util/rbac"
+	"dearcode.net/doodle/pkg/util/reload"
+)
+
+var (
+	// hotFields 不需要重启就能生效的配置, 数据库每次使用时重新连接, 直接修改即可.
+	hotFields = []string{"DB", "ETCD", "RBAC", "SSO", "Token", "Log", "Server.Domain", "Server.WebPath"}
+
+	reloaders = map[string]func(){
+		"ETCD": func() {
+			eventMu.Lock()
+			defer eventMu.Unlock()
+			if eventClient != nil {
+				eventClient.SetEndpoints(config.Manager.ETCD.Hosts)
+			}
+		},
+		"RBAC": func() { rbacClient = rbac.New(config.Manager.RBAC.Host, config.Manager.RBAC.Token) },
+		"Log":  setLogLevel,
+	}
+
+	reloadMu sync.Mutex
+)
+
+// setLogLevel 配置了日志级别时使用它, 否则保持启动时的级别.
+func setLogLevel() {
+	if l := config.Manager.Log.Level; l != "none" {
+		log.SetLevelByString(l)
+	}
+}
+
+// Reload 重新读取配置文件, 替换可以在线修改的配置, 返回生效及需要重启的字段.
+func Reload() (reload.Report, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	nc, err := config.Parse()
+	if err != nil {
+		return reload.Report{}, errors.Trace(err)
+	}
+
+	r := reload.Apply(&config.Manager, nc, hotFields)
+	for _, s := range r.Sections() {
+		if fn, ok := reloaders[s]; ok {
+			fn()
+		}
+	}
+
+	for _, f := range r.Applied {
+		log.Infof("reload config %s applied", f)
+	}
+	for _, f := range r.Restart {
+		log.Warningf("reload config %s changed, need restart", f)
+	}
+
+	return r, nil
+}
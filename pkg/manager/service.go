@@ -115,6 +115,7 @@ func (p *service) DELETE(w http.ResponseWriter, r *http.Request) {
 		util.SendResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	notify("service", vars.ID, 0)
 	util.SendResponse(w, 0, "")
 
 	log.Debugf("delete service:%v, success", vars.ID)
@@ -252,6 +253,7 @@ func (p *service) PUT(w http.ResponseWriter, r *http.Request) {
 		util.SendResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	notify("service", vars.ID, 0)
 	util.SendResponse(w, 0, "")
 
 	log.Debugf("update service success:%v, new:%+v", ra, vars)
@@ -260,3 +262,28 @@ func (p *service) PUT(w http.ResponseWriter, r *http.Request) {
 func getServiceResourceID(serviceID int64) (int64, error) {
 	return getResourceID("service", serviceID)
 }
+
+// assertService 验证当前用户是否有服务的权限.
+func assertService(w http.ResponseWriter, r *http.Request, serviceID int64) error {
+	u, err := session.User(w, r)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	resID, err := getServiceResourceID(serviceID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return u.assert(resID)
+}
+
+// assertInterface 验证当前用户是否有接口所在服务的权限.
+func assertInterface(w http.ResponseWriter, r *http.Request, ifaceID int64) error {
+	sid, err := getInterfaceServiceID(ifaceID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return assertService(w, r, sid)
+}
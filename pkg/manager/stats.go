@@ -3,11 +3,13 @@ package manager
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"dearcode.net/crab/log"
 	"github.com/juju/errors"
 
 	"dearcode.net/doodle/pkg/util"
+	"dearcode.net/doodle/pkg/util/histogram"
 )
 
 type statsSumAction struct {
@@ -32,6 +34,28 @@ func (ssa *statsSumAction) GET(w http.ResponseWriter, r *http.Request) {
 	response(w, ss)
 }
 
+type statsVersionAction struct {
+	ID int64 `json:"interfaceID" valid:"Required"`
+}
+
+// GET 查询接口按后端版本的调用统计, 用来对比灰度版本.
+func (sva *statsVersionAction) GET(w http.ResponseWriter, r *http.Request) {
+	if err := util.DecodeRequestValue(r, sva); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	svs, err := selectVersionStats(sva.ID)
+	if err != nil {
+		util.SendResponse(w, http.StatusNotFound, "not found")
+		log.Errorf("version stats not found, error:%v", errors.ErrorStack(err))
+		return
+	}
+
+	log.Debugf("result:%v", svs)
+	response(w, QueryResponse{Total: len(svs), Rows: svs})
+}
+
 type statsTopInterface struct {
 }
 
@@ -100,3 +124,116 @@ func (se *statsErrors) GET(w http.ResponseWriter, r *http.Request) {
 	log.Debugf("result:%v", errs)
 	response(w, QueryResponse{Total: total, Rows: errs})
 }
+
+const (
+	statsTimeLayout = "2006-01-02 15:04"
+)
+
+type statsLatencyAction struct {
+	ID        int64  `json:"interfaceID" valid:"Required"`
+	AppID     int64  `json:"appID"`
+	Begin     string `json:"begin"`
+	End       string `json:"end"`
+	Quantiles string `json:"quantiles"`
+	Step      int    `json:"step"`
+}
+
+// GET 查询接口在任意时间范围内的耗时百分位及分布, step为曲线的时间间隔(分钟), 默认最近一小时.
+func (sla *statsLatencyAction) GET(w http.ResponseWriter, r *http.Request) {
+	if err := util.DecodeRequestValue(r, sla); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now()
+	if sla.End == "" {
+		sla.End = now.Format(statsTimeLayout)
+	}
+	if sla.Begin == "" {
+		sla.Begin = now.Add(-time.Hour).Format(statsTimeLayout)
+	}
+	if sla.Quantiles == "" {
+		sla.Quantiles = "50,95,99"
+	}
+	if sla.Step <= 0 {
+		sla.Step = 1
+	}
+
+	for _, s := range []string{sla.Begin, sla.End} {
+		if _, err := time.ParseInLocation(statsTimeLayout, s, time.Local); err != nil {
+			util.SendResponse(w, http.StatusBadRequest, "invalid time:%s, layout:%s", s, statsTimeLayout)
+			return
+		}
+	}
+
+	qs, err := histogram.ParseQuantiles(sla.Quantiles)
+	if err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lrs, err := selectLatencyStats(sla.ID, sla.AppID, sla.Begin, sla.End)
+	if err != nil {
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		log.Errorf("selectLatencyStats error:%v", errors.ErrorStack(err))
+		return
+	}
+
+	sl, err := mergeLatency(lrs, qs, time.Duration(sla.Step)*time.Minute)
+	if err != nil {
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		log.Errorf("mergeLatency error:%v", errors.ErrorStack(err))
+		return
+	}
+
+	log.Debugf("latency stats:%+v", sl)
+	response(w, sl)
+}
+
+// mergeLatency 合并所有行计算总体分位数, 同时按step合并出每段的分位数曲线.
+func mergeLatency(lrs []latencyRow, qs []float64, step time.Duration) (*statsLatency, error) {
+	total := histogram.New()
+	sl := &statsLatency{Series: []statsLatencyPoint{}}
+
+	var cur *histogram.Histogram
+	var date string
+
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		p := statsLatencyPoint{Date: date, Count: cur.Count()}
+		for _, q := range qs {
+			p.Values = append(p.Values, cur.Quantile(q))
+		}
+		sl.Series = append(sl.Series, p)
+	}
+
+	for _, lr := range lrs {
+		h, err := histogram.Parse(lr.buckets)
+		if err != nil {
+			return nil, errors.Annotatef(err, "event_time:%s", lr.date)
+		}
+		total.Merge(h)
+
+		t, err := time.ParseInLocation(statsTimeLayout, lr.date, time.Local)
+		if err != nil {
+			return nil, errors.Annotatef(err, "event_time:%s", lr.date)
+		}
+
+		if d := t.Truncate(step).Format(statsTimeLayout); d != date {
+			flush()
+			cur, date = histogram.New(), d
+		}
+		cur.Merge(h)
+	}
+	flush()
+
+	sl.Count = total.Count()
+	sl.Buckets = total.Buckets()
+	for _, q := range qs {
+		sl.Quantiles = append(sl.Quantiles, statsQuantile{Quantile: q * 100, Value: total.Quantile(q)})
+	}
+
+	return sl, nil
+}
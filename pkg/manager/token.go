@@ -0,0 +1,385 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"dearcode.net/crab/http/server"
+	"dearcode.net/crab/log"
+	"dearcode.net/crab/orm"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/manager/config"
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util"
+	"dearcode.net/doodle/pkg/util/token"
+)
+
+const (
+	tokenTimeLayout = "2006-01-02 15:04:05"
+)
+
+// assertApp 只有管理员及应用的负责人可以管理应用的token.
+func assertApp(u *userinfo, appID int64) error {
+	if u.IsAdmin {
+		return nil
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer db.Close()
+
+	var a meta.Application
+	if err = orm.NewStmt(db, "application").Where("id=%d", appID).Query(&a); err != nil {
+		return errors.Trace(err)
+	}
+
+	if a.Email != u.Email {
+		log.Errorf("account:%+v, application:%d email:%s", *u, appID, a.Email)
+		return fmt.Errorf("you don't have permission to access")
+	}
+
+	return nil
+}
+
+type tokenAction struct {
+}
+
+// GET 查询应用签发过的token.
+func (t *tokenAction) GET(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		AppID int64 `json:"appID" valid:"Required"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = assertApp(u, vars.AppID); err != nil {
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	var ts []meta.AppToken
+
+	total, err := query("app_token", fmt.Sprintf("application_id=%d", vars.AppID), "id", "desc", 0, 0, &ts)
+	if err != nil {
+		log.Errorf("query app_token:%d error:%s", vars.AppID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	server.SendRows(w, total, ts)
+}
+
+// POST 使用最新的密钥给应用签发token, token只返回这一次.
+func (t *tokenAction) POST(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		AppID   int64  `json:"appID" valid:"Required"`
+		Expire  int64  `json:"expire"`
+		Scopes  string `json:"scopes"`
+		Comment string `json:"comment"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = assertApp(u, vars.AppID); err != nil {
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	if vars.Expire <= 0 {
+		vars.Expire = int64(config.Manager.Token.Expire)
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	var k meta.TokenKey
+	if err = orm.NewStmt(db, "token_key").Where("state=%d", meta.TokenKeyActive).Sort("id").Order("desc").Limit(1).Query(&k); err != nil {
+		log.Errorf("query active token key error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, "没有可用的签名密钥")
+		return
+	}
+
+	now := time.Now()
+	c := token.Claims{
+		AppID:     vars.AppID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Unix() + vars.Expire,
+		ID:        token.NewID(),
+	}
+
+	for _, s := range strings.Split(vars.Scopes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			c.Scopes = append(c.Scopes, s)
+		}
+	}
+
+	tk, err := token.Sign(k.Kid, k.Secret, c)
+	if err != nil {
+		log.Errorf("sign token:%+v error:%v", c, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	at := struct {
+		ApplicationID int64 `db:"application_id"`
+		Jti           string
+		Kid           string
+		Scopes        string
+		Expire        string
+		Comment       string
+		Ctime         string `db_default:"now()"`
+	}{
+		ApplicationID: vars.AppID,
+		Jti:           c.ID,
+		Kid:           k.Kid,
+		Scopes:        strings.Join(c.Scopes, ","),
+		Expire:        time.Unix(c.ExpiresAt, 0).Format(tokenTimeLayout),
+		Comment:       vars.Comment,
+	}
+
+	id, err := orm.NewStmt(db, "app_token").Insert(&at)
+	if err != nil {
+		log.Errorf("insert app_token:%+v error:%v", at, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	util.SendResponse(w, 0, `{"id":%d, "token":"%s"}`, id, tk)
+
+	log.Debugf("%s sign token for app:%d, id:%d, jti:%s, kid:%s", u.Email, vars.AppID, id, c.ID, k.Kid)
+}
+
+// DELETE 吊销token, 加入吊销列表后repeater立即拒绝.
+func (t *tokenAction) DELETE(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID    int64 `json:"id" valid:"Required"`
+		AppID int64 `json:"appID" valid:"Required"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = assertApp(u, vars.AppID); err != nil {
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	if _, err = orm.NewStmt(db, "app_token").Exec("update app_token set revoked=1 where id=? and application_id=?", vars.ID, vars.AppID); err != nil {
+		log.Errorf("revoke app_token:%+v error:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("app_token", vars.ID, vars.AppID)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("%s revoke token:%d, app:%d", u.Email, vars.ID, vars.AppID)
+}
+
+type tokenRevoke struct {
+}
+
+// GET 吊销列表, 只返回还没过期的.
+func (t *tokenRevoke) GET(w http.ResponseWriter, r *http.Request) {
+	if _, err := session.User(w, r); err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var ts []meta.AppToken
+
+	total, err := query("app_token", "revoked=1 and expire > now()", "id", "desc", 0, 0, &ts)
+	if err != nil {
+		log.Errorf("query revoked app_token error:%s", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	server.SendRows(w, total, ts)
+}
+
+type tokenKey struct {
+}
+
+// GET 查询签名密钥, 不返回密钥内容.
+func (t *tokenKey) GET(w http.ResponseWriter, r *http.Request) {
+	if _, err := session.User(w, r); err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var ks []meta.TokenKey
+
+	total, err := query("token_key", "", "id", "desc", 0, 0, &ks)
+	if err != nil {
+		log.Errorf("query token_key error:%s", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	server.SendRows(w, total, ks)
+}
+
+// POST 生成新的签名密钥, 之前的签名密钥改为只验证, 用于密钥轮换.
+func (t *tokenKey) POST(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		Kid     string `json:"kid" valid:"Required;AlphaNumeric"`
+		Comment string `json:"comment"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !u.IsAdmin {
+		util.SendResponse(w, http.StatusForbidden, "只有管理员可以管理签名密钥")
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	k := struct {
+		Kid     string
+		Secret  string
+		State   int
+		Comment string
+		Ctime   string `db_default:"now()"`
+		Mtime   string `db_default:"now()"`
+	}{
+		Kid:     vars.Kid,
+		Secret:  token.NewSecret(),
+		State:   int(meta.TokenKeyActive),
+		Comment: vars.Comment,
+	}
+
+	if _, err = orm.NewStmt(db, "token_key").Exec("update token_key set state=? where state=?", meta.TokenKeyVerify, meta.TokenKeyActive); err != nil {
+		log.Errorf("rotate token_key error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := orm.NewStmt(db, "token_key").Insert(&k)
+	if err != nil {
+		if strings.Contains(err.Error(), "1062") {
+			util.SendResponse(w, http.StatusInternalServerError, "kid已存在")
+			return
+		}
+		log.Errorf("insert token_key:%s error:%v", k.Kid, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("token_key", id, 0)
+	util.SendResponse(w, 0, `{"id":%d}`, id)
+
+	log.Debugf("%s add token key:%s, id:%d", u.Email, k.Kid, id)
+}
+
+// PUT 修改签名密钥状态, 停用后用它签发的token全部失效.
+func (t *tokenKey) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID      int64  `json:"id" valid:"Required" db_auto:""`
+		State   int    `json:"state" valid:"Range(0, 2)"`
+		Comment string `json:"comment"`
+		Mtime   string `db_const:"now()"`
+	}{}
+
+	u, err := session.User(w, r)
+	if err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !u.IsAdmin {
+		util.SendResponse(w, http.StatusForbidden, "只有管理员可以管理签名密钥")
+		return
+	}
+
+	if err = util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	if _, err = orm.NewStmt(db, "token_key").Where("id=%d", vars.ID).Update(&vars); err != nil {
+		log.Errorf("update token_key:%+v error:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("token_key", vars.ID, 0)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("%s update token key:%+v", u.Email, vars)
+}
@@ -0,0 +1,217 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+
+	"dearcode.net/crab/http/server"
+	"dearcode.net/crab/log"
+	"dearcode.net/crab/orm"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util"
+)
+
+type transform struct {
+}
+
+// GET 查询接口的转换规则.
+func (t *transform) GET(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		InterfaceID int64 `json:"interfaceID" valid:"Required"`
+	}{}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var ts []meta.Transform
+
+	total, err := query("transform", fmt.Sprintf("interface_id=%d", vars.InterfaceID), "id", "asc", 0, 0, &ts)
+	if err != nil {
+		log.Errorf("query transform:%d error:%s", vars.InterfaceID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	server.SendRows(w, total, ts)
+}
+
+// POST 添加转换规则.
+func (t *transform) POST(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		InterfaceID int64  `json:"interfaceID" valid:"Required"`
+		Target      int    `json:"target" valid:"Range(0, 4)"`
+		Action      int    `json:"action" valid:"Range(0, 3)"`
+		Name        string `json:"name"`
+		Value       string `json:"value"`
+		Comment     string `json:"comment"`
+		Ctime       string `db_default:"now()"`
+		Mtime       string `db_default:"now()"`
+	}{}
+
+	if _, err := session.User(w, r); err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		log.Errorf("invalid req:%+v", r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := checkTransform(vars.Target, vars.Action, vars.Name); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := assertInterface(w, r, vars.InterfaceID); err != nil {
+		log.Errorf("interface:%d, vars:%+v, err:%v", vars.InterfaceID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	id, err := orm.NewStmt(db, "transform").Insert(&vars)
+	if err != nil {
+		log.Errorf("insert transform:%+v error:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("transform", id, vars.InterfaceID)
+	util.SendResponse(w, 0, `{"id":%d}`, id)
+
+	log.Debugf("add transform success, id:%v, %+v", id, vars)
+}
+
+// PUT 修改转换规则.
+func (t *transform) PUT(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID      int64  `json:"id" valid:"Required" db_auto:""`
+		Target  int    `json:"target" valid:"Range(0, 4)"`
+		Action  int    `json:"action" valid:"Range(0, 3)"`
+		Name    string `json:"name"`
+		Value   string `json:"value"`
+		Comment string `json:"comment"`
+		Mtime   string `db_const:"now()"`
+	}{}
+
+	if _, err := session.User(w, r); err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		log.Errorf("invalid req:%+v", r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := checkTransform(vars.Target, vars.Action, vars.Name); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := assertTransform(w, r, vars.ID); err != nil {
+		log.Errorf("transform:%d, vars:%+v, err:%v", vars.ID, vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	db, err := mdb.GetConnection()
+	if err != nil {
+		log.Errorf("GetConnection error:%v", errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer db.Close()
+
+	if _, err = orm.NewStmt(db, "transform").Where("id=%d", vars.ID).Update(&vars); err != nil {
+		log.Errorf("update transform:%+v error:%v", vars, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	notify("transform", vars.ID, 0)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("update transform success, new:%+v", vars)
+}
+
+// DELETE 删除转换规则.
+func (t *transform) DELETE(w http.ResponseWriter, r *http.Request) {
+	vars := struct {
+		ID int64 `json:"id" valid:"Required"`
+	}{}
+
+	if _, err := session.User(w, r); err != nil {
+		log.Errorf("session.User error:%v, req:%v", errors.ErrorStack(err), r)
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := util.DecodeRequestValue(r, &vars); err != nil {
+		util.SendResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := assertTransform(w, r, vars.ID); err != nil {
+		log.Errorf("transform:%d, err:%v", vars.ID, errors.ErrorStack(err))
+		util.SendResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	if err := del("transform", vars.ID); err != nil {
+		util.SendResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	notify("transform", vars.ID, 0)
+	util.SendResponse(w, 0, "")
+
+	log.Debugf("delete transform:%v, success", vars.ID)
+}
+
+// checkTransform 检查规则的位置与动作, 后端路径只能设置, 其它位置需要名字.
+func checkTransform(target, action int, name string) error {
+	if meta.TransformTarget(target) == meta.TransformPath {
+		if meta.TransformAction(action) != meta.TransformSet {
+			return errors.New("path only support set")
+		}
+		return nil
+	}
+
+	if name == "" {
+		return errors.New("name can not be empty")
+	}
+
+	return nil
+}
+
+// assertTransform 验证当前用户是否有规则所在接口的权限.
+func assertTransform(w http.ResponseWriter, r *http.Request, id int64) error {
+	db, err := mdb.GetConnection()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer db.Close()
+
+	var ifaceID int64
+	if err = db.QueryRow("select interface_id from transform where id=?", id).Scan(&ifaceID); err != nil {
+		return errors.Annotatef(err, "transform:%d", id)
+	}
+
+	return assertInterface(w, r, ifaceID)
+}
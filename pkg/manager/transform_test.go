@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+func TestCheckTransform(t *testing.T) {
+	cases := []struct {
+		target meta.TransformTarget
+		action meta.TransformAction
+		name   string
+		ok     bool
+	}{
+		{meta.TransformHeader, meta.TransformAdd, "X-App", true},
+		{meta.TransformHeader, meta.TransformSet, "", false},
+		{meta.TransformQuery, meta.TransformRename, "q", true},
+		{meta.TransformPath, meta.TransformSet, "", true},
+		{meta.TransformPath, meta.TransformAdd, "", false},
+		{meta.TransformPath, meta.TransformRemove, "p", false},
+	}
+
+	for _, c := range cases {
+		if err := checkTransform(int(c.target), int(c.action), c.name); (err == nil) != c.ok {
+			t.Fatalf("target:%d action:%d name:%s expect:%v, err:%v", c.target, c.action, c.name, c.ok, err)
+		}
+	}
+}
+
+func TestTransformNeedLogin(t *testing.T) {
+	tf := &transform{}
+
+	cases := []struct {
+		method string
+		body   string
+		fn     func(http.ResponseWriter, *http.Request)
+	}{
+		{http.MethodPost, "interfaceID=1&target=0&action=0&name=X-App", tf.POST},
+		{http.MethodPut, "id=1&target=0&action=1&name=X-App", tf.PUT},
+		{http.MethodDelete, "id=1", tf.DELETE},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/transform/", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		c.fn(w, req)
+
+		resp := struct{ Status int }{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Status != http.StatusBadRequest {
+			t.Fatalf("%s without session expect:%d, got:%s", c.method, http.StatusBadRequest, w.Body.String())
+		}
+	}
+}
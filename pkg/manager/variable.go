@@ -149,6 +149,7 @@ func (v *variable) DELETE(w http.ResponseWriter, r *http.Request) {
 		util.SendResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	notify("variable", vars.ID, 0)
 	util.SendResponse(w, 0, "")
 
 	log.Debugf("delete Variable:%v, success", vars.ID)
@@ -178,6 +179,7 @@ func (v *variable) POST(w http.ResponseWriter, r *http.Request) {
 		util.SendResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	notify("variable", id, vars.InterfaceID)
 	util.SendResponse(w, 0, `{"id":%d}`, id)
 
 	log.Debugf("add Variable success, id:%v", id)
@@ -204,6 +206,7 @@ func (v *variable) PUT(w http.ResponseWriter, r *http.Request) {
 		util.SendResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	notify("variable", vars.ID, 0)
 	util.SendResponse(w, 0, "")
 
 	log.Debugf("update Variable success, new:%+v", vars)
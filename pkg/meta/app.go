@@ -3,6 +3,7 @@ package meta
 import (
 	"encoding/json"
 	"strconv"
+	"strings"
 )
 
 // MicroAPP 一个函数式应用.
@@ -35,6 +36,14 @@ func (m *MicroAPP) Version() int64 {
 	return v
 }
 
+// Match 判断实例是否为指定版本, version可以是GitHash的前缀或者GitTime.
+func (m *MicroAPP) Match(version string) bool {
+	if version == "" {
+		return false
+	}
+	return m.GitTime == version || strings.HasPrefix(m.GitHash, version)
+}
+
 func (m *MicroAPP) String() string {
 	b, _ := json.Marshal(m)
 	return string(b)
@@ -2,18 +2,50 @@ package meta
 
 import (
 	"dearcode.net/crab/http/server"
+
+	"dearcode.net/doodle/pkg/util/cidr"
 )
 
+// AuthMode 应用调用网关的认证方式.
+type AuthMode int
+
+const (
+	// AuthToken 请求头中带Token.
+	AuthToken AuthMode = iota
+	// AuthSignature 使用应用密钥对请求签名.
+	AuthSignature
+	// AuthAny 两种方式都可以.
+	AuthAny
+)
+
+// AllowToken 是否允许使用Token.
+func (m AuthMode) AllowToken() bool {
+	return m != AuthSignature
+}
+
+// AllowSignature 是否允许使用签名.
+func (m AuthMode) AllowSignature() bool {
+	return m != AuthToken
+}
+
 // Application 对应应用表.
 type Application struct {
-	ID      int64
-	Name    string
-	User    string
-	Email   string
-	Token   string
-	Comment string
-	Ctime   string
-	Mtime   string
+	ID       int64
+	Name     string
+	User     string
+	Email    string
+	Token    string
+	Secret   string   `json:"-"`
+	AuthMode AuthMode `db:"auth_mode"`
+	// AllowCIDR 允许调用的来源网段, 逗号分隔, 为空不限制.
+	AllowCIDR string `db:"allow_cidr"`
+	// AllowNets 解析后的AllowCIDR, 网关加载应用时生成, 不用每次请求都解析.
+	AllowNets cidr.List `json:"-"`
+	// CertFingerprint 客户端证书的sha256指纹, 双向TLS时用证书识别应用.
+	CertFingerprint string `db:"cert_fingerprint"`
+	Comment         string
+	Ctime           string
+	Mtime           string
 }
 
 // Relation 关联关系结构.
@@ -51,20 +83,35 @@ type Service struct {
 	MTime      string `json:"mtime" db:"mtime" db_default:"now()"`
 }
 
-// Variable 接口参数
+const (
+	// PostionRequestJSON 注册接口时请求body中的json字段.
+	PostionRequestJSON server.VariablePostion = 4
+	// PostionResponseJSON 注册接口时返回body中的json字段.
+	PostionResponseJSON server.VariablePostion = 14
+	// PostionPath 接口路径模式中{name}捕获的参数.
+	PostionPath server.VariablePostion = 5
+)
+
+// Variable 接口参数, json字段通过Level及Parent(父字段类型)组成树.
 type Variable struct {
 	ID       int64
 	Postion  server.VariablePostion
 	Name     string
 	Type     string
 	Level    int
+	Parent   string
 	Required bool
-	Example  string
-	Comment  string
-	Ctime    string
-	Mtime    string
+	// Sensitive 敏感字段, 日志中脱敏.
+	Sensitive bool
+	Example   string
+	Comment   string
+	Ctime     string
+	Mtime     string
 }
 
+// MethodGRPC gRPC接口, 路径为/package.Service/Method, 服务路径对应package.Service, 接口路径对应Method.
+const MethodGRPC server.Method = server.RESTful + 1
+
 // Interface 接口信息
 type Interface struct {
 	ID      int64
@@ -78,8 +125,40 @@ type Interface struct {
 	Backend string
 	Comment string
 	Level   int8
-	Ctime   string
-	Mtime   string
+	// Internal 只允许内网调用.
+	Internal bool
+	// Mock 不调用后端, 根据返回值字段生成结果.
+	Mock bool
+	// MockLatency 模拟延迟, 单位毫秒.
+	MockLatency int `db:"mock_latency"`
+	// MockErrorRate 模拟出错的百分比.
+	MockErrorRate int `db:"mock_error_rate"`
+	// RedactFields 日志中需要脱敏的字段名或json路径, 逗号分隔.
+	RedactFields string `db:"redact_fields"`
+	// RedactHeaders 日志中需要脱敏的头, 逗号分隔.
+	RedactHeaders string `db:"redact_headers"`
+	// LogMaxSize 日志中body的最大长度, 0使用默认值.
+	LogMaxSize int `db:"log_max_size"`
+	// MaxBodySize 请求body的最大长度, 单位KB, 0使用全局配置.
+	MaxBodySize int `db:"max_body_size"`
+	// MaxHeaderSize 请求头的最大长度, 单位KB, 0使用全局配置.
+	MaxHeaderSize int `db:"max_header_size"`
+	Ctime         string
+	Mtime         string
+	// Params 按路径模式匹配时捕获的参数, 精确匹配时为nil.
+	Params []PathParam
+}
+
+// PathParam 接口路径模式中捕获的参数.
+type PathParam struct {
+	Name  string
+	Value string
+}
+
+// Route 接口的路径模式, 如/orders/{id}, /static/*, ~开头的是正则.
+type Route struct {
+	ID   int64
+	Path string
 }
 
 // TokenBody token结构.
@@ -95,3 +174,217 @@ type Response struct {
 	Message string      `json:",omitempty"`
 	Data    interface{} `json:",omitempty"`
 }
+
+// TransformTarget 转换规则作用的位置.
+type TransformTarget int
+
+const (
+	// TransformHeader 请求头.
+	TransformHeader TransformTarget = iota
+	// TransformQuery url参数.
+	TransformQuery
+	// TransformForm form表单参数.
+	TransformForm
+	// TransformPath 后端请求路径, 只支持TransformSet.
+	TransformPath
+	// TransformResponseHeader 返回头.
+	TransformResponseHeader
+)
+
+// TransformAction 转换动作.
+type TransformAction int
+
+const (
+	// TransformAdd 添加, 已存在时追加一个值.
+	TransformAdd TransformAction = iota
+	// TransformSet 设置, 覆盖原有值.
+	TransformSet
+	// TransformRemove 删除.
+	TransformRemove
+	// TransformRename 改名, Value为新名字.
+	TransformRename
+)
+
+// Transform 接口转发时对请求及返回的转换规则, Value支持{app.id}这种模板变量.
+type Transform struct {
+	ID          int64
+	InterfaceID int64 `db:"interface_id"`
+	Target      TransformTarget
+	Action      TransformAction
+	Name        string
+	Value       string
+	Comment     string
+	Ctime       string
+	Mtime       string
+}
+
+// Canary 服务的灰度发布策略, 命中策略的请求转发到指定版本的实例上.
+type Canary struct {
+	ID          int64
+	ServiceID   int64 `db:"service_id"`
+	Version     string
+	Percent     int
+	AppIDs      string `db:"app_ids"`
+	HeaderName  string `db:"header_name"`
+	HeaderValue string `db:"header_value"`
+	State       int
+	Comment     string
+	Ctime       string
+	Mtime       string
+}
+
+// BackendPolicy 接口调用后端的超时及重试策略, 时间单位都是毫秒.
+type BackendPolicy struct {
+	ID             int64
+	InterfaceID    int64 `db:"interface_id"`
+	ConnectTimeout int   `db:"connect_timeout"`
+	Timeout        int
+	// Attempts 总的尝试次数, 1表示不重试.
+	Attempts int
+	// RetryOn 可以重试的错误, 逗号分隔, 支持error, timeout, 5xx及具体的状态码.
+	RetryOn string `db:"retry_on"`
+	// NonIdempotent 非幂等的方法(POST)也重试.
+	NonIdempotent bool `db:"non_idempotent"`
+	// Backoff 第一次重试前的等待时间, 之后每次翻倍, 不超过MaxBackoff.
+	Backoff    int
+	MaxBackoff int `db:"max_backoff"`
+	Comment    string
+	Ctime      string
+	Mtime      string
+}
+
+// Mirror 接口的流量镜像, 按比例复制请求到影子后端, 不影响调用方.
+type Mirror struct {
+	ID          int64
+	InterfaceID int64 `db:"interface_id"`
+	// Target 影子后端地址, scheme://host:port, 路径及参数与后端请求一致.
+	Target  string
+	Percent int
+	// Diff 记录与主后端返回结果的差异.
+	Diff    bool
+	State   int
+	Comment string
+	Ctime   string
+	Mtime   string
+}
+
+// Cors 服务的跨域配置, 每个服务只有一条.
+type Cors struct {
+	ID        int64
+	ServiceID int64 `db:"service_id"`
+	// Origins 允许的来源, 逗号分隔, 支持*及https://*.example.com这样的通配符.
+	Origins string
+	// Methods 允许的方法, 逗号分隔, 为空时允许GET,POST,PUT,DELETE.
+	Methods string
+	// Headers 允许的请求头, 逗号分隔, *表示调用方请求的都允许.
+	Headers     string
+	Credentials bool
+	// MaxAge 预检结果的缓存时间, 单位秒, 0不返回.
+	MaxAge  int `db:"max_age"`
+	State   int
+	Comment string
+	Ctime   string
+	Mtime   string
+}
+
+// Certificate 网关https证书, 按SNI中的域名选择.
+type Certificate struct {
+	ID     int64
+	Domain string
+	Cert   string
+	Key    string `json:"-"`
+	// NotAfter 证书过期时间.
+	NotAfter string `db:"not_after"`
+	State    int
+	Comment  string
+	Ctime    string
+	Mtime    string
+}
+
+// MirrorDiff 一次镜像请求与主后端的对比结果.
+type MirrorDiff struct {
+	ID            int64
+	InterfaceID   int64 `db:"interface_id"`
+	Session       string
+	PrimaryStatus int    `db:"primary_status"`
+	MirrorStatus  int    `db:"mirror_status"`
+	PrimaryHash   string `db:"primary_hash"`
+	MirrorHash    string `db:"mirror_hash"`
+	PrimaryCost   int64  `db:"primary_cost"`
+	MirrorCost    int64  `db:"mirror_cost"`
+	Same          bool
+	Error         string
+	Ctime         string
+}
+
+// EventPrefix manager修改配置后在etcd中写入事件的前缀, 后面跟表名.
+const EventPrefix = "/event/"
+
+// Event 配置修改事件, repeater收到后清理对应的缓存.
+type Event struct {
+	// Table 修改的表名.
+	Table string
+	// ID 修改的记录id.
+	ID int64
+	// Parent 记录所属的上级id, variable及transform是接口id, canary是服务id, 不知道时为0.
+	Parent int64 `json:",omitempty"`
+	// Routes route事件带上服务下所有路径模式的快照, ID是服务id.
+	Routes []Route `json:",omitempty"`
+}
+
+// TokenKeyState 签名密钥状态.
+type TokenKeyState int
+
+const (
+	// TokenKeyDisabled 停用, 用它签发的token全部失效.
+	TokenKeyDisabled TokenKeyState = iota
+	// TokenKeyActive 用来签发新token, 同时可以验证.
+	TokenKeyActive
+	// TokenKeyVerify 轮换下来的密钥, 只用来验证已经签发的token.
+	TokenKeyVerify
+)
+
+// TokenKey token签名密钥, 通过Kid区分.
+type TokenKey struct {
+	ID      int64
+	Kid     string
+	Secret  string `json:"-"`
+	State   TokenKeyState
+	Comment string
+	Ctime   string
+	Mtime   string
+}
+
+// AppToken 签发给应用的token记录, 不保存token本身.
+type AppToken struct {
+	ID            int64
+	ApplicationID int64 `db:"application_id"`
+	Jti           string
+	Kid           string
+	Scopes        string
+	Expire        string
+	Revoked       bool
+	Comment       string
+	Ctime         string
+}
+
+const (
+	// QuotaDay 按自然日计算的配额.
+	QuotaDay = "day"
+	// QuotaMonth 按自然月计算的配额.
+	QuotaMonth = "month"
+)
+
+// Quota 应用调用接口的配额, 同一应用及接口每个周期只有一条.
+type Quota struct {
+	ID            int64
+	ApplicationID int64 `db:"application_id"`
+	InterfaceID   int64 `db:"interface_id"`
+	// Period 统计周期, day或month.
+	Period string
+	// Calls 每个周期内允许的调用次数.
+	Calls   int64
+	Comment string
+	Ctime   string
+	Mtime   string
+}
@@ -2,11 +2,12 @@ package document
 
 // Field 方法中的参数.
 type Field struct {
-	Name     string
-	Type     string
-	Required bool
-	Comment  string
-	Child    map[string]Field
+	Name      string
+	Type      string
+	Required  bool
+	Sensitive bool
+	Comment   string
+	Child     map[string]Field
 }
 
 // Method 接口中的一个方法.
@@ -0,0 +1,212 @@
+package repeater
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util/redact"
+	"dearcode.net/doodle/pkg/util/signature"
+)
+
+const (
+	adminPrefix = "/_admin/"
+)
+
+var (
+	// Admin 管理接口, 查看路由表、后端实例及缓存.
+	Admin = &admin{}
+)
+
+type admin struct {
+}
+
+// adminEnabled 配置了Token才开启管理接口.
+func adminEnabled() bool {
+	return config.Repeater.Admin.Token != "none" && config.Repeater.Admin.Token != ""
+}
+
+// adminOnGateway 没有单独的监听地址时, 管理接口挂在网关端口上.
+func adminOnGateway(req *http.Request) bool {
+	return config.Repeater.Admin.Addr == "none" && strings.HasPrefix(req.URL.Path, adminPrefix)
+}
+
+func (a *admin) send(w http.ResponseWriter, v interface{}) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf)
+}
+
+// ServeHTTP 所有请求都要带Admin-Token.
+func (a *admin) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !adminEnabled() {
+		http.NotFound(w, req)
+		return
+	}
+
+	if !signature.Equal(req.Header.Get("Admin-Token"), config.Repeater.Admin.Token) {
+		log.Errorf("admin %s from %s invalid token", req.URL.Path, req.RemoteAddr)
+		http.Error(w, "invalid admin token", http.StatusUnauthorized)
+		return
+	}
+
+	log.Infof("admin %s %s from %s", req.Method, req.URL, req.RemoteAddr)
+
+	switch strings.TrimPrefix(req.URL.Path, adminPrefix) {
+	case "backends":
+		a.send(w, bs.snapshot())
+	case "cache":
+		a.cache(w, req)
+	case "cache/flush":
+		a.flush(w, req)
+	case "stats":
+		a.send(w, stats.pending())
+	case "budget":
+		//没有熔断器, 后端的重试预算就是当前的保护状态
+		a.send(w, Server.budget.snapshot())
+	case "shed":
+		a.send(w, Server.shed.snapshot())
+	case "certs":
+		a.send(w, certs.snapshot())
+	case "resync":
+		a.resync(w, req)
+	case "reload":
+		a.reload(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// cache 查看缓存, key中的控制字符按Go的转义格式显示, prefix同样支持转义.
+func (a *admin) cache(w http.ResponseWriter, req *http.Request) {
+	prefix, err := unquoteKey(req.URL.Query().Get("prefix"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	type item struct {
+		Key   string
+		Age   int64
+		Value interface{}
+	}
+
+	var items []item
+	for _, i := range dc.cache.Snapshot(prefix) {
+		items = append(items, item{Key: quoteKey(i.Key), Age: i.Age, Value: maskCacheValue(i.Key, i.Value)})
+	}
+
+	a.send(w, items)
+}
+
+// maskCacheValue 缓存中的签名密钥、应用的token及密钥、证书私钥不能返回, 复制一份替换成掩码.
+func maskCacheValue(key string, v interface{}) interface{} {
+	if strings.HasPrefix(key, "\x07") {
+		return redact.Mask
+	}
+
+	switch nv := v.(type) {
+	case *meta.Application:
+		app := *nv
+		app.Token = maskString(app.Token)
+		app.Secret = maskString(app.Secret)
+		return &app
+	case *meta.Certificate:
+		c := *nv
+		c.Key = maskString(c.Key)
+		return &c
+	}
+
+	return v
+}
+
+func maskString(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redact.Mask
+}
+
+// flush 删除指定key, 没有key时清空所有缓存.
+func (a *admin) flush(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "need POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := req.URL.Query().Get("key")
+	if key == "" {
+		dc.cache.Purge()
+		log.Warningf("admin purge all cache")
+		a.send(w, map[string]string{"flush": "all"})
+		return
+	}
+
+	k, err := unquoteKey(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dc.cache.Delete(k)
+	log.Warningf("admin delete cache key:%s", key)
+	a.send(w, map[string]string{"flush": key})
+}
+
+// resync 从etcd重新加载后端实例列表.
+func (a *admin) resync(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "need POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := bs.load(); err != nil {
+		log.Errorf("admin resync error:%s", errors.ErrorStack(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Warningf("admin resync backends from etcd")
+	a.send(w, bs.snapshot())
+}
+
+// reload 重新加载配置文件, 返回生效及需要重启的字段.
+func (a *admin) reload(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "need POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r, err := Reload()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Warningf("admin reload config")
+	a.send(w, r)
+}
+
+func quoteKey(k string) string {
+	s := strconv.Quote(k)
+	return s[1 : len(s)-1]
+}
+
+func unquoteKey(k string) (string, error) {
+	s, err := strconv.Unquote(`"` + strings.Replace(k, `"`, `\"`, -1) + `"`)
+	if err != nil {
+		return "", errors.Annotatef(err, "invalid key:%s", k)
+	}
+	return s, nil
+}
@@ -0,0 +1,37 @@
+package repeater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+func TestAdminCacheMask(t *testing.T) {
+	dc = &dbCache{cache: newTTLCache(60)}
+	dc.cache.Add("\x07kid1", "token-sign-secret")
+	dc.cache.Add("\x061", &meta.Application{ID: 1, Name: "app", Token: "app-token-value", Secret: "app-secret-value"})
+	dc.cache.Add("\x0ca1b2", int64(1))
+
+	req := httptest.NewRequest(http.MethodGet, "/_admin/cache", nil)
+	w := httptest.NewRecorder()
+	Admin.cache(w, req)
+
+	body := w.Body.String()
+	for _, s := range []string{"token-sign-secret", "app-token-value", "app-secret-value"} {
+		if strings.Contains(body, s) {
+			t.Fatalf("secret %s in response:%s", s, body)
+		}
+	}
+
+	if !strings.Contains(body, `"Value":"******"`) || !strings.Contains(body, `"Name":"app"`) {
+		t.Fatalf("invalid response:%s", body)
+	}
+
+	//缓存中的原值不能被修改
+	if a := dc.cache.Get("\x061").(*meta.Application); a.Secret != "app-secret-value" {
+		t.Fatalf("cached app modified:%+v", a)
+	}
+}
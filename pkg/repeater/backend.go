@@ -61,6 +61,7 @@ func (bs *backendService) start() {
 	}
 }
 
+// load 从etcd全量加载后端实例, 替换内存中的列表, 也用于手动重新同步.
 func (bs *backendService) load() error {
 	bss, err := bs.etcd.List(apigatePrefix)
 	if err != nil {
@@ -68,6 +69,8 @@ func (bs *backendService) load() error {
 		return errors.Annotatef(err, apigatePrefix)
 	}
 
+	nbs := &backendService{apps: make(map[string][]meta.MicroAPP)}
+
 	for k, v := range bss {
 		// k = /api/dbs/dbfree/handler/Fore/192.168.180.102/21638
 		ss := strings.Split(k, "/")
@@ -80,12 +83,29 @@ func (bs *backendService) load() error {
 		name := strings.Join(ss[2:len(ss)-2], "/")
 		app := meta.MicroAPP{}
 		json.Unmarshal([]byte(v), &app)
-		bs.register(name, app)
+		nbs.register(name, app)
 	}
 
+	bs.mu.Lock()
+	bs.apps = nbs.apps
+	bs.mu.Unlock()
+
 	return nil
 }
 
+// snapshot 复制一份当前的后端实例列表.
+func (bs *backendService) snapshot() map[string][]meta.MicroAPP {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	apps := make(map[string][]meta.MicroAPP, len(bs.apps))
+	for k, v := range bs.apps {
+		apps[k] = append([]meta.MicroAPP(nil), v...)
+	}
+
+	return apps
+}
+
 // unregister 如果etcd中事务是删除，这里就去管理处删除.
 func (bs *backendService) unregister(name, host string, port int) {
 	bs.mu.Lock()
@@ -143,11 +163,6 @@ func (bs *backendService) getMicroAPPs(name string) ([]meta.MicroAPP, error) {
 	bs.mu.RLock()
 	defer bs.mu.RUnlock()
 
-	log.Debugf("find name:%v", name)
-	for k, v := range bs.apps {
-		log.Debugf("k:%v, v:%v", k, v)
-	}
-
 	apps, ok := bs.apps[name]
 	if !ok {
 		return nil, errors.Annotatef(errNotFound, name)
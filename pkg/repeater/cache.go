@@ -8,7 +8,6 @@ import (
 	"sync"
 	"time"
 
-	"dearcode.net/crab/cache"
 	"dearcode.net/crab/log"
 	"dearcode.net/crab/util/aes"
 	"github.com/juju/errors"
@@ -18,15 +17,37 @@ import (
 )
 
 type dbCache struct {
-	cache          *cache.Cache
+	cache          *ttlCache
 	selService     *sql.Stmt
 	selIface       *sql.Stmt
+	selIfaceByID   *sql.Stmt
+	selRoutes      *sql.Stmt
 	selVar         *sql.Stmt
 	selApp         *sql.Stmt
+	selAppByID     *sql.Stmt
+	selTokenKey    *sql.Stmt
+	selRevoke      *sql.Stmt
 	selRelation    *sql.Stmt
+	selTransform   *sql.Stmt
+	selCanary      *sql.Stmt
+	selPolicy      *sql.Stmt
+	selMirror      *sql.Stmt
+	selCertificate *sql.Stmt
+	selAppByCert   *sql.Stmt
+	selCors        *sql.Stmt
+	selQuota       *sql.Stmt
+	selQuotaUsage  *sql.Stmt
 	instStats      *sql.Stmt
 	instErrorStats *sql.Stmt
+	instVersion    *sql.Stmt
+	instRetry      *sql.Stmt
+	instLatency    *sql.Stmt
+	instMirrorDiff *sql.Stmt
+	instLimit      *sql.Stmt
+	instQuota      *sql.Stmt
 	dbc            *sql.DB
+	// watching 正在监控manager的修改事件.
+	watching int32
 	sync.RWMutex
 }
 
@@ -43,6 +64,14 @@ func (dc *dbCache) closeAll() {
 		dc.selIface.Close()
 		dc.selIface = nil
 	}
+	if dc.selIfaceByID != nil {
+		dc.selIfaceByID.Close()
+		dc.selIfaceByID = nil
+	}
+	if dc.selRoutes != nil {
+		dc.selRoutes.Close()
+		dc.selRoutes = nil
+	}
 	if dc.selVar != nil {
 		dc.selVar.Close()
 		dc.selVar = nil
@@ -52,11 +81,61 @@ func (dc *dbCache) closeAll() {
 		dc.selApp = nil
 	}
 
+	if dc.selAppByID != nil {
+		dc.selAppByID.Close()
+		dc.selAppByID = nil
+	}
+
+	if dc.selTokenKey != nil {
+		dc.selTokenKey.Close()
+		dc.selTokenKey = nil
+	}
+
+	if dc.selRevoke != nil {
+		dc.selRevoke.Close()
+		dc.selRevoke = nil
+	}
+
 	if dc.selRelation != nil {
 		dc.selRelation.Close()
 		dc.selRelation = nil
 	}
 
+	if dc.selTransform != nil {
+		dc.selTransform.Close()
+		dc.selTransform = nil
+	}
+
+	if dc.selCanary != nil {
+		dc.selCanary.Close()
+		dc.selCanary = nil
+	}
+
+	if dc.selPolicy != nil {
+		dc.selPolicy.Close()
+		dc.selPolicy = nil
+	}
+
+	if dc.selMirror != nil {
+		dc.selMirror.Close()
+		dc.selMirror = nil
+	}
+
+	if dc.selCertificate != nil {
+		dc.selCertificate.Close()
+		dc.selCertificate = nil
+	}
+
+	if dc.selAppByCert != nil {
+		dc.selAppByCert.Close()
+		dc.selAppByCert = nil
+	}
+
+	if dc.selCors != nil {
+		dc.selCors.Close()
+		dc.selCors = nil
+	}
+
 	if dc.instStats != nil {
 		dc.instStats.Close()
 		dc.instStats = nil
@@ -67,6 +146,46 @@ func (dc *dbCache) closeAll() {
 		dc.instErrorStats = nil
 	}
 
+	if dc.instVersion != nil {
+		dc.instVersion.Close()
+		dc.instVersion = nil
+	}
+
+	if dc.instRetry != nil {
+		dc.instRetry.Close()
+		dc.instRetry = nil
+	}
+
+	if dc.instLatency != nil {
+		dc.instLatency.Close()
+		dc.instLatency = nil
+	}
+
+	if dc.instMirrorDiff != nil {
+		dc.instMirrorDiff.Close()
+		dc.instMirrorDiff = nil
+	}
+
+	if dc.instLimit != nil {
+		dc.instLimit.Close()
+		dc.instLimit = nil
+	}
+
+	if dc.selQuota != nil {
+		dc.selQuota.Close()
+		dc.selQuota = nil
+	}
+
+	if dc.selQuotaUsage != nil {
+		dc.selQuotaUsage.Close()
+		dc.selQuotaUsage = nil
+	}
+
+	if dc.instQuota != nil {
+		dc.instQuota.Close()
+		dc.instQuota = nil
+	}
+
 }
 
 func (dc *dbCache) conectDB() error {
@@ -85,15 +204,35 @@ func (dc *dbCache) conectDB() error {
 		return errors.Trace(err)
 	}
 
-	if dc.selIface, err = dc.dbc.Prepare("select id, method, backend, email from interface where service_id = ? and path=?"); err != nil {
+	if dc.selIface, err = dc.dbc.Prepare("select id, method, backend, email, internal, mock, mock_latency, mock_error_rate, redact_fields, redact_headers, log_max_size, max_body_size, max_header_size, level from interface where service_id = ? and path=?"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selIfaceByID, err = dc.dbc.Prepare("select id, method, backend, email, internal, mock, mock_latency, mock_error_rate, redact_fields, redact_headers, log_max_size, max_body_size, max_header_size, level from interface where id = ?"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selRoutes, err = dc.dbc.Prepare("select id, path from interface where service_id = ?"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selVar, err = dc.dbc.Prepare("select id, postion, name, type, level, parent, required, sensitive, example from variable where interface_id = ? order by id"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selApp, err = dc.dbc.Prepare("select name, email, auth_mode, allow_cidr from application where id = ? and token=?"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selAppByID, err = dc.dbc.Prepare("select name, email, secret, auth_mode, allow_cidr from application where id = ?"); err != nil {
 		return errors.Trace(err)
 	}
 
-	if dc.selVar, err = dc.dbc.Prepare("select postion, name, type, required from variable where interface_id = ?"); err != nil {
+	if dc.selTokenKey, err = dc.dbc.Prepare("select secret from token_key where kid = ? and state > 0"); err != nil {
 		return errors.Trace(err)
 	}
 
-	if dc.selApp, err = dc.dbc.Prepare("select name, email from application where id = ? and token=?"); err != nil {
+	if dc.selRevoke, err = dc.dbc.Prepare("select id from app_token where jti = ? and revoked = 1"); err != nil {
 		return errors.Trace(err)
 	}
 
@@ -101,6 +240,34 @@ func (dc *dbCache) conectDB() error {
 		return errors.Trace(err)
 	}
 
+	if dc.selTransform, err = dc.dbc.Prepare("select id, target, action, name, value from transform where interface_id = ? order by id"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selCanary, err = dc.dbc.Prepare("select id, version, percent, app_ids, header_name, header_value from canary where service_id = ? and state = 1 order by id desc limit 1"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selPolicy, err = dc.dbc.Prepare("select id, connect_timeout, timeout, attempts, retry_on, non_idempotent, backoff, max_backoff from backend_policy where interface_id = ?"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selMirror, err = dc.dbc.Prepare("select id, target, percent, diff from mirror where interface_id = ? and state = 1"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selCertificate, err = dc.dbc.Prepare("select id, domain, cert, `key` from certificate where state = 1 order by id"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selAppByCert, err = dc.dbc.Prepare("select id from application where cert_fingerprint = ?"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selCors, err = dc.dbc.Prepare("select c.id, c.service_id, c.origins, c.methods, c.headers, c.credentials, c.max_age from cors c, service s where c.service_id = s.id and s.path = ? and c.state = 1"); err != nil {
+		return errors.Trace(err)
+	}
+
 	if dc.instStats, err = dc.dbc.Prepare("insert into stats (iface_id, app_id, cnt, err, cost, event_time) values (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE cnt = cnt + ?, cost =  cost + ?"); err != nil {
 		return errors.Trace(err)
 	}
@@ -109,15 +276,56 @@ func (dc *dbCache) conectDB() error {
 		return errors.Trace(err)
 	}
 
+	if dc.instVersion, err = dc.dbc.Prepare("insert into stats_version (iface_id, version, cnt, err, cost, event_time) values (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE cnt = cnt + ?, err = err + ?, cost =  cost + ?"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.instMirrorDiff, err = dc.dbc.Prepare("insert into mirror_diff (interface_id, session, primary_status, mirror_status, primary_hash, mirror_hash, primary_cost, mirror_cost, same, error, ctime) values (?,?,?,?,?,?,?,?,?,?,now())"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.instRetry, err = dc.dbc.Prepare("insert into stats_retry (iface_id, backend, cnt, event_time) values (?,?,?,?) ON DUPLICATE KEY UPDATE cnt = cnt + ?"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.instLatency, err = dc.dbc.Prepare("insert into stats_latency (iface_id, app_id, buckets, event_time) values (?,?,?,?)"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.instLimit, err = dc.dbc.Prepare("insert into stats_limit (iface_id, kind, cnt, event_time) values (?,?,?,?) ON DUPLICATE KEY UPDATE cnt = cnt + ?"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selQuota, err = dc.dbc.Prepare("select id, period, calls from quota where application_id = ? and interface_id = ?"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.selQuotaUsage, err = dc.dbc.Prepare("select cnt from quota_usage where quota_id = ? and period_key = ?"); err != nil {
+		return errors.Trace(err)
+	}
+
+	if dc.instQuota, err = dc.dbc.Prepare("insert into quota_usage (quota_id, period_key, cnt) values (?,?,?) ON DUPLICATE KEY UPDATE cnt = cnt + ?"); err != nil {
+		return errors.Trace(err)
+	}
+
 	return nil
 }
 
 var (
-	errInvalidPath   = errors.New("invalid path")
-	errInvalidToken  = errors.New("invalid token")
-	errNotFoundToken = errors.New("token not found")
-	errNotFound      = errors.New("not found")
-	errForbidden     = errors.New("forbidden")
+	errInvalidPath      = errors.New("invalid path")
+	errInvalidToken     = errors.New("invalid token")
+	errNotFoundToken    = errors.New("token not found")
+	errNotFound         = errors.New("not found")
+	errForbidden        = errors.New("forbidden")
+	errInvalidArgument  = errors.New("invalid argument")
+	errInvalidSign      = errors.New("invalid signature")
+	errBodyTooLarge     = errors.New("request body too large")
+	errHeaderTooLarge   = errors.New("request header too large")
+	errRequestTimeout   = errors.New("request timeout")
+	errMethodNotAllowed = errors.New("method not allowed")
+	errBackend          = errors.New("backend error")
+	errOverloaded       = errors.New("overloaded")
+	errQuotaExceeded    = errors.New("quota exceeded")
 )
 
 const (
@@ -197,9 +405,15 @@ func (dc *dbCache) queryDB(s *sql.Stmt, arg []interface{}, res []interface{}) er
 
 func (dc *dbCache) getInterface(key string) (*meta.Interface, error) {
 	if v := dc.cache.Get(key); v != nil {
-		return v.(*meta.Interface), nil
+		//ID为0的是不存在的路径, 认证前就会按路径查接口, 不缓存的话随机路径每次都会查库
+		if i := v.(*meta.Interface); i.ID != 0 {
+			return i, nil
+		}
+		return nil, errors.Annotatef(errNotFound, "path:%s", key)
 	}
 
+	gen := dc.cache.Gen()
+
 	ps := strings.Split(key, "/")
 	if len(ps) < 3 {
 		return nil, errors.Trace(errInvalidPath)
@@ -207,6 +421,9 @@ func (dc *dbCache) getInterface(key string) (*meta.Interface, error) {
 
 	p := meta.Service{}
 	if err := dc.queryDB(dc.selService, []interface{}{ps[1]}, []interface{}{&p.ID, &p.Validate, &p.Version}); err != nil {
+		if errors.Cause(err) == errNotFound {
+			dc.cache.AddSince(key, &meta.Interface{}, gen)
+		}
 		return nil, errors.Trace(err)
 	}
 
@@ -221,28 +438,44 @@ func (dc *dbCache) getInterface(key string) (*meta.Interface, error) {
 	}
 
 	i := meta.Interface{}
-	if err := dc.queryDB(dc.selIface, []interface{}{p.ID, path}, []interface{}{&i.ID, &i.Method, &i.Backend, &i.Email}); err != nil {
+	err := dc.queryDB(dc.selIface, []interface{}{p.ID, path}, ifaceDests(&i))
+	if errors.Cause(err) == errNotFound {
+		//没有精确匹配的接口, 再按服务下的路径模式查找
+		path = key[len(ps[1])+1:]
+		err = dc.matchInterface(p.ID, path, &i)
+	}
+	if err != nil {
+		if errors.Cause(err) == errNotFound {
+			dc.cache.AddSince(key, &meta.Interface{Service: p}, gen)
+		}
 		return nil, errors.Trace(err)
 	}
 
 	i.Path = path
 	i.Service = p
 
-	dc.cache.Add(key, &i)
+	dc.cache.AddSince(key, &i, gen)
 
 	return &i, nil
 }
 
+// ifaceDests 查询接口时各列对应的字段.
+func ifaceDests(i *meta.Interface) []interface{} {
+	return []interface{}{&i.ID, &i.Method, &i.Backend, &i.Email, &i.Internal, &i.Mock, &i.MockLatency, &i.MockErrorRate, &i.RedactFields, &i.RedactHeaders, &i.LogMaxSize, &i.MaxBodySize, &i.MaxHeaderSize, &i.Level}
+}
+
 func (dc *dbCache) validateRelation(appID, ifaceID int64) error {
-	key := fmt.Sprintf("\x03%d%d", appID, ifaceID)
+	key := fmt.Sprintf("\x03%d.%d", appID, ifaceID)
 	if v := dc.cache.Get(key); v != nil {
 		return nil
 	}
+
+	gen := dc.cache.Gen()
 	var id int64
 	if err := dc.queryDB(dc.selRelation, []interface{}{appID, ifaceID}, []interface{}{&id}); err != nil {
 		return errors.Trace(err)
 	}
-	dc.cache.Add(key, id)
+	dc.cache.AddSince(key, id, gen)
 	return nil
 }
 
@@ -251,12 +484,15 @@ func (dc *dbCache) getApplication(id int64, token string) (*meta.Application, er
 	if v := dc.cache.Get(key); v != nil {
 		return v.(*meta.Application), nil
 	}
+
+	gen := dc.cache.Gen()
 	a := meta.Application{ID: id}
-	if err := dc.queryDB(dc.selApp, []interface{}{id, token}, []interface{}{&a.Name, &a.Email}); err != nil {
+	if err := dc.queryDB(dc.selApp, []interface{}{id, token}, []interface{}{&a.Name, &a.Email, &a.AuthMode, &a.AllowCIDR}); err != nil {
 		return nil, errors.Trace(err)
 	}
+	parseAllowNets(&a)
 
-	dc.cache.Add(key, &a)
+	dc.cache.AddSince(key, &a, gen)
 	return &a, nil
 }
 
@@ -267,6 +503,8 @@ func (dc *dbCache) getVariable(id int64) ([]*meta.Variable, error) {
 		return vs.([]*meta.Variable), nil
 	}
 
+	gen := dc.cache.Gen()
+
 	var rows *sql.Rows
 	var err error
 
@@ -283,17 +521,130 @@ func (dc *dbCache) getVariable(id int64) ([]*meta.Variable, error) {
 
 	for rows.Next() {
 		var v meta.Variable
-		if err = rows.Scan(&v.Postion, &v.Name, &v.Type, &v.Required); err != nil {
+		if err = rows.Scan(&v.ID, &v.Postion, &v.Name, &v.Type, &v.Level, &v.Parent, &v.Required, &v.Sensitive, &v.Example); err != nil {
 			return nil, errors.Trace(err)
 		}
 		vs = append(vs, &v)
 	}
 
-	dc.cache.Add(key, vs)
+	dc.cache.AddSince(key, vs, gen)
 
 	return vs, nil
 }
 
+// getTransforms 接口的转换规则, 按添加顺序执行.
+func (dc *dbCache) getTransforms(id int64) ([]*meta.Transform, error) {
+	key := fmt.Sprintf("\x04%d", id)
+
+	if ts := dc.cache.Get(key); ts != nil {
+		return ts.([]*meta.Transform), nil
+	}
+
+	gen := dc.cache.Gen()
+
+	var rows *sql.Rows
+	var err error
+
+	if err = dc.dbQuery(func() error {
+		rows, err = dc.selTransform.Query(id)
+		return err
+	}); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	defer rows.Close()
+
+	var ts []*meta.Transform
+
+	for rows.Next() {
+		t := meta.Transform{InterfaceID: id}
+		if err = rows.Scan(&t.ID, &t.Target, &t.Action, &t.Name, &t.Value); err != nil {
+			return nil, errors.Trace(err)
+		}
+		ts = append(ts, &t)
+	}
+
+	dc.cache.AddSince(key, ts, gen)
+
+	return ts, nil
+}
+
+// getCanary 服务正在生效的灰度策略, 没有时返回nil.
+func (dc *dbCache) getCanary(serviceID int64) (*meta.Canary, error) {
+	key := fmt.Sprintf("\x05%d", serviceID)
+	if v := dc.cache.Get(key); v != nil {
+		if c := v.(*meta.Canary); c.ID != 0 {
+			return c, nil
+		}
+		return nil, nil
+	}
+
+	gen := dc.cache.Gen()
+
+	c := meta.Canary{ServiceID: serviceID}
+	if err := dc.queryDB(dc.selCanary, []interface{}{serviceID}, []interface{}{&c.ID, &c.Version, &c.Percent, &c.AppIDs, &c.HeaderName, &c.HeaderValue}); err != nil {
+		if errors.Cause(err) != errNotFound {
+			return nil, errors.Trace(err)
+		}
+		//没有灰度策略也缓存起来，避免每次都查库
+		dc.cache.AddSince(key, &meta.Canary{ServiceID: serviceID}, gen)
+		return nil, nil
+	}
+
+	dc.cache.AddSince(key, &c, gen)
+	return &c, nil
+}
+
+// getPolicy 获取接口的超时及重试策略, 没有配置返回nil.
+func (dc *dbCache) getPolicy(ifaceID int64) (*meta.BackendPolicy, error) {
+	key := fmt.Sprintf("\x0a%d", ifaceID)
+	if v := dc.cache.Get(key); v != nil {
+		if p := v.(*meta.BackendPolicy); p.ID != 0 {
+			return p, nil
+		}
+		return nil, nil
+	}
+
+	gen := dc.cache.Gen()
+
+	p := meta.BackendPolicy{InterfaceID: ifaceID}
+	if err := dc.queryDB(dc.selPolicy, []interface{}{ifaceID}, []interface{}{&p.ID, &p.ConnectTimeout, &p.Timeout, &p.Attempts, &p.RetryOn, &p.NonIdempotent, &p.Backoff, &p.MaxBackoff}); err != nil {
+		if errors.Cause(err) != errNotFound {
+			return nil, errors.Trace(err)
+		}
+		dc.cache.AddSince(key, &meta.BackendPolicy{InterfaceID: ifaceID}, gen)
+		return nil, nil
+	}
+
+	dc.cache.AddSince(key, &p, gen)
+	return &p, nil
+}
+
+// getMirror 获取接口开启的镜像配置, 没有返回nil.
+func (dc *dbCache) getMirror(ifaceID int64) (*meta.Mirror, error) {
+	key := fmt.Sprintf("\x0b%d", ifaceID)
+	if v := dc.cache.Get(key); v != nil {
+		if m := v.(*meta.Mirror); m.ID != 0 {
+			return m, nil
+		}
+		return nil, nil
+	}
+
+	gen := dc.cache.Gen()
+
+	m := meta.Mirror{InterfaceID: ifaceID}
+	if err := dc.queryDB(dc.selMirror, []interface{}{ifaceID}, []interface{}{&m.ID, &m.Target, &m.Percent, &m.Diff}); err != nil {
+		if errors.Cause(err) != errNotFound {
+			return nil, errors.Trace(err)
+		}
+		dc.cache.AddSince(key, &meta.Mirror{InterfaceID: ifaceID}, gen)
+		return nil, nil
+	}
+
+	dc.cache.AddSince(key, &m, gen)
+	return &m, nil
+}
+
 func (dc *dbCache) executeDB(s *sql.Stmt, arg []interface{}) (res sql.Result, err error) {
 	dc.Lock()
 	defer dc.Unlock()
@@ -312,9 +663,22 @@ func (dc *dbCache) executeDB(s *sql.Stmt, arg []interface{}) (res sql.Result, er
 		}
 	}
 
+	//最后一次重试时才连上数据库, 并没有执行
+	if err == nil {
+		err = errors.Errorf("exec failed after %d retries", maxRetry)
+	}
+
 	return
 }
 
+// available 数据库是否可以连接.
+func (dc *dbCache) available() bool {
+	dc.Lock()
+	defer dc.Unlock()
+
+	return dc.dbc != nil && dc.dbc.Ping() == nil
+}
+
 func (dc *dbCache) insertDB(s *sql.Stmt, arg []interface{}) (id int64, err error) {
 	var res sql.Result
 
@@ -328,8 +692,8 @@ func (dc *dbCache) insertDB(s *sql.Stmt, arg []interface{}) (id int64, err error
 	return
 }
 
-func (dc *dbCache) insertStats(iface, app int64, count, errs int, cost int64) error {
-	id, err := dc.insertDB(dc.instStats, []interface{}{iface, app, count, errs, cost, time.Now().Format("2006-01-02 15:04"), count, cost})
+func (dc *dbCache) insertStats(iface, app int64, count, errs int, cost int64, tm string) error {
+	id, err := dc.insertDB(dc.instStats, []interface{}{iface, app, count, errs, cost, tm, count, cost})
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -337,8 +701,54 @@ func (dc *dbCache) insertStats(iface, app int64, count, errs int, cost int64) er
 	return nil
 }
 
+func (dc *dbCache) insertVersionStats(iface int64, version string, count, errs int, cost int64, tm string) error {
+	id, err := dc.insertDB(dc.instVersion, []interface{}{iface, version, count, errs, cost, tm, count, errs, cost})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Debugf("insert version stats:%v", id)
+	return nil
+}
+
+func (dc *dbCache) insertRetryStats(iface int64, backend string, count int, tm string) error {
+	id, err := dc.insertDB(dc.instRetry, []interface{}{iface, backend, count, tm, count})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Debugf("insert retry stats:%v", id)
+	return nil
+}
+
+func (dc *dbCache) insertLimitStats(iface int64, kind string, count int, tm string) error {
+	id, err := dc.insertDB(dc.instLimit, []interface{}{iface, kind, count, tm, count})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Debugf("insert limit stats:%v", id)
+	return nil
+}
+
+// insertLatencyStats 耗时分布, 同一分钟可能有多行, 查询时合并.
+func (dc *dbCache) insertLatencyStats(iface, app int64, buckets, tm string) error {
+	id, err := dc.insertDB(dc.instLatency, []interface{}{iface, app, buckets, tm})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Debugf("insert latency stats:%v", id)
+	return nil
+}
+
+func (dc *dbCache) insertMirrorDiff(d *meta.MirrorDiff) error {
+	id, err := dc.insertDB(dc.instMirrorDiff, []interface{}{d.InterfaceID, d.Session, d.PrimaryStatus, d.MirrorStatus, d.PrimaryHash, d.MirrorHash, d.PrimaryCost, d.MirrorCost, d.Same, d.Error})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Debugf("insert mirror diff:%v", id)
+	return nil
+}
+
 func (dc *dbCache) insertErrorStats(session string, iface, app int64, info string, tm time.Time) error {
-	id, err := dc.insertDB(dc.instErrorStats, []interface{}{session, iface, app, info})
+	id, err := dc.insertDB(dc.instErrorStats, []interface{}{session, iface, app, info, tm})
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -0,0 +1,67 @@
+package repeater
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"dearcode.net/crab/log"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+// canaryHit 判断请求是否命中灰度策略, 指定应用及header优先, 其次按比例.
+func canaryHit(c *meta.Canary, app *meta.Application, req *http.Request) bool {
+	if c.AppIDs != "" {
+		id := strconv.FormatInt(app.ID, 10)
+		for _, s := range strings.Split(c.AppIDs, ",") {
+			if strings.TrimSpace(s) == id {
+				return true
+			}
+		}
+	}
+
+	if c.HeaderName != "" && req.Header.Get(c.HeaderName) == c.HeaderValue {
+		return true
+	}
+
+	return c.Percent > 0 && rand.Intn(100) < c.Percent
+}
+
+// canaryApps 根据服务的灰度策略挑出本次请求可用的实例.
+// 命中策略时只用新版本实例, 没命中时只用其它版本实例, 哪边没有实例就退回使用全部实例.
+func canaryApps(id string, app *meta.Application, iface *meta.Interface, req *http.Request, apps []meta.MicroAPP) []meta.MicroAPP {
+	c, err := dc.getCanary(iface.Service.ID)
+	if err != nil {
+		log.Errorf("%s get canary service:%d error:%v", id, iface.Service.ID, err)
+		return apps
+	}
+
+	if c == nil {
+		return apps
+	}
+
+	var canary, stable []meta.MicroAPP
+	for i := range apps {
+		if apps[i].Match(c.Version) {
+			canary = append(canary, apps[i])
+			continue
+		}
+		stable = append(stable, apps[i])
+	}
+
+	if canaryHit(c, app, req) {
+		if len(canary) > 0 {
+			log.Debugf("%s canary:%d hit, version:%s", id, c.ID, c.Version)
+			return canary
+		}
+		return apps
+	}
+
+	if len(stable) > 0 {
+		return stable
+	}
+
+	return apps
+}
@@ -0,0 +1,77 @@
+package repeater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+func TestCanaryHit(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/svc/iface", nil)
+	req.Header.Set("X-Canary", "1")
+
+	cases := []struct {
+		canary meta.Canary
+		app    int64
+		hit    bool
+	}{
+		{meta.Canary{AppIDs: "3, 5"}, 5, true},
+		{meta.Canary{AppIDs: "3,5"}, 35, false},
+		{meta.Canary{HeaderName: "X-Canary", HeaderValue: "1"}, 1, true},
+		{meta.Canary{HeaderName: "X-Canary", HeaderValue: "2"}, 1, false},
+		{meta.Canary{Percent: 100}, 1, true},
+		{meta.Canary{Percent: 0}, 1, false},
+	}
+
+	for _, c := range cases {
+		if canaryHit(&c.canary, &meta.Application{ID: c.app}, req) != c.hit {
+			t.Fatalf("canary:%+v app:%d expect:%v", c.canary, c.app, c.hit)
+		}
+	}
+}
+
+func TestCanaryApps(t *testing.T) {
+	dc = &dbCache{cache: newTTLCache(60)}
+	dc.cache.Add("\x051", &meta.Canary{ID: 1, ServiceID: 1, Version: "abc", AppIDs: "7"})
+	//ID为0表示服务没有灰度策略
+	dc.cache.Add("\x052", &meta.Canary{ServiceID: 2})
+
+	apps := []meta.MicroAPP{{Host: "stable", GitHash: "0123"}, {Host: "canary", GitHash: "abcdef"}}
+	req := httptest.NewRequest(http.MethodGet, "/svc/iface", nil)
+
+	hosts := func(as []meta.MicroAPP) (s []string) {
+		for _, a := range as {
+			s = append(s, a.Host)
+		}
+		return
+	}
+
+	cases := []struct {
+		service int64
+		app     int64
+		apps    []meta.MicroAPP
+		expect  []string
+	}{
+		{1, 7, apps, []string{"canary"}},
+		{1, 8, apps, []string{"stable"}},
+		//哪边没有实例就用全部实例
+		{1, 7, apps[:1], []string{"stable"}},
+		{1, 8, apps[1:], []string{"canary"}},
+		{2, 7, apps, []string{"stable", "canary"}},
+	}
+
+	for _, c := range cases {
+		iface := &meta.Interface{Service: meta.Service{ID: c.service}}
+		got := hosts(canaryApps("s1", &meta.Application{ID: c.app}, iface, req, c.apps))
+		if len(got) != len(c.expect) {
+			t.Fatalf("service:%d app:%d expect:%v, got:%v", c.service, c.app, c.expect, got)
+		}
+		for i := range got {
+			if got[i] != c.expect[i] {
+				t.Fatalf("service:%d app:%d expect:%v, got:%v", c.service, c.app, c.expect, got)
+			}
+		}
+	}
+}
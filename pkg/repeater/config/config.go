@@ -13,6 +13,10 @@ type etcdConfig struct {
 
 type cacheConfig struct {
 	Timeout int
+	// WatchTimeout 监控manager修改事件正常时使用的超时时间, 监控中断时退回到Timeout.
+	WatchTimeout int `cfg_default:"3600"`
+	// MaxSize 最多缓存的个数, 超过时删除最早添加的.
+	MaxSize int `cfg_default:"100000"`
 }
 
 type ssoConfig struct {
@@ -26,6 +30,156 @@ type rbacConfig struct {
 	Token string
 }
 
+type traceConfig struct {
+	Exporter string `cfg_default:"none"`
+	Endpoint string `cfg_default:"http://127.0.0.1:4318"`
+	File     string `cfg_default:"./logs/trace.log"`
+}
+
+type tokenConfig struct {
+	// LegacyUntil 老格式token的截止时间, 格式为2006-01-02 15:04:05, none表示一直可用.
+	LegacyUntil string `cfg_default:"none"`
+}
+
+type signatureConfig struct {
+	// MaxSkew 签名时间与当前时间允许的最大误差, 单位秒.
+	MaxSkew int `cfg_default:"300"`
+}
+
+type networkConfig struct {
+	// TrustedProxies 可信代理网段, 逗号分隔, 只有来自这些地址的X-Forwarded-For才会使用.
+	TrustedProxies string `cfg_default:"none"`
+	// Internal 内网网段, 逗号分隔, 内部接口只允许这些地址调用.
+	Internal string `cfg_default:"10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,127.0.0.0/8,::1/128"`
+}
+
+type mockConfig struct {
+	// Enable 所有接口都返回模拟数据.
+	Enable bool `cfg_default:"false"`
+	// Latency 模拟延迟, 单位毫秒.
+	Latency int `cfg_default:"0"`
+	// ErrorRate 模拟出错的百分比.
+	ErrorRate int `cfg_default:"0"`
+}
+
+type redactConfig struct {
+	// Fields 所有接口日志中都要脱敏的字段, 逗号分隔.
+	Fields string `cfg_default:"password,passwd,secret,token,idcard"`
+	// Headers 所有接口日志中都要脱敏的头, 逗号分隔.
+	Headers string `cfg_default:"Token,Authorization,Cookie,X-Signature"`
+	// MaxSize 日志中body的最大长度, 0不限制.
+	MaxSize int `cfg_default:"4096"`
+}
+
+type retryConfig struct {
+	// BudgetPercent 每个后端在一个窗口内重试次数不超过请求数的百分比.
+	BudgetPercent int `cfg_default:"20"`
+	// BudgetMin 每个窗口内不受比例限制的最少重试次数.
+	BudgetMin int `cfg_default:"10"`
+	// BudgetWindow 统计窗口, 单位秒.
+	BudgetWindow int `cfg_default:"10"`
+}
+
+type mirrorConfig struct {
+	// Timeout 镜像请求超时, 单位秒.
+	Timeout int `cfg_default:"10"`
+	// MaxConcurrent 同时进行的镜像请求上限, 超过时直接丢弃.
+	MaxConcurrent int `cfg_default:"100"`
+}
+
+type adminConfig struct {
+	// Addr 管理接口单独监听的地址, none表示使用网关端口的/_admin/路径.
+	Addr string `cfg_default:"none"`
+	// Token 请求头Admin-Token需要与它一致, none表示关闭管理接口.
+	Token string `cfg_default:"none"`
+}
+
+type spoolConfig struct {
+	// Dir 数据库不可用时统计数据落盘的目录, none表示不落盘.
+	Dir string `cfg_default:"./spool"`
+	// MaxSize 落盘文件的最大长度, 单位MB, 超过后新的统计直接丢弃.
+	MaxSize int `cfg_default:"100"`
+}
+
+type tlsConfig struct {
+	// Addr https监听地址, none表示不开启.
+	Addr string `cfg_default:"none"`
+	// CertDir 证书目录, 证书xxx.crt与私钥xxx.key成对存放, default.crt为默认证书, none表示只使用manager上传的证书.
+	CertDir string `cfg_default:"none"`
+	// Reload 重新加载证书的间隔, 单位秒, manager修改证书时会立即加载.
+	Reload int `cfg_default:"60"`
+	// Redirect http请求重定向到https.
+	Redirect bool `cfg_default:"false"`
+	// ClientCA 验证客户端证书的CA文件, none表示不验证客户端证书.
+	ClientCA string `cfg_default:"none"`
+	// RequireClientCert 必须提供客户端证书, 否则只在提供时验证.
+	RequireClientCert bool `cfg_default:"false"`
+}
+
+type grpcConfig struct {
+	// H2C http端口接受明文http2(prior knowledge), gRPC客户端不使用TLS时需要.
+	H2C bool `cfg_default:"true"`
+}
+
+type limitConfig struct {
+	// MaxBodySize 请求body的最大长度, 单位KB, 0不限制, 接口可以单独设置.
+	MaxBodySize int `cfg_default:"10240"`
+	// MaxHeaderSize 请求头的最大长度, 单位KB, 解析时超过它的请求直接断开, 接口只能设置得更小.
+	MaxHeaderSize int `cfg_default:"64"`
+	// ReadHeaderTimeout 读取请求头的超时, 单位秒.
+	ReadHeaderTimeout int `cfg_default:"10"`
+	// ReadTimeout 读取整个请求的超时, 单位秒, 0不限制, https上的gRPC流式接口也受它限制.
+	ReadTimeout int `cfg_default:"60"`
+	// IdleTimeout keep-alive连接的空闲超时, 单位秒.
+	IdleTimeout int `cfg_default:"120"`
+	// MaxConnsPerIP 每个客户端ip同时打开的连接数, 0不限制, 可信代理不受限制.
+	MaxConnsPerIP int `cfg_default:"0"`
+}
+
+type errorConfig struct {
+	// DocURL 错误码文档地址, 返回时加上#错误码, none表示不返回.
+	DocURL string `cfg_default:"none"`
+}
+
+type shutdownConfig struct {
+	// Timeout 退出时等待正在处理的请求的最长时间, 单位秒, 超过后直接关闭连接.
+	Timeout int `cfg_default:"30"`
+}
+
+type shedConfig struct {
+	// Enable 按后端自适应限制并发, 过载时先拒绝普通接口.
+	Enable bool `cfg_default:"false"`
+	// InitLimit 每个后端初始的并发上限.
+	InitLimit int `cfg_default:"100"`
+	// MinLimit 并发上限的最小值.
+	MinLimit int `cfg_default:"10"`
+	// MaxLimit 并发上限的最大值.
+	MaxLimit int `cfg_default:"1000"`
+	// Tolerance 延迟超过最小延迟的百分比时认为后端过载.
+	Tolerance int `cfg_default:"200"`
+	// Backoff 过载时并发上限下调到原来的百分比.
+	Backoff int `cfg_default:"90"`
+	// Reserve 给重要接口保留的并发百分比, 普通接口只能使用剩下的部分.
+	Reserve int `cfg_default:"20"`
+	// Window 重新测量最小延迟的间隔, 单位秒.
+	Window int `cfg_default:"60"`
+}
+
+type quotaConfig struct {
+	// Sync 调用配额的计数写入数据库并读回总数的间隔, 单位秒, 多个网关之间可能超出这段时间的调用量.
+	Sync int `cfg_default:"5"`
+}
+
+type logConfig struct {
+	// Level 日志级别, 如debug, info, warning, error, none表示不修改.
+	Level string `cfg_default:"none"`
+}
+
+type reloadConfig struct {
+	// Watch 检查配置文件修改的间隔, 单位秒, 0表示只在收到SIGHUP时重新加载.
+	Watch int `cfg_default:"0"`
+}
+
 type serverConfig struct {
 	SecretKey string
 	BuildPath string
@@ -36,12 +190,31 @@ type serverConfig struct {
 }
 
 type Config struct {
-	DB     orm.DB
-	ETCD   etcdConfig
-	Server serverConfig
-	Cache  cacheConfig
-	RBAC   rbacConfig
-	SSO    ssoConfig
+	DB        orm.DB
+	ETCD      etcdConfig
+	Server    serverConfig
+	Cache     cacheConfig
+	RBAC      rbacConfig
+	SSO       ssoConfig
+	Trace     traceConfig
+	Token     tokenConfig
+	Signature signatureConfig
+	Network   networkConfig
+	Mock      mockConfig
+	Redact    redactConfig
+	Retry     retryConfig
+	Mirror    mirrorConfig
+	Admin     adminConfig
+	Spool     spoolConfig
+	TLS       tlsConfig
+	GRPC      grpcConfig
+	Limit     limitConfig
+	Error     errorConfig
+	Log       logConfig
+	Reload    reloadConfig
+	Shutdown  shutdownConfig
+	Shed      shedConfig
+	Quota     quotaConfig
 }
 
 var (
@@ -52,3 +225,17 @@ var (
 func Load() error {
 	return dcfg.LoadConfig(*cfgPath, &Repeater)
 }
+
+// Path 配置文件路径.
+func Path() string {
+	return *cfgPath
+}
+
+// Parse 读取配置文件到新的结构中, 不修改当前配置, 用于重新加载前的检查.
+func Parse() (*Config, error) {
+	var c Config
+	if err := dcfg.LoadConfig(*cfgPath, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
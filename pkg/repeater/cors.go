@@ -0,0 +1,149 @@
+package repeater
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+const (
+	corsDefaultMethods = "GET,POST,PUT,DELETE"
+)
+
+// splitList 逗号分隔的列表, 去掉空白.
+func splitList(s string) []string {
+	var ls []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ls = append(ls, v)
+		}
+	}
+	return ls
+}
+
+// matchOrigin 来源是否在允许列表中, 支持*及https://*.example.com这样的通配符.
+func matchOrigin(origins, origin string) bool {
+	origin = strings.ToLower(origin)
+
+	for _, p := range splitList(strings.ToLower(origins)) {
+		if p == "*" || p == origin {
+			return true
+		}
+
+		i := strings.IndexByte(p, '*')
+		if i < 0 {
+			continue
+		}
+
+		prefix, suffix := p[:i], p[i+1:]
+		if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsFold 不区分大小写查找.
+func containsFold(ls []string, v string) bool {
+	for _, l := range ls {
+		if strings.EqualFold(l, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPreflight 浏览器发的跨域预检请求.
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions && req.Header.Get("Origin") != "" && req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// allowOrigin 设置允许的来源, 带凭证时不能返回*.
+func allowOrigin(h http.Header, c *meta.Cors, origin string) {
+	h.Add("Vary", "Origin")
+
+	if strings.TrimSpace(c.Origins) == "*" && !c.Credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if c.Credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// preflight 网关直接回复预检请求, 不需要Token, 不允许时返回403且不带跨域头.
+func preflight(w http.ResponseWriter, req *http.Request, c *meta.Cors) {
+	origin := req.Header.Get("Origin")
+	method := req.Header.Get("Access-Control-Request-Method")
+
+	h := w.Header()
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	methods := c.Methods
+	if methods == "" {
+		methods = corsDefaultMethods
+	}
+
+	if c.ID == 0 || !matchOrigin(c.Origins, origin) || !containsFold(splitList(methods), method) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	allowOrigin(h, c, origin)
+	h.Set("Access-Control-Allow-Methods", strings.Join(splitList(methods), ","))
+
+	if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		if strings.TrimSpace(c.Headers) == "*" {
+			h.Set("Access-Control-Allow-Headers", headers)
+		} else if c.Headers != "" {
+			h.Set("Access-Control-Allow-Headers", strings.Join(splitList(c.Headers), ","))
+		}
+	}
+
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(c.MaxAge))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// corsHeader 实际请求的跨域头, 来源不在允许列表中时不返回, 由浏览器拦截.
+func corsHeader(h http.Header, c *meta.Cors, origin string) {
+	if c.ID == 0 || !matchOrigin(c.Origins, origin) {
+		return
+	}
+
+	allowOrigin(h, c, origin)
+	h.Set("Access-Control-Expose-Headers", "Session")
+}
+
+// getCors 根据请求路径中的服务查询跨域配置, 没有配置时返回ID为0的空配置.
+func (dc *dbCache) getCors(path string) (*meta.Cors, error) {
+	ps := strings.SplitN(path, "/", 3)
+	if len(ps) < 2 || ps[1] == "" {
+		return nil, errors.Trace(errInvalidPath)
+	}
+
+	key := "\x0d" + ps[1]
+	if v := dc.cache.Get(key); v != nil {
+		return v.(*meta.Cors), nil
+	}
+
+	gen := dc.cache.Gen()
+
+	var c meta.Cors
+	if err := dc.queryDB(dc.selCors, []interface{}{ps[1]}, []interface{}{&c.ID, &c.ServiceID, &c.Origins, &c.Methods, &c.Headers, &c.Credentials, &c.MaxAge}); err != nil && errors.Cause(err) != errNotFound {
+		return nil, errors.Trace(err)
+	}
+
+	dc.cache.AddSince(key, &c, gen)
+	return &c, nil
+}
@@ -0,0 +1,69 @@
+package repeater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	cases := []struct {
+		origins string
+		origin  string
+		ok      bool
+	}{
+		{"*", "http://a.com", true},
+		{"https://a.com, https://b.com", "https://B.com", true},
+		{"https://a.com", "http://a.com", false},
+		{"https://*.example.com", "https://x.example.com", true},
+		{"https://*.example.com", "https://x.y.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "http://x.example.com", false},
+		{"*.example.com", "http://x.example.com", true},
+		{"https://*.example.com", "https://evilexample.com", false},
+	}
+
+	for _, c := range cases {
+		if matchOrigin(c.origins, c.origin) != c.ok {
+			t.Fatalf("origins:%s origin:%s expect:%v", c.origins, c.origin, c.ok)
+		}
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	c := &meta.Cors{ID: 1, Origins: "https://*.example.com", Methods: "GET,POST", Headers: "*", Credentials: true, MaxAge: 600}
+
+	req := httptest.NewRequest(http.MethodOptions, "/svc/iface", nil)
+	req.Header.Set("Origin", "https://a.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Token, Content-Type")
+
+	if !isPreflight(req) {
+		t.Fatalf("expect preflight")
+	}
+
+	w := httptest.NewRecorder()
+	preflight(w, req, c)
+
+	h := w.Header()
+	if w.Code != http.StatusNoContent || h.Get("Access-Control-Allow-Origin") != "https://a.example.com" ||
+		h.Get("Access-Control-Allow-Credentials") != "true" || h.Get("Access-Control-Allow-Headers") != "Token, Content-Type" ||
+		h.Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("invalid preflight response:%d %v", w.Code, h)
+	}
+
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	w = httptest.NewRecorder()
+	preflight(w, req, c)
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expect forbidden, get:%d %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	preflight(w, req, &meta.Cors{})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expect forbidden without cors, get:%d", w.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package repeater
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+)
+
+const (
+	drainCheckInterval = 100 * time.Millisecond
+)
+
+var (
+	// inflight 正在处理的请求, h2c及gRPC的连接被接管后Shutdown不再等待, 只能按请求计数.
+	inflight int64
+)
+
+// Drain 等待正在处理的请求及镜像请求结束, 超过ctx的期限时返回错误, 剩下的请求直接丢弃.
+func Drain(ctx context.Context) error {
+	t := time.NewTicker(drainCheckInterval)
+	defer t.Stop()
+
+	for atomic.LoadInt64(&inflight) > 0 {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return errors.Annotatef(ctx.Err(), "%d requests not finished", atomic.LoadInt64(&inflight))
+		}
+	}
+
+	//占满所有镜像槽位, 说明镜像请求都结束了, 新的镜像请求也会直接丢弃
+	for i := 0; i < cap(mirrorSlots); i++ {
+		select {
+		case mirrorSlots <- struct{}{}:
+		case <-ctx.Done():
+			return errors.Annotatef(ctx.Err(), "%d mirror requests not finished", len(mirrorSlots))
+		}
+	}
+
+	log.Infof("drain finished")
+	return nil
+}
@@ -0,0 +1,33 @@
+package repeater
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	mirrorSlots = make(chan struct{}, 2)
+	atomic.StoreInt64(&inflight, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+
+	if err := Drain(ctx); err == nil {
+		t.Fatalf("expect timeout with request in flight")
+	}
+
+	atomic.StoreInt64(&inflight, 0)
+	mirrorSlots = make(chan struct{}, 2)
+
+	if err := Drain(context.Background()); err != nil {
+		t.Fatalf("drain error:%v", err)
+	}
+
+	select {
+	case mirrorSlots <- struct{}{}:
+		t.Fatalf("mirror slots should be full after drain")
+	default:
+	}
+}
@@ -0,0 +1,68 @@
+package repeater
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/repeater/config"
+)
+
+// apiError 返回给调用方的错误, 内部细节只记录在日志中.
+type apiError struct {
+	Status  int `json:"-"`
+	Code    string
+	Message string
+	Session string
+	Doc     string `json:",omitempty"`
+}
+
+// errorCodes 内部错误到返回码的映射, 没有的都是InternalError.
+var errorCodes = map[error]apiError{
+	errInvalidPath:      {Status: http.StatusNotFound, Code: "InterfaceNotFound", Message: "interface not found"},
+	errNotFound:         {Status: http.StatusNotFound, Code: "NotFound", Message: "resource not found"},
+	errInvalidToken:     {Status: http.StatusNotFound, Code: "InvalidToken", Message: "invalid token"},
+	errNotFoundToken:    {Status: http.StatusUnauthorized, Code: "MissingToken", Message: "token not found"},
+	errInvalidSign:      {Status: http.StatusUnauthorized, Code: "InvalidSignature", Message: "invalid signature"},
+	errForbidden:        {Status: http.StatusForbidden, Code: "Forbidden", Message: "access denied"},
+	errInvalidArgument:  {Status: http.StatusBadRequest, Code: "InvalidArgument", Message: "invalid argument"},
+	errMethodNotAllowed: {Status: http.StatusMethodNotAllowed, Code: "MethodNotAllowed", Message: "method not allowed"},
+	errBodyTooLarge:     {Status: http.StatusRequestEntityTooLarge, Code: "BodyTooLarge", Message: "request body too large"},
+	errHeaderTooLarge:   {Status: http.StatusRequestHeaderFieldsTooLarge, Code: "HeaderTooLarge", Message: "request header too large"},
+	errRequestTimeout:   {Status: http.StatusRequestTimeout, Code: "RequestTimeout", Message: "request timeout"},
+	errBackend:          {Status: http.StatusBadGateway, Code: "BackendError", Message: "backend unavailable"},
+	errOverloaded:       {Status: http.StatusServiceUnavailable, Code: "Overloaded", Message: "service overloaded, retry later"},
+	errQuotaExceeded:    {Status: http.StatusTooManyRequests, Code: "QuotaExceeded", Message: "call quota exceeded"},
+	errMock:             {Status: http.StatusInternalServerError, Code: "MockError", Message: "mock error"},
+}
+
+var errInternal = apiError{Status: http.StatusInternalServerError, Code: "InternalError", Message: "internal error"}
+
+// publicError 找到错误对应的返回码, 参数校验的错误信息是给调用方看的, 原样返回.
+func publicError(err error) apiError {
+	e, ok := errorCodes[errors.Cause(err)]
+	if !ok {
+		return errInternal
+	}
+
+	if errors.Cause(err) == errInvalidArgument {
+		e.Message = err.Error()
+	}
+
+	return e
+}
+
+func (r *repeater) writeError(w http.ResponseWriter, err error) {
+	e := publicError(err)
+	e.Session = w.Header().Get("Session")
+	if u := config.Repeater.Error.DocURL; u != "none" {
+		e.Doc = u + "#" + e.Code
+	}
+
+	buf, _ := json.Marshal(e)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	w.Write(buf)
+}
@@ -0,0 +1,52 @@
+package repeater
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/repeater/config"
+)
+
+func TestWriteError(t *testing.T) {
+	config.Repeater.Error.DocURL = "https://doc.example.com/errors"
+
+	cases := []struct {
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{errors.Annotatef(errNotFound, "sql:%#v, argv:%#v", "select secret from application", 1), http.StatusNotFound, "NotFound", "resource not found"},
+		{errors.Trace(errors.Annotatef(errInvalidArgument, "key:%s not found in %s", "id", "URI")), http.StatusBadRequest, "InvalidArgument", "key:id not found in URI: invalid argument"},
+		{errors.Wrap(errors.New("dial tcp 10.0.0.1:80: connection refused"), errBackend), http.StatusBadGateway, "BackendError", "backend unavailable"},
+		{errors.New("panic: runtime error"), http.StatusInternalServerError, "InternalError", "internal error"},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		w.Header().Set("Session", "s1")
+		(&repeater{}).writeError(w, c.err)
+
+		if w.Code != c.status {
+			t.Fatalf("err:%v expect status:%d, got:%d", c.err, c.status, w.Code)
+		}
+
+		var e apiError
+		if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+			t.Fatalf("invalid body:%s, error:%v", w.Body.String(), err)
+		}
+
+		if e.Code != c.code || e.Message != c.message || e.Session != "s1" || e.Doc != "https://doc.example.com/errors#"+c.code {
+			t.Fatalf("err:%v unexpected response:%+v", c.err, e)
+		}
+
+		if strings.Contains(w.Body.String(), "sql") || strings.Contains(w.Body.String(), "10.0.0.1") {
+			t.Fatalf("internal details leaked:%s", w.Body.String())
+		}
+	}
+}
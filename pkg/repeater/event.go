@@ -0,0 +1,213 @@
+package repeater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+	"go.etcd.io/etcd/client/v3"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util/etcd"
+)
+
+const (
+	watchRetryInterval = time.Second
+)
+
+// watch 监控manager的修改事件, 监控正常时缓存使用较长的超时时间, 中断时退回到Cache.Timeout.
+func (dc *dbCache) watch(c *etcd.Client) {
+	var rev int64
+
+	for {
+		next, err := c.WatchPrefixRev(meta.EventPrefix, rev, func() {
+			log.Infof("watch %s success, rev:%d", meta.EventPrefix, rev)
+			atomic.StoreInt32(&dc.watching, 1)
+			dc.resetTimeout()
+		}, dc.onEvent)
+
+		atomic.StoreInt32(&dc.watching, 0)
+		dc.resetTimeout()
+
+		if errors.Cause(err) == context.Canceled {
+			log.Infof("watch %s stop", meta.EventPrefix)
+			return
+		}
+
+		if errors.Cause(err) == etcd.ErrCompacted {
+			//中间的事件丢了, 只能全部重新加载
+			dc.cache.Purge()
+		}
+
+		log.Errorf("watch %s error:%v, retry from rev:%d", meta.EventPrefix, errors.ErrorStack(err), next)
+		rev = next
+		time.Sleep(watchRetryInterval)
+	}
+}
+
+// resetTimeout 按监控状态设置缓存超时, 重新加载配置后也调用它.
+func (dc *dbCache) resetTimeout() {
+	if atomic.LoadInt32(&dc.watching) == 1 {
+		dc.cache.SetTimeout(int64(config.Repeater.Cache.WatchTimeout))
+		return
+	}
+	dc.cache.SetTimeout(int64(config.Repeater.Cache.Timeout))
+}
+
+func (dc *dbCache) onEvent(ev clientv3.Event) {
+	if ev.Type != clientv3.EventTypePut {
+		return
+	}
+
+	var e meta.Event
+	if err := json.Unmarshal(ev.Kv.Value, &e); err != nil {
+		log.Errorf("invalid event key:%s, value:%s, error:%v", ev.Kv.Key, ev.Kv.Value, err)
+		return
+	}
+
+	dc.evict(e)
+	log.Infof("event:%+v, rev:%d, evict cache", e, ev.Kv.ModRevision)
+}
+
+// evict 清理事件对应的缓存, 不知道上级id时按缓存的内容查找.
+func (dc *dbCache) evict(e meta.Event) {
+	c := dc.cache
+
+	switch e.Table {
+	case "application":
+		c.Delete(fmt.Sprintf("\x01%d", e.ID))
+		c.Delete(fmt.Sprintf("\x06%d", e.ID))
+		c.DeleteFunc("\x0c", func(string, interface{}) bool { return true })
+		c.DeleteFunc(fmt.Sprintf("\x03%d.", e.ID), func(string, interface{}) bool { return true })
+
+	case "relation":
+		c.DeleteFunc("\x03", func(_ string, v interface{}) bool {
+			id, ok := v.(int64)
+			return ok && id == e.ID
+		})
+
+	case "service":
+		//新加的服务之前缓存的是不存在的路径
+		c.DeleteFunc("/", func(_ string, v interface{}) bool {
+			i, ok := v.(*meta.Interface)
+			return ok && (i.Service.ID == e.ID || i.ID == 0)
+		})
+		c.Delete(fmt.Sprintf("\x05%d", e.ID))
+		c.DeleteFunc("\x0d", func(string, interface{}) bool { return true })
+		c.Delete(fmt.Sprintf("\x0e%d", e.ID))
+
+	case "interface":
+		//路径模式变化后按模式匹配到的接口可能不同, 都重新匹配
+		c.DeleteFunc("/", func(_ string, v interface{}) bool {
+			i, ok := v.(*meta.Interface)
+			return ok && (i.ID == e.ID || i.ID == 0 || i.Params != nil)
+		})
+		c.DeleteFunc("\x0e", func(string, interface{}) bool { return true })
+		c.Delete(fmt.Sprintf("\x02%d", e.ID))
+		c.Delete(fmt.Sprintf("\x04%d", e.ID))
+		c.Delete(fmt.Sprintf("\x09%d", e.ID))
+		c.Delete(fmt.Sprintf("\x0a%d", e.ID))
+		c.Delete(fmt.Sprintf("\x0b%d", e.ID))
+		suffix := fmt.Sprintf(".%d", e.ID)
+		c.DeleteFunc("\x03", func(k string, _ interface{}) bool {
+			return strings.HasSuffix(k, suffix)
+		})
+
+	case "variable":
+		if e.Parent != 0 {
+			c.Delete(fmt.Sprintf("\x02%d", e.Parent))
+			c.Delete(fmt.Sprintf("\x09%d", e.Parent))
+			return
+		}
+		//不知道接口id时, 脱敏规则全部重新生成
+		c.DeleteFunc("\x09", func(string, interface{}) bool { return true })
+		c.DeleteFunc("\x02", func(_ string, v interface{}) bool {
+			for _, vr := range v.([]*meta.Variable) {
+				if vr.ID == e.ID {
+					return true
+				}
+			}
+			return false
+		})
+
+	case "transform":
+		if e.Parent != 0 {
+			c.Delete(fmt.Sprintf("\x04%d", e.Parent))
+			return
+		}
+		c.DeleteFunc("\x04", func(_ string, v interface{}) bool {
+			for _, t := range v.([]*meta.Transform) {
+				if t.ID == e.ID {
+					return true
+				}
+			}
+			return false
+		})
+
+	case "canary":
+		if e.Parent != 0 {
+			c.Delete(fmt.Sprintf("\x05%d", e.Parent))
+			return
+		}
+		c.DeleteFunc("\x05", func(_ string, v interface{}) bool {
+			return v.(*meta.Canary).ID == e.ID
+		})
+
+	case "backend_policy":
+		if e.Parent != 0 {
+			c.Delete(fmt.Sprintf("\x0a%d", e.Parent))
+			return
+		}
+		c.DeleteFunc("\x0a", func(_ string, v interface{}) bool {
+			return v.(*meta.BackendPolicy).ID == e.ID
+		})
+
+	case "mirror":
+		if e.Parent != 0 {
+			c.Delete(fmt.Sprintf("\x0b%d", e.Parent))
+			return
+		}
+		c.DeleteFunc("\x0b", func(_ string, v interface{}) bool {
+			return v.(*meta.Mirror).ID == e.ID
+		})
+
+	case "token_key":
+		c.DeleteFunc("\x07", func(string, interface{}) bool { return true })
+
+	case "app_token":
+		c.DeleteFunc("\x08", func(string, interface{}) bool { return true })
+
+	case "cors":
+		//没有配置时缓存的是空配置, 按服务路径找不到, 全部清理
+		c.DeleteFunc("\x0d", func(string, interface{}) bool { return true })
+
+	case "quota":
+		//新加的配额对应的缓存是空列表, 按id找不到, 全部清理
+		c.DeleteFunc("\x0f", func(string, interface{}) bool { return true })
+
+	case "certificate":
+		certs.notify()
+
+	case "route":
+		c.DeleteFunc("/", func(_ string, v interface{}) bool {
+			i, ok := v.(*meta.Interface)
+			return ok && i.Service.ID == e.ID && (i.ID == 0 || i.Params != nil)
+		})
+		key := fmt.Sprintf("\x0e%d", e.ID)
+		if e.Routes == nil {
+			c.Delete(key)
+			return
+		}
+		//manager推送的快照, 不用再查数据库
+		c.Add(key, compileRoutes(e.ID, e.Routes))
+
+	default:
+		log.Errorf("unknown event table:%s, event:%+v", e.Table, e)
+	}
+}
@@ -0,0 +1,77 @@
+package repeater
+
+import (
+	"testing"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+func TestEvict(t *testing.T) {
+	c := &dbCache{cache: newTTLCache(60)}
+
+	c.cache.Add("\x011", &meta.Application{ID: 1})
+	c.cache.Add("\x031.7", int64(100))
+	c.cache.Add("\x032.7", int64(101))
+	c.cache.Add("\x032.8", int64(102))
+	c.cache.Add("/svc/a", &meta.Interface{ID: 7, Service: meta.Service{ID: 3}})
+	c.cache.Add("/svc/b", &meta.Interface{ID: 8, Service: meta.Service{ID: 3}})
+	c.cache.Add("\x027", []*meta.Variable{{ID: 20}, {ID: 21}})
+	c.cache.Add("\x028", []*meta.Variable{{ID: 22}})
+
+	c.evict(meta.Event{Table: "relation", ID: 101})
+	if c.cache.Get("\x032.7") != nil || c.cache.Get("\x031.7") == nil {
+		t.Fatalf("relation evict failed")
+	}
+
+	c.evict(meta.Event{Table: "variable", ID: 21})
+	if c.cache.Get("\x027") != nil || c.cache.Get("\x028") == nil {
+		t.Fatalf("variable evict failed")
+	}
+
+	c.evict(meta.Event{Table: "application", ID: 1})
+	if c.cache.Get("\x011") != nil || c.cache.Get("\x031.7") != nil || c.cache.Get("\x032.8") == nil {
+		t.Fatalf("application evict failed")
+	}
+
+	c.evict(meta.Event{Table: "interface", ID: 8})
+	if c.cache.Get("/svc/b") != nil || c.cache.Get("\x032.8") != nil || c.cache.Get("\x028") != nil || c.cache.Get("/svc/a") == nil {
+		t.Fatalf("interface evict failed")
+	}
+
+	c.evict(meta.Event{Table: "service", ID: 3})
+	if c.cache.Get("/svc/a") != nil {
+		t.Fatalf("service evict failed")
+	}
+}
+
+func TestEvictDuringLoad(t *testing.T) {
+	c := &dbCache{cache: newTTLCache(60)}
+
+	//读取数据库前记下gen, 读取期间接口被修改
+	gen := c.cache.Gen()
+	c.evict(meta.Event{Table: "interface", ID: 7})
+
+	if c.cache.AddSince("/svc/a", &meta.Interface{ID: 7}, gen) || c.cache.Get("/svc/a") != nil {
+		t.Fatalf("stale value added after evict")
+	}
+
+	gen = c.cache.Gen()
+	if !c.cache.AddSince("/svc/a", &meta.Interface{ID: 7}, gen) || c.cache.Get("/svc/a") == nil {
+		t.Fatalf("add without evict failed")
+	}
+}
+
+func TestTTLCacheMaxSize(t *testing.T) {
+	c := newTTLCache(60)
+	c.SetMaxSize(2)
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	//更新后移到最前面, 超过上限时先删除b
+	c.Add("a", 3)
+	c.Add("c", 4)
+
+	if c.Get("b") != nil || c.Get("a") != 3 || c.Get("c") != 4 {
+		t.Fatalf("unexpected items a:%v b:%v c:%v", c.Get("a"), c.Get("b"), c.Get("c"))
+	}
+}
@@ -0,0 +1,339 @@
+package repeater
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"dearcode.net/crab/http/server"
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+	"golang.org/x/net/http2"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util/signature"
+	"dearcode.net/doodle/pkg/util/trace"
+)
+
+const (
+	grpcConnectTimeout = 10 * time.Second
+)
+
+// gRPC状态码, 只列出网关用到的.
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcNotFound          = 5
+	grpcPermissionDenied  = 7
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+	grpcUnauthenticated   = 16
+)
+
+var (
+	// grpcH2C 明文http2后端.
+	grpcH2C = &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.DialTimeout(network, addr, grpcConnectTimeout)
+		},
+	}
+	// grpcTLS https后端.
+	grpcTLS = &http2.Transport{}
+)
+
+// isGRPC http2并且Content-Type是application/grpc.
+func isGRPC(req *http.Request) bool {
+	return req.ProtoMajor == 2 && req.Method == http.MethodPost && strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc")
+}
+
+// methodAllowed gRPC请求只能访问gRPC接口, 反之亦然.
+func methodAllowed(m server.Method, req *http.Request) bool {
+	if m == meta.MethodGRPC || isGRPC(req) {
+		return m == meta.MethodGRPC && isGRPC(req)
+	}
+
+	return m == server.RESTful || req.Method == m.String()
+}
+
+// grpcCode 网关错误对应的gRPC状态码.
+func grpcCode(err error) int {
+	switch errors.Cause(err) {
+	case errForbidden:
+		return grpcPermissionDenied
+	case errInvalidArgument:
+		return grpcInvalidArgument
+	case errInvalidPath, errNotFound:
+		return grpcNotFound
+	case errNotFoundToken, errInvalidSign, errInvalidToken:
+		return grpcUnauthenticated
+	case errMethodNotAllowed:
+		return grpcUnimplemented
+	case errQuotaExceeded, errHeaderTooLarge:
+		return grpcResourceExhausted
+	case errOverloaded:
+		return grpcUnavailable
+	}
+	return grpcInternal
+}
+
+// grpcError 只有header的gRPC错误返回.
+func grpcError(w http.ResponseWriter, code int, msg string) {
+	h := w.Header()
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Grpc-Status", strconv.Itoa(code))
+	h.Set("Grpc-Message", url.PathEscape(msg))
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcBackendURL faas接口从etcd中选后端实例, 否则使用接口配置的地址, 路径保持/package.Service/Method不变.
+func grpcBackendURL(id string, app *meta.Application, iface *meta.Interface, req *http.Request) (string, *meta.MicroAPP, error) {
+	if iface.Service.Version == 1 {
+		apps, err := bs.getMicroAPPs(iface.Backend)
+		if err != nil {
+			return "", nil, errors.Trace(err)
+		}
+		apps = canaryApps(id, app, iface, req, apps)
+		ma := &apps[time.Now().UnixNano()%int64(len(apps))]
+		return fmt.Sprintf("http://%s:%d%s", ma.Host, ma.Port, req.URL.Path), ma, nil
+	}
+
+	return strings.TrimSuffix(iface.Backend, "/") + req.URL.Path, nil, nil
+}
+
+// grpcStatus 从trailer中取状态, 只有header的返回在header中.
+func grpcStatus(resp *http.Response) (string, string) {
+	if s := resp.Trailer.Get("Grpc-Status"); s != "" {
+		return s, resp.Trailer.Get("Grpc-Message")
+	}
+	return resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+}
+
+// serveGRPC 代理gRPC请求, 请求及返回都按流转发, 不读取body.
+// 流不能重放, 所以不支持接口的超时重试策略及重试预算, 也不支持mock, 只受请求头大小、配额及过载保护的限制.
+func (r *repeater) serveGRPC(w http.ResponseWriter, req *http.Request, id string, span *trace.Span, limit requestLimit) {
+	//流式请求不能提前读body, 只支持Token或客户端证书认证
+	req.Header.Del(signature.HeaderSignature)
+
+	as := span.Child("auth", trace.KindInternal)
+	app, iface, err := r.GetInterface(req, id)
+	as.Finish(err)
+	if err != nil {
+		log.Errorf("%s grpc error:%s", id, errors.ErrorStack(err))
+		grpcError(w, grpcCode(err), publicError(err).Message)
+		return
+	}
+	log.Infof("%s app:%s email:%s, grpc interface:%s email:%s", id, app.Name, app.Email, iface.Name, iface.Email)
+
+	span.SetAttr("app.id", strconv.FormatInt(app.ID, 10))
+	span.SetAttr("interface.id", strconv.FormatInt(iface.ID, 10))
+
+	//认证前查接口失败时用的是全局限制, 这里按接口的再检查一次
+	if err = limit.of(iface).checkHeader(req); err != nil {
+		log.Errorf("%s grpc check header error:%s", id, errors.ErrorStack(err))
+		grpcError(w, grpcCode(err), publicError(err).Message)
+		return
+	}
+
+	if mockEnabled(iface) {
+		log.Errorf("%s grpc interface:%d mock not supported", id, iface.ID)
+		grpcError(w, grpcUnimplemented, "mock not supported for grpc interface")
+		return
+	}
+
+	//先占用配额, 没有调用后端时退回
+	qu, err := quotas.take(app.ID, iface.ID)
+	if err != nil {
+		if qu != nil {
+			qu.header(w.Header())
+		}
+		log.Errorf("%s grpc quota error:%s", id, errors.ErrorStack(err))
+		grpcError(w, grpcCode(err), publicError(err).Message)
+		return
+	}
+
+	backend, ma, err := grpcBackendURL(id, app, iface, req)
+	if err != nil {
+		quotas.refund(qu)
+		log.Errorf("%s grpc backend error:%s", id, errors.ErrorStack(err))
+		grpcError(w, grpcUnavailable, errorCodes[errBackend].Message)
+		return
+	}
+
+	out, err := http.NewRequestWithContext(req.Context(), http.MethodPost, backend, req.Body)
+	if err != nil {
+		quotas.refund(qu)
+		log.Errorf("%s grpc new request error:%v", id, err)
+		grpcError(w, grpcInternal, errInternal.Message)
+		return
+	}
+
+	release, err := r.shed.acquire(iface)
+	if err != nil {
+		quotas.refund(qu)
+		log.Errorf("%s grpc shed error:%s", id, errors.ErrorStack(err))
+		grpcError(w, grpcCode(err), publicError(err).Message)
+		return
+	}
+
+	//流转发过程中panic也要归还并发
+	var used time.Duration
+	var overload bool
+	acquired := time.Now()
+	defer func() {
+		if used == 0 {
+			used = time.Since(acquired)
+		}
+		release(used, overload)
+	}()
+
+	if qu != nil {
+		qu.header(w.Header())
+	}
+
+	out.Header = req.Header.Clone()
+	out.Header.Del("Token")
+	out.Header.Set("Session", id)
+	out.Header.Set("Te", "trailers")
+	out.ContentLength = req.ContentLength
+
+	bspan := span.Child("backend", trace.KindClient)
+	bspan.SetAttr("grpc.url", backend)
+	trace.Inject(out.Header, bspan.Context)
+
+	rt := grpcH2C
+	if out.URL.Scheme == "https" {
+		rt = grpcTLS
+	}
+
+	log.Infof("%s grpc backend:%s begin", id, backend)
+
+	b := time.Now()
+	resp, err := rt.RoundTrip(out)
+	if err != nil {
+		used, overload = time.Since(b), true
+		bspan.Finish(err)
+		stats.failed(id, app.ID, iface.ID, err.Error())
+		log.Errorf("%s grpc backend:%s error:%v", id, backend, err)
+		grpcError(w, grpcUnavailable, errorCodes[errBackend].Message)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, vs := range resp.Header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	n, err := copyFlush(w, resp.Body)
+
+	//body结束后才有trailer
+	for k, vs := range resp.Trailer {
+		w.Header()[http.TrailerPrefix+k] = vs
+	}
+
+	used = time.Since(b)
+	cost := int64(used / time.Millisecond)
+	code, msg := grpcStatus(resp)
+	overload = overloaded(resp.StatusCode, err) || code == strconv.Itoa(grpcUnavailable) || code == strconv.Itoa(grpcResourceExhausted)
+
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("invalid http status:%v", resp.StatusCode)
+	}
+	if err == nil && code != strconv.Itoa(grpcOK) {
+		err = fmt.Errorf("grpc status:%s message:%s", code, msg)
+	}
+	bspan.Finish(err)
+
+	if ma != nil {
+		stats.version(iface.ID, ma.GitHash, cost, err == nil)
+	}
+
+	if err != nil {
+		stats.failed(id, app.ID, iface.ID, err.Error())
+		log.Errorf("%s grpc used:%dms end failed, bytes:%d, error:%v", id, cost, n, err)
+		return
+	}
+
+	stats.success(app.ID, iface.ID, cost)
+	log.Infof("%s grpc used:%dms end success, bytes:%d", id, cost, n)
+}
+
+// copyFlush 每次读到数据都立即发给调用方, 流式返回需要.
+func copyFlush(w http.ResponseWriter, r io.Reader) (int64, error) {
+	f, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	var n int64
+
+	for {
+		nr, err := r.Read(buf)
+		if nr > 0 {
+			nw, werr := w.Write(buf[:nr])
+			n += int64(nw)
+			if werr != nil {
+				return n, errors.Trace(werr)
+			}
+			if f != nil {
+				f.Flush()
+			}
+		}
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, errors.Trace(err)
+		}
+	}
+}
+
+// H2C http端口支持明文http2, 收到http2的preface时接管连接交给网关处理, 否则交给h.
+func H2C(h http.Handler) http.Handler {
+	s := &http2.Server{}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "PRI" || req.URL.Path != "*" || req.Proto != "HTTP/2.0" || len(req.Header) != 0 {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "h2c not supported", http.StatusInternalServerError)
+			return
+		}
+
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			log.Errorf("h2c hijack error:%v", err)
+			return
+		}
+
+		//http1只解析了preface的第一行, 剩下的在这里读出来
+		buf := make([]byte, 6)
+		if _, err = io.ReadFull(rw, buf); err != nil || string(buf) != "SM\r\n\r\n" {
+			log.Errorf("h2c invalid preface from %s, error:%v", conn.RemoteAddr(), err)
+			conn.Close()
+			return
+		}
+
+		s.ServeConn(&prefaceConn{Conn: conn, r: io.MultiReader(strings.NewReader(http2.ClientPreface), rw.Reader)}, &http2.ServeConnOpts{Handler: Server})
+	})
+}
+
+// prefaceConn 把完整的preface及缓存的数据还给http2.Server.
+type prefaceConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *prefaceConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,60 @@
+package repeater
+
+import (
+	"net/http"
+	"testing"
+
+	"dearcode.net/crab/http/server"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+func TestMethodAllowed(t *testing.T) {
+	g := &http.Request{Method: http.MethodPost, ProtoMajor: 2, Header: http.Header{"Content-Type": []string{"application/grpc+proto"}}}
+	p := &http.Request{Method: http.MethodPost, ProtoMajor: 1, Header: http.Header{}}
+
+	cases := []struct {
+		m   server.Method
+		req *http.Request
+		ok  bool
+	}{
+		{meta.MethodGRPC, g, true},
+		{meta.MethodGRPC, p, false},
+		{server.POST, g, false},
+		{server.RESTful, g, false},
+		{server.POST, p, true},
+		{server.RESTful, p, true},
+		{server.GET, p, false},
+	}
+
+	for i, c := range cases {
+		if methodAllowed(c.m, c.req) != c.ok {
+			t.Fatalf("case:%d method:%v expect:%v", i, c.m, c.ok)
+		}
+	}
+}
+
+func TestGRPCStatus(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Grpc-Status": []string{"5"}}, Trailer: http.Header{}}
+	if s, _ := grpcStatus(resp); s != "5" {
+		t.Fatalf("expect trailers-only status 5, get:%s", s)
+	}
+
+	resp.Trailer.Set("Grpc-Status", "0")
+	if s, _ := grpcStatus(resp); s != "0" {
+		t.Fatalf("expect trailer status 0, get:%s", s)
+	}
+
+	if c := grpcCode(errors.Trace(errNotFoundToken)); c != grpcUnauthenticated {
+		t.Fatalf("expect unauthenticated, get:%d", c)
+	}
+
+	if c := grpcCode(errors.Trace(errOverloaded)); c != grpcUnavailable {
+		t.Fatalf("expect unavailable, get:%d", c)
+	}
+
+	if c := grpcCode(errors.Trace(errHeaderTooLarge)); c != grpcResourceExhausted {
+		t.Fatalf("expect resource exhausted, get:%d", c)
+	}
+}
@@ -1,11 +1,11 @@
 package repeater
 
 import (
-	"dearcode.net/crab/cache"
 	"dearcode.net/crab/orm"
 	"github.com/juju/errors"
 
 	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util/trace"
 )
 
 var (
@@ -15,10 +15,17 @@ var (
 	dc     *dbCache
 	bs     *backendService
 	stats  *statsCache
+	quotas *quotaCache
 )
 
 // repeater 网关验证模块
 type repeater struct {
+	// nonces 签名请求用过的随机串, 防重放.
+	nonces *ttlCache
+	// budget 后端重试预算.
+	budget *retryBudget
+	// shed 后端自适应并发限制.
+	shed *loadShedder
 }
 
 // Init 初始化HTTP接口.
@@ -27,17 +34,49 @@ func Init() error {
 		return errors.Trace(err)
 	}
 
-	stats = newStatsCache()
+	setLogLevel()
+
+	if err := loadTokenConfig(); err != nil {
+		return errors.Trace(err)
+	}
+
+	if err := loadNetworkConfig(); err != nil {
+		return errors.Trace(err)
+	}
+
+	loadRedactConfig()
+
+	mirrorSlots = make(chan struct{}, config.Repeater.Mirror.MaxConcurrent)
+
+	tc := config.Repeater.Trace
+	if err := trace.Init("repeater", tc.Exporter, tc.Endpoint, tc.File); err != nil {
+		return errors.Trace(err)
+	}
+
+	sp, err := newSpool(config.Repeater.Spool.Dir, config.Repeater.Spool.MaxSize)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	stats = newStatsCache(sp)
 	go stats.run()
 
 	mdb = &config.Repeater.DB
 
-	dc = &dbCache{cache: cache.NewCache(int64(config.Repeater.Cache.Timeout))}
+	dc = &dbCache{cache: newTTLCache(int64(config.Repeater.Cache.Timeout))}
+	dc.cache.SetMaxSize(config.Repeater.Cache.MaxSize)
 	if err := dc.conectDB(); err != nil {
 		return errors.Trace(err)
 	}
 
-	Server = &repeater{}
+	quotas = newQuotaCache()
+	go quotas.run()
+
+	Server = &repeater{
+		nonces: newTTLCache(int64(config.Repeater.Signature.MaxSkew) * 2),
+		budget: newRetryBudget(),
+		shed:   newLoadShedder(),
+	}
 
 	nbs, err := newBackendService()
 	if err != nil {
@@ -45,6 +84,7 @@ func Init() error {
 	}
 
 	go nbs.start()
+	go dc.watch(nbs.etcd)
 
 	if err := nbs.load(); err != nil {
 		return errors.Trace(err)
@@ -55,7 +95,10 @@ func Init() error {
 	return nil
 }
 
-// Stop 结束后端监控.
+// Stop 结束后端监控, 写入剩余的统计.
 func Stop() {
 	bs.stop()
+	quotas.stop()
+	stats.stop()
+	trace.Stop()
 }
@@ -0,0 +1,219 @@
+package repeater
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+)
+
+const (
+	limitBody    = "body"
+	limitHeader  = "header"
+	limitTimeout = "timeout"
+	limitConn    = "conn"
+	limitShed    = "shed"
+)
+
+// requestLimit 请求的大小限制, 单位字节, 0不限制.
+type requestLimit struct {
+	iface  int64
+	body   int64
+	header int64
+}
+
+// getRequestLimit 接口设置了就用接口的, 否则用全局配置, 接口不存在时使用全局配置, 错误由GetInterface返回.
+// 不存在的路径也会缓存, 认证前查接口不会因为随机路径放大数据库查询.
+func getRequestLimit(path string) requestLimit {
+	l := requestLimit{
+		body:   int64(config.Repeater.Limit.MaxBodySize) << 10,
+		header: int64(config.Repeater.Limit.MaxHeaderSize) << 10,
+	}
+
+	iface, err := dc.getInterface(path)
+	if err != nil {
+		return l
+	}
+
+	return l.of(iface)
+}
+
+// of 使用接口设置的限制, 接口没设置的保持不变.
+func (l requestLimit) of(iface *meta.Interface) requestLimit {
+	l.iface = iface.ID
+	if iface.MaxBodySize > 0 {
+		l.body = int64(iface.MaxBodySize) << 10
+	}
+	if iface.MaxHeaderSize > 0 {
+		l.header = int64(iface.MaxHeaderSize) << 10
+	}
+
+	return l
+}
+
+// headerSize 请求行及请求头的长度, 与http1线上的格式一致.
+func headerSize(req *http.Request) int64 {
+	n := len(req.Method) + len(req.RequestURI) + len(req.Proto) + 4
+	for k, vs := range req.Header {
+		for _, v := range vs {
+			n += len(k) + len(v) + 4
+		}
+	}
+	return int64(n)
+}
+
+// checkHeader 请求头超过限制返回errHeaderTooLarge.
+func (l requestLimit) checkHeader(req *http.Request) error {
+	if l.header <= 0 {
+		return nil
+	}
+
+	if n := headerSize(req); n > l.header {
+		stats.limit(l.iface, limitHeader)
+		return errors.Annotatef(errHeaderTooLarge, "header size:%d, limit:%d", n, l.header)
+	}
+
+	return nil
+}
+
+// readBody 读取body, 超过限制返回errBodyTooLarge, 读超时返回errRequestTimeout.
+func (l requestLimit) readBody(req *http.Request) ([]byte, error) {
+	if l.body > 0 && req.ContentLength > l.body {
+		stats.limit(l.iface, limitBody)
+		return nil, errors.Annotatef(errBodyTooLarge, "content length:%d, limit:%d", req.ContentLength, l.body)
+	}
+
+	var r io.Reader = req.Body
+	if l.body > 0 {
+		//多读一个字节用来判断是否超过限制
+		r = io.LimitReader(req.Body, l.body+1)
+	}
+
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			stats.limit(l.iface, limitTimeout)
+			return nil, errors.Annotatef(errRequestTimeout, "read body:%v", err)
+		}
+		return nil, errors.Trace(err)
+	}
+
+	if l.body > 0 && int64(len(buf)) > l.body {
+		stats.limit(l.iface, limitBody)
+		return nil, errors.Annotatef(errBodyTooLarge, "body size exceeds limit:%d", l.body)
+	}
+
+	return buf, nil
+}
+
+// NewServer 按配置设置超时及请求头大小的http.Server.
+func NewServer(h http.Handler) *http.Server {
+	c := config.Repeater.Limit
+	s := &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: time.Duration(c.ReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(c.ReadTimeout) * time.Second,
+		IdleTimeout:       time.Duration(c.IdleTimeout) * time.Second,
+	}
+
+	//解析时留出余量, 稍微超出的请求由ServeHTTP返回431并记录统计
+	if c.MaxHeaderSize > 0 {
+		s.MaxHeaderBytes = c.MaxHeaderSize << 11
+	}
+
+	return s
+}
+
+// LimitListener 限制每个客户端ip同时打开的连接数, 超过时http连接返回429后关闭, https连接直接关闭.
+func LimitListener(ln net.Listener, plain bool) net.Listener {
+	if config.Repeater.Limit.MaxConnsPerIP <= 0 {
+		return ln
+	}
+
+	return &ipLimitListener{
+		Listener: ln,
+		max:      config.Repeater.Limit.MaxConnsPerIP,
+		plain:    plain,
+		conns:    make(map[string]int),
+	}
+}
+
+type ipLimitListener struct {
+	net.Listener
+	max   int
+	plain bool
+	conns map[string]int
+	sync.Mutex
+}
+
+func (l *ipLimitListener) acquire(ip string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.conns[ip] >= l.max {
+		return false
+	}
+	l.conns[ip]++
+	return true
+}
+
+func (l *ipLimitListener) release(ip string) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.conns[ip]--; l.conns[ip] <= 0 {
+		delete(l.conns, ip)
+	}
+}
+
+func (l *ipLimitListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		ip, _, err := net.SplitHostPort(c.RemoteAddr().String())
+		if err != nil {
+			ip = c.RemoteAddr().String()
+		}
+
+		//可信代理后面是很多客户端, 不做限制
+		if proxies, _ := networkLists(); proxies.Contains(net.ParseIP(ip)) {
+			return c, nil
+		}
+
+		if l.acquire(ip) {
+			return &ipLimitConn{Conn: c, release: func() { l.release(ip) }}, nil
+		}
+
+		log.Warningf("client:%s too many connections, limit:%d", ip, l.max)
+		stats.limit(0, limitConn)
+
+		if l.plain {
+			c.SetWriteDeadline(time.Now().Add(time.Second))
+			io.WriteString(c, "HTTP/1.1 429 Too Many Requests\r\nConnection: close\r\nContent-Length: 0\r\n\r\n")
+		}
+		c.Close()
+	}
+}
+
+// ipLimitConn 连接关闭时释放计数.
+type ipLimitConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+func (c *ipLimitConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
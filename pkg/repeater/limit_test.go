@@ -0,0 +1,130 @@
+package repeater
+
+import (
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+)
+
+func TestReadBodyLimit(t *testing.T) {
+	stats = newStatsCache(nil)
+	l := requestLimit{iface: 1, body: 4}
+
+	req := httptest.NewRequest("POST", "/svc/iface", strings.NewReader("1234"))
+	buf, err := l.readBody(req)
+	if err != nil || string(buf) != "1234" {
+		t.Fatalf("read body:%s error:%v", buf, err)
+	}
+
+	req = httptest.NewRequest("POST", "/svc/iface", strings.NewReader("12345"))
+	if _, err = l.readBody(req); errors.Cause(err) != errBodyTooLarge {
+		t.Fatalf("expect body too large, error:%v", err)
+	}
+
+	//没有Content-Length时读到超过限制为止
+	req = httptest.NewRequest("POST", "/svc/iface", strings.NewReader("12345"))
+	req.ContentLength = -1
+	if _, err = l.readBody(req); errors.Cause(err) != errBodyTooLarge {
+		t.Fatalf("expect body too large, error:%v", err)
+	}
+
+	if n := stats.limitEntrys()[limitKey{1, limitBody}]; n != 2 {
+		t.Fatalf("expect 2 body limit, got:%d", n)
+	}
+}
+
+func TestRequestLimitNotFound(t *testing.T) {
+	config.Repeater.Limit.MaxBodySize = 1
+	config.Repeater.Limit.MaxHeaderSize = 2
+
+	//不存在的路径已经缓存, 不会再查数据库
+	dc = &dbCache{cache: newTTLCache(60)}
+	dc.cache.Add("/svc/none", &meta.Interface{Service: meta.Service{ID: 3}})
+	dc.cache.Add("/svc/big", &meta.Interface{ID: 7, Service: meta.Service{ID: 3}, MaxBodySize: 8})
+
+	if l := getRequestLimit("/svc/none"); l.iface != 0 || l.body != 1<<10 || l.header != 2<<10 {
+		t.Fatalf("expect global limit, got:%+v", l)
+	}
+
+	if _, err := dc.getInterface("/svc/none"); errors.Cause(err) != errNotFound {
+		t.Fatalf("expect not found, error:%v", err)
+	}
+
+	if l := getRequestLimit("/svc/big"); l.iface != 7 || l.body != 8<<10 || l.header != 2<<10 {
+		t.Fatalf("expect interface limit, got:%+v", l)
+	}
+
+	//新加接口后不存在的缓存要清理
+	dc.evict(meta.Event{Table: "interface", ID: 9})
+	if dc.cache.Get("/svc/none") != nil || dc.cache.Get("/svc/big") == nil {
+		t.Fatalf("interface evict failed")
+	}
+}
+
+func TestIPLimitListener(t *testing.T) {
+	stats = newStatsCache(nil)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	l := &ipLimitListener{Listener: ln, max: 1, plain: true, conns: make(map[string]int)}
+
+	c1, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+
+	s1, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//第一个连接没关闭时第二个连接被拒绝
+	c2, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- c
+	}()
+
+	buf := make([]byte, 64)
+	n, _ := c2.Read(buf)
+	if !strings.HasPrefix(string(buf[:n]), "HTTP/1.1 429") {
+		t.Fatalf("expect 429, got:%q", buf[:n])
+	}
+
+	//关闭第一个后可以连上
+	s1.Close()
+
+	c3, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c3.Close()
+
+	if s3 := <-accepted; s3 != nil {
+		s3.Close()
+	}
+
+	if n := stats.limitEntrys()[limitKey{0, limitConn}]; n != 1 {
+		t.Fatalf("expect 1 conn limit, got:%d", n)
+	}
+}
@@ -0,0 +1,162 @@
+package repeater
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util"
+	"dearcode.net/doodle/pkg/util/signature"
+)
+
+// mirrorCall 一次镜像请求, 结束后关闭done.
+type mirrorCall struct {
+	id     string
+	mirror *meta.Mirror
+	code   int
+	hash   string
+	cost   time.Duration
+	err    error
+	done   chan struct{}
+}
+
+var (
+	// mirrorSlots 限制同时进行的镜像请求数.
+	mirrorSlots chan struct{}
+)
+
+// mirrorRequest 复制后端请求, 只替换地址, 路径及参数不变.
+func mirrorRequest(m *meta.Mirror, req *http.Request, body []byte) (*http.Request, error) {
+	t, err := url.Parse(m.Target)
+	if err != nil {
+		return nil, errors.Annotatef(err, "invalid mirror target:%s", m.Target)
+	}
+
+	u := *req.URL
+	u.Scheme = t.Scheme
+	u.Host = t.Host
+
+	nr, err := http.NewRequest(req.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	for k, vs := range req.Header {
+		nr.Header[k] = append([]string(nil), vs...)
+	}
+	nr.Header.Set("X-Mirror", "1")
+
+	return nr, nil
+}
+
+// mirror 命中镜像比例时异步发送请求, 返回nil表示本次不镜像.
+func (r *repeater) mirror(id string, iface *meta.Interface, req *http.Request) *mirrorCall {
+	m, err := dc.getMirror(iface.ID)
+	if err != nil {
+		log.Errorf("%s get mirror interface:%d error:%s", id, iface.ID, errors.ErrorStack(err))
+		return nil
+	}
+
+	if m == nil || m.Percent <= 0 || rand.Intn(100) >= m.Percent {
+		return nil
+	}
+
+	select {
+	case mirrorSlots <- struct{}{}:
+	default:
+		log.Warningf("%s mirror interface:%d dropped, too many requests", id, iface.ID)
+		return nil
+	}
+
+	var body []byte
+	if req.Body != nil {
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			<-mirrorSlots
+			log.Errorf("%s read body error:%v", id, err)
+			return nil
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	mr, err := mirrorRequest(m, req, body)
+	if err != nil {
+		<-mirrorSlots
+		log.Errorf("%s mirror interface:%d error:%s", id, iface.ID, errors.ErrorStack(err))
+		return nil
+	}
+
+	c := &mirrorCall{id: id, mirror: m, done: make(chan struct{})}
+
+	go func() {
+		defer func() {
+			<-mirrorSlots
+			close(c.done)
+		}()
+
+		timeout := time.Duration(config.Repeater.Mirror.Timeout) * time.Second
+		b := time.Now()
+		rb, _, code, err := util.DoRequestTimeout(mr, timeout, timeout)
+		c.cost = time.Since(b)
+		c.code, c.err = code, err
+		if err == nil {
+			c.hash = signature.BodyHash(rb)
+		}
+
+		log.Debugf("%s mirror %s code:%d cost:%v err:%v", id, mr.URL, code, c.cost, err)
+	}()
+
+	return c
+}
+
+// compare 等镜像请求结束后与主后端结果对比并记录, 不阻塞主请求.
+func (c *mirrorCall) compare(iface int64, code int, body []byte, cost time.Duration, err error) {
+	if !c.mirror.Diff {
+		return
+	}
+
+	d := c.primary(iface, code, body, cost, err)
+
+	go func() {
+		c.wait(d, err)
+
+		if e := dc.insertMirrorDiff(d); e != nil {
+			log.Errorf("%s insert mirror diff:%+v error:%s", c.id, d, errors.ErrorStack(e))
+		}
+	}()
+}
+
+// primary 记录主后端的结果.
+func (c *mirrorCall) primary(iface int64, code int, body []byte, cost time.Duration, err error) *meta.MirrorDiff {
+	d := &meta.MirrorDiff{
+		InterfaceID:   iface,
+		Session:       c.id,
+		PrimaryStatus: code,
+		PrimaryCost:   int64(cost / time.Millisecond),
+	}
+	if err == nil {
+		d.PrimaryHash = signature.BodyHash(body)
+	}
+
+	return d
+}
+
+// wait 等镜像请求结束, 填上镜像的结果并对比, err是主后端的错误.
+func (c *mirrorCall) wait(d *meta.MirrorDiff, err error) {
+	<-c.done
+
+	d.MirrorStatus = c.code
+	d.MirrorHash = c.hash
+	d.MirrorCost = int64(c.cost / time.Millisecond)
+	if c.err != nil {
+		d.Error = c.err.Error()
+	}
+	d.Same = err == nil && c.err == nil && d.PrimaryStatus == d.MirrorStatus && d.PrimaryHash == d.MirrorHash
+}
@@ -0,0 +1,102 @@
+package repeater
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+)
+
+func TestMirrorRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "http://backend:8080/v1/orders?id=7", nil)
+	req.Header.Set("Session", "s1")
+
+	nr, err := mirrorRequest(&meta.Mirror{Target: "https://shadow:9090/ignored"}, req, []byte("a=1"))
+	if err != nil {
+		t.Fatalf("mirror request error:%v", err)
+	}
+
+	if nr.URL.String() != "https://shadow:9090/v1/orders?id=7" || nr.Method != http.MethodPost {
+		t.Fatalf("invalid mirror url:%s method:%s", nr.URL, nr.Method)
+	}
+
+	if nr.Header.Get("Session") != "s1" || nr.Header.Get("X-Mirror") != "1" || req.Header.Get("X-Mirror") != "" {
+		t.Fatalf("invalid mirror header:%v, origin:%v", nr.Header, req.Header)
+	}
+
+	if body, _ := ioutil.ReadAll(nr.Body); string(body) != "a=1" {
+		t.Fatalf("invalid mirror body:%s", body)
+	}
+}
+
+func TestMirrorSample(t *testing.T) {
+	got := make(chan string, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		got <- req.Header.Get("X-Mirror") + string(body)
+		w.Write([]byte("ok"))
+	}))
+	defer shadow.Close()
+
+	config.Repeater.Mirror.Timeout = 1
+	mirrorSlots = make(chan struct{}, 1)
+	dc = &dbCache{cache: newTTLCache(60)}
+	dc.cache.Add("\x0b1", &meta.Mirror{ID: 1, InterfaceID: 1, Target: shadow.URL, Percent: 0})
+	dc.cache.Add("\x0b2", &meta.Mirror{ID: 2, InterfaceID: 2, Target: shadow.URL, Percent: 100, Diff: true})
+
+	r := &repeater{}
+	newReq := func() *http.Request {
+		return httptest.NewRequest(http.MethodPost, "http://backend/v1/orders", strings.NewReader("a=1"))
+	}
+
+	if c := r.mirror("s1", &meta.Interface{ID: 1}, newReq()); c != nil {
+		t.Fatalf("percent 0 should not mirror")
+	}
+
+	req := newReq()
+	c := r.mirror("s2", &meta.Interface{ID: 2}, req)
+	if c == nil {
+		t.Fatalf("percent 100 should mirror")
+	}
+
+	//没有空闲的槽位时丢弃
+	if r.mirror("s3", &meta.Interface{ID: 2}, newReq()) != nil {
+		t.Fatalf("mirror should be dropped without free slot")
+	}
+
+	if s := <-got; s != "1a=1" {
+		t.Fatalf("invalid mirror request:%s", s)
+	}
+
+	//主请求的body还能再读
+	if body, _ := ioutil.ReadAll(req.Body); string(body) != "a=1" {
+		t.Fatalf("invalid primary body:%s", body)
+	}
+
+	d := c.primary(2, http.StatusOK, []byte("ok"), 0, nil)
+	c.wait(d, nil)
+	if !d.Same || d.MirrorStatus != http.StatusOK || d.Session != "s2" || d.InterfaceID != 2 {
+		t.Fatalf("expect same diff:%+v", d)
+	}
+
+	d = c.primary(2, http.StatusOK, []byte("changed"), 0, nil)
+	c.wait(d, nil)
+	if d.Same || d.PrimaryHash == d.MirrorHash {
+		t.Fatalf("expect different body:%+v", d)
+	}
+
+	d = c.primary(2, 0, nil, 0, errors.New("timeout"))
+	c.wait(d, errors.New("timeout"))
+	if d.Same || d.PrimaryHash != "" {
+		t.Fatalf("primary error should not be same:%+v", d)
+	}
+
+	if len(mirrorSlots) != 0 {
+		t.Fatalf("mirror slot not released")
+	}
+}
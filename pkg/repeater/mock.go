@@ -0,0 +1,141 @@
+package repeater
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+)
+
+const (
+	// mockMaxDepth 防止类型自己引用自己时无限展开.
+	mockMaxDepth = 8
+)
+
+var (
+	errMock = errors.New("mock error")
+)
+
+// mockEnabled 全局开关打开或者接口开启了mock.
+func mockEnabled(iface *meta.Interface) bool {
+	return config.Repeater.Mock.Enable || iface.Mock
+}
+
+// mock 根据接口注册的返回值字段生成json, 不调用后端.
+func (r *repeater) mock(w http.ResponseWriter, id string, iface *meta.Interface) error {
+	latency, rate := config.Repeater.Mock.Latency, config.Repeater.Mock.ErrorRate
+	if iface.Mock {
+		latency, rate = iface.MockLatency, iface.MockErrorRate
+	}
+
+	if latency > 0 {
+		time.Sleep(time.Duration(latency) * time.Millisecond)
+	}
+
+	if rate > 0 && rand.Intn(100) < rate {
+		log.Infof("%s mock interface:%d error, rate:%d", id, iface.ID, rate)
+		return errors.Trace(errMock)
+	}
+
+	vars, err := dc.getVariable(iface.ID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	buf, err := json.Marshal(mockResponse(vars))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	log.Infof("%s mock interface:%d latency:%dms response:%s", id, iface.ID, latency, buf)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Mock", "1")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf)
+
+	return nil
+}
+
+// mockResponse 返回值字段通过Level及Parent组成树, 从第0层开始生成.
+func mockResponse(vars []*meta.Variable) map[string]interface{} {
+	var rvs []*meta.Variable
+	for _, v := range vars {
+		if v.Postion == meta.PostionResponseJSON {
+			rvs = append(rvs, v)
+		}
+	}
+
+	return mockObject(rvs, 0, "", 0)
+}
+
+// mockObject 生成指定层级及父类型下的所有字段.
+func mockObject(vars []*meta.Variable, level int, parent string, depth int) map[string]interface{} {
+	m := make(map[string]interface{})
+	if depth > mockMaxDepth {
+		return m
+	}
+
+	for _, v := range vars {
+		if v.Level == level && v.Parent == parent {
+			m[v.Name] = mockValue(vars, v, v.Type, depth)
+		}
+	}
+
+	return m
+}
+
+// mockValue 优先使用示例值, 没有示例按类型生成默认值, 结构体类型递归生成子字段.
+func mockValue(vars []*meta.Variable, v *meta.Variable, t string, depth int) interface{} {
+	t = strings.TrimLeft(t, "*")
+
+	if strings.HasPrefix(t, "[]") {
+		var l []interface{}
+		if v.Example != "" && json.Unmarshal([]byte(v.Example), &l) == nil {
+			return l
+		}
+		return []interface{}{mockValue(vars, &meta.Variable{Level: v.Level, Type: v.Type}, t[2:], depth+1)}
+	}
+
+	switch {
+	case t == "string":
+		return v.Example
+	case t == "bool":
+		b, _ := strconv.ParseBool(v.Example)
+		return b
+	case strings.HasPrefix(t, "int"), strings.HasPrefix(t, "uint"):
+		n, _ := strconv.ParseInt(v.Example, 10, 64)
+		return n
+	case strings.HasPrefix(t, "float"):
+		f, _ := strconv.ParseFloat(v.Example, 64)
+		return f
+	case t == "time.Time":
+		if v.Example != "" {
+			return v.Example
+		}
+		return time.Now().Format(time.RFC3339)
+	}
+
+	if v.Example != "" {
+		var i interface{}
+		if json.Unmarshal([]byte(v.Example), &i) == nil {
+			return i
+		}
+		return v.Example
+	}
+
+	if strings.HasPrefix(t, "map[") || t == "interface {}" {
+		return map[string]interface{}{}
+	}
+
+	//结构体, 子字段的Parent是当前字段的完整类型
+	return mockObject(vars, v.Level+1, v.Type, depth+1)
+}
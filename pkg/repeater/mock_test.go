@@ -0,0 +1,42 @@
+package repeater
+
+import (
+	"encoding/json"
+	"testing"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+func TestMockResponse(t *testing.T) {
+	vars := []*meta.Variable{
+		{Postion: meta.PostionRequestJSON, Name: "query", Type: "string"},
+		{Postion: meta.PostionResponseJSON, Name: "id", Type: "int64", Example: "12"},
+		{Postion: meta.PostionResponseJSON, Name: "name", Type: "string", Example: "doodle"},
+		{Postion: meta.PostionResponseJSON, Name: "users", Type: "[]*meta.User"},
+		{Postion: meta.PostionResponseJSON, Name: "email", Type: "string", Level: 1, Parent: "[]*meta.User", Example: "a@b.c"},
+		{Postion: meta.PostionResponseJSON, Name: "self", Type: "*meta.Node"},
+		{Postion: meta.PostionResponseJSON, Name: "next", Type: "*meta.Node", Level: 1, Parent: "*meta.Node"},
+	}
+
+	buf, err := json.Marshal(mockResponse(vars))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m struct {
+		ID    int64
+		Name  string
+		Query *string
+		Users []struct {
+			Email string
+		}
+	}
+
+	if err = json.Unmarshal(buf, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.ID != 12 || m.Name != "doodle" || m.Query != nil || len(m.Users) != 1 || m.Users[0].Email != "a@b.c" {
+		t.Fatalf("invalid mock response:%s", buf)
+	}
+}
@@ -0,0 +1,90 @@
+package repeater
+
+import (
+	"net/http"
+	"sync"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util/cidr"
+)
+
+var (
+	// networkMu 配置可以重新加载, 读写网段时加锁.
+	networkMu sync.RWMutex
+	// trustedProxies 可信代理.
+	trustedProxies cidr.List
+	// internalNets 内网网段.
+	internalNets cidr.List
+)
+
+// loadNetworkConfig 解析可信代理及内网网段.
+func loadNetworkConfig() (err error) {
+	var proxies, internal cidr.List
+
+	if s := config.Repeater.Network.TrustedProxies; s != "none" {
+		if proxies, err = cidr.Parse(s); err != nil {
+			return errors.Annotatef(err, "invalid Network.TrustedProxies")
+		}
+	}
+
+	if internal, err = cidr.Parse(config.Repeater.Network.Internal); err != nil {
+		return errors.Annotatef(err, "invalid Network.Internal")
+	}
+
+	//都解析成功才替换, 重新加载出错时不影响原来的配置
+	networkMu.Lock()
+	trustedProxies, internalNets = proxies, internal
+	networkMu.Unlock()
+
+	return nil
+}
+
+// networkLists 当前的可信代理及内网网段.
+func networkLists() (cidr.List, cidr.List) {
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+	return trustedProxies, internalNets
+}
+
+// checkNetwork 检查请求来源是否在应用允许的网段内, 内部接口只能从内网调用.
+func checkNetwork(req *http.Request, app *meta.Application, iface *meta.Interface) error {
+	if app.AllowCIDR == "" && !iface.Internal {
+		return nil
+	}
+
+	proxies, internal := networkLists()
+	ip := cidr.ClientIP(req.RemoteAddr, req.Header.Get("X-Forwarded-For"), proxies)
+
+	if iface.Internal && !internal.Contains(ip) {
+		return errors.Annotatef(errForbidden, "interface:%d internal only, client:%v", iface.ID, ip)
+	}
+
+	if app.AllowCIDR == "" {
+		return nil
+	}
+
+	//配置的网段解析失败时拒绝所有来源
+	if len(app.AllowNets) == 0 {
+		return errors.Annotatef(errForbidden, "app:%d invalid allow cidr:%s", app.ID, app.AllowCIDR)
+	}
+
+	if !app.AllowNets.Contains(ip) {
+		return errors.Annotatef(errForbidden, "app:%d client:%v not allowed", app.ID, ip)
+	}
+
+	return nil
+}
+
+// parseAllowNets 加载应用时解析允许的网段, 出错时保持为空, 请求时拒绝.
+func parseAllowNets(app *meta.Application) {
+	nets, err := cidr.Parse(app.AllowCIDR)
+	if err != nil {
+		log.Errorf("app:%d invalid allow cidr:%s, error:%v", app.ID, app.AllowCIDR, err)
+		return
+	}
+	app.AllowNets = nets
+}
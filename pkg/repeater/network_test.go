@@ -0,0 +1,81 @@
+package repeater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+)
+
+func TestCheckNetwork(t *testing.T) {
+	iface := &meta.Interface{ID: 1}
+
+	cases := []struct {
+		allow  string
+		remote string
+		ok     bool
+	}{
+		{"", "1.2.3.4:80", true},
+		{"10.0.0.0/8, 192.168.1.10", "10.1.2.3:80", true},
+		{"10.0.0.0/8, 192.168.1.10", "192.168.1.10:80", true},
+		{"10.0.0.0/8, 192.168.1.10", "192.168.1.11:80", false},
+		{"10.0.0.0/33", "10.1.2.3:80", false},
+	}
+
+	for _, c := range cases {
+		app := &meta.Application{ID: 1, AllowCIDR: c.allow}
+		parseAllowNets(app)
+
+		req := httptest.NewRequest(http.MethodGet, "/svc/iface", nil)
+		req.RemoteAddr = c.remote
+
+		err := checkNetwork(req, app, iface)
+		if c.ok != (err == nil) || (err != nil && errors.Cause(err) != errForbidden) {
+			t.Fatalf("allow:%s remote:%s expect:%v, err:%v", c.allow, c.remote, c.ok, err)
+		}
+	}
+}
+
+func TestReloadNetworkConfig(t *testing.T) {
+	config.Repeater.Network.TrustedProxies = "10.0.0.1"
+	config.Repeater.Network.Internal = "192.168.0.0/16"
+
+	iface := &meta.Interface{ID: 1, Internal: true}
+	app := &meta.Application{ID: 1}
+
+	//重新加载与请求同时进行, 用-race检查
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if err := loadNetworkConfig(); err != nil {
+				t.Errorf("load network config error:%v", err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/svc/iface", nil)
+		req.RemoteAddr = "10.0.0.1:80"
+		req.Header.Set("X-Forwarded-For", "192.168.1.1")
+		checkNetwork(req, app, iface)
+	}
+	<-done
+
+	req := httptest.NewRequest(http.MethodGet, "/svc/iface", nil)
+	req.RemoteAddr = "10.0.0.1:80"
+	req.Header.Set("X-Forwarded-For", "192.168.1.1")
+	if err := checkNetwork(req, app, iface); err != nil {
+		t.Fatalf("client behind trusted proxy should be internal, err:%v", err)
+	}
+
+	req.RemoteAddr = "10.0.0.2:80"
+	if err := checkNetwork(req, app, iface); errors.Cause(err) != errForbidden {
+		t.Fatalf("untrusted proxy should be forbidden, err:%v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package repeater
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+)
+
+// quotaCounter 一个配额在当前周期的使用量, used是上次同步时数据库中所有网关的总数, pending是本网关还没有写入的.
+type quotaCounter struct {
+	quota   int64
+	period  string
+	key     string
+	used    int64
+	pending int64
+}
+
+// quotaUsage 返回给调用方的配额使用情况, 有多个配额时取剩余最少的.
+type quotaUsage struct {
+	Limit     int64
+	Remaining int64
+	// Reset 周期结束的时间.
+	Reset int64
+	// counters 本次占用的计数, 没有调用后端时退回.
+	counters []*quotaCounter
+}
+
+// quotaCache 所有网关通过数据库共享计数, 本地先计数, 定时写入并读回总数, 重启后从数据库恢复.
+type quotaCache struct {
+	counters map[string]*quotaCounter
+	mu       sync.Mutex
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+func newQuotaCache() *quotaCache {
+	return &quotaCache{counters: make(map[string]*quotaCounter), done: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+// periodKey 时间所在的周期及周期结束的时间, 按本地时间的自然日或自然月.
+func periodKey(period string, now time.Time) (string, time.Time) {
+	if period == meta.QuotaMonth {
+		return now.Format("2006-01"), time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+	}
+	return now.Format("2006-01-02"), time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+}
+
+// getQuotas 应用调用接口的配额, 没有配置时缓存空列表.
+func (dc *dbCache) getQuotas(app, iface int64) ([]meta.Quota, error) {
+	key := fmt.Sprintf("\x0f%d.%d", app, iface)
+	if v := dc.cache.Get(key); v != nil {
+		return v.([]meta.Quota), nil
+	}
+
+	gen := dc.cache.Gen()
+
+	var rows *sql.Rows
+	var err error
+
+	if err = dc.dbQuery(func() error {
+		rows, err = dc.selQuota.Query(app, iface)
+		return err
+	}); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	defer rows.Close()
+
+	qs := []meta.Quota{}
+
+	for rows.Next() {
+		q := meta.Quota{ApplicationID: app, InterfaceID: iface}
+		if err = rows.Scan(&q.ID, &q.Period, &q.Calls); err != nil {
+			return nil, errors.Trace(err)
+		}
+		qs = append(qs, q)
+	}
+
+	dc.cache.AddSince(key, qs, gen)
+
+	return qs, nil
+}
+
+// quotaUsed 数据库中配额在周期内的总数, 没有记录时为0.
+func (dc *dbCache) quotaUsed(quota int64, key string) (int64, error) {
+	var n int64
+	if err := dc.queryDB(dc.selQuotaUsage, []interface{}{quota, key}, []interface{}{&n}); err != nil && errors.Cause(err) != errNotFound {
+		return 0, errors.Trace(err)
+	}
+	return n, nil
+}
+
+func (dc *dbCache) addQuotaUsage(quota int64, key string, n int64) error {
+	_, err := dc.executeDB(dc.instQuota, []interface{}{quota, key, n, n})
+	return errors.Trace(err)
+}
+
+// counter 配额在当前周期的计数, 本网关第一次使用时从数据库读取.
+func (q *quotaCache) counter(qt meta.Quota, now time.Time) *quotaCounter {
+	key, _ := periodKey(qt.Period, now)
+	ck := fmt.Sprintf("%d/%s", qt.ID, key)
+
+	q.mu.Lock()
+	c, ok := q.counters[ck]
+	q.mu.Unlock()
+	if ok {
+		return c
+	}
+
+	used, err := dc.quotaUsed(qt.ID, key)
+	if err != nil {
+		//先按0计算, 同步时会读回总数
+		log.Errorf("quota:%d period:%s load usage error:%s", qt.ID, key, errors.ErrorStack(err))
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if c, ok = q.counters[ck]; ok {
+		return c
+	}
+
+	c = &quotaCounter{quota: qt.ID, period: qt.Period, key: key, used: used}
+	q.counters[ck] = c
+
+	return c
+}
+
+// take 应用调用接口的配额都没有用完时计数加1, 没有配置配额时返回nil, 读取配额出错时不限制.
+func (q *quotaCache) take(app, iface int64) (*quotaUsage, error) {
+	qs, err := dc.getQuotas(app, iface)
+	if err != nil {
+		log.Errorf("app:%d interface:%d get quota error:%s", app, iface, errors.ErrorStack(err))
+		return nil, nil
+	}
+
+	if len(qs) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	cs := make([]*quotaCounter, len(qs))
+	for i, qt := range qs {
+		cs[i] = q.counter(qt, now)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var u *quotaUsage
+	for i, c := range cs {
+		left := qs[i].Calls - c.used - c.pending
+		if u == nil || left < u.Remaining {
+			_, reset := periodKey(qs[i].Period, now)
+			u = &quotaUsage{Limit: qs[i].Calls, Remaining: left, Reset: reset.Unix()}
+		}
+	}
+
+	if u.Remaining <= 0 {
+		u.Remaining = 0
+		return u, errors.Annotatef(errQuotaExceeded, "app:%d interface:%d limit:%d", app, iface, u.Limit)
+	}
+
+	for _, c := range cs {
+		c.pending++
+	}
+	u.Remaining--
+	u.counters = cs
+
+	return u, nil
+}
+
+// refund 请求没有到达后端时退回take占用的计数, 已经同步到数据库的在下次同步时扣除.
+func (q *quotaCache) refund(u *quotaUsage) {
+	if u == nil || len(u.counters) == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, c := range u.counters {
+		c.pending--
+	}
+	u.Remaining++
+	u.counters = nil
+}
+
+// header 把配额使用情况写到响应头.
+func (u *quotaUsage) header(h http.Header) {
+	h.Set("X-Quota-Limit", strconv.FormatInt(u.Limit, 10))
+	h.Set("X-Quota-Remaining", strconv.FormatInt(u.Remaining, 10))
+	h.Set("X-Quota-Reset", strconv.FormatInt(u.Reset, 10))
+}
+
+// sync 本地计数写入数据库并读回所有网关的总数, 过期周期的计数写完后删除.
+func (q *quotaCache) sync() {
+	q.mu.Lock()
+	cs := make(map[string]quotaCounter, len(q.counters))
+	for k, c := range q.counters {
+		cs[k] = *c
+	}
+	q.mu.Unlock()
+
+	now := time.Now()
+
+	for k, c := range cs {
+		//退回已经同步过的计数时pending为负数
+		if c.pending != 0 {
+			if err := dc.addQuotaUsage(c.quota, c.key, c.pending); err != nil {
+				log.Errorf("quota:%d period:%s add usage:%d error:%s", c.quota, c.key, c.pending, errors.ErrorStack(err))
+				continue
+			}
+		}
+
+		used, err := dc.quotaUsed(c.quota, c.key)
+
+		q.mu.Lock()
+		cur := q.counters[k]
+		cur.pending -= c.pending
+		if err != nil {
+			log.Errorf("quota:%d period:%s load usage error:%s", c.quota, c.key, errors.ErrorStack(err))
+			cur.used += c.pending
+		} else {
+			cur.used = used
+		}
+		if key, _ := periodKey(cur.period, now); key != cur.key && cur.pending == 0 {
+			delete(q.counters, k)
+		}
+		q.mu.Unlock()
+	}
+}
+
+func (q *quotaCache) run() {
+	t := time.NewTicker(time.Duration(config.Repeater.Quota.Sync) * time.Second)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			q.sync()
+		case <-q.done:
+			q.sync()
+			close(q.stopped)
+			return
+		}
+	}
+}
+
+// stop 退出前写入本地计数.
+func (q *quotaCache) stop() {
+	close(q.done)
+	<-q.stopped
+}
@@ -0,0 +1,87 @@
+package repeater
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+func TestPeriodKey(t *testing.T) {
+	now := time.Date(2026, 12, 31, 23, 59, 0, 0, time.Local)
+
+	if k, reset := periodKey(meta.QuotaDay, now); k != "2026-12-31" || !reset.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("unexpected day key:%s reset:%v", k, reset)
+	}
+
+	if k, reset := periodKey(meta.QuotaMonth, now); k != "2026-12" || !reset.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("unexpected month key:%s reset:%v", k, reset)
+	}
+}
+
+func TestQuotaTake(t *testing.T) {
+	dc = &dbCache{cache: newTTLCache(60)}
+	dc.cache.Add("\x0f1.2", []meta.Quota{{ID: 7, Period: meta.QuotaDay, Calls: 10}, {ID: 8, Period: meta.QuotaMonth, Calls: 100}})
+
+	//其它网关已经用了8次, 不查数据库
+	q := newQuotaCache()
+	now := time.Now()
+	day, _ := periodKey(meta.QuotaDay, now)
+	month, _ := periodKey(meta.QuotaMonth, now)
+	q.counters[fmt.Sprintf("7/%s", day)] = &quotaCounter{quota: 7, period: meta.QuotaDay, key: day, used: 8}
+	q.counters[fmt.Sprintf("8/%s", month)] = &quotaCounter{quota: 8, period: meta.QuotaMonth, key: month, used: 50}
+
+	for i := int64(1); i >= 0; i-- {
+		u, err := q.take(1, 2)
+		if err != nil {
+			t.Fatalf("take error:%v", err)
+		}
+		if u.Limit != 10 || u.Remaining != i {
+			t.Fatalf("unexpected usage:%+v", u)
+		}
+	}
+
+	u, err := q.take(1, 2)
+	if errors.Cause(err) != errQuotaExceeded || u.Remaining != 0 {
+		t.Fatalf("expect quota exceeded, usage:%+v, err:%v", u, err)
+	}
+
+	if c := q.counters[fmt.Sprintf("8/%s", month)]; c.pending != 2 {
+		t.Fatalf("rejected call should not be counted:%+v", c)
+	}
+
+	dc.cache.Add("\x0f1.3", []meta.Quota{})
+	if u, err = q.take(1, 3); u != nil || err != nil {
+		t.Fatalf("expect no quota, usage:%+v, err:%v", u, err)
+	}
+}
+
+func TestQuotaRefund(t *testing.T) {
+	dc = &dbCache{cache: newTTLCache(60)}
+	dc.cache.Add("\x0f1.2", []meta.Quota{{ID: 7, Period: meta.QuotaDay, Calls: 1}})
+
+	q := newQuotaCache()
+	day, _ := periodKey(meta.QuotaDay, time.Now())
+	q.counters[fmt.Sprintf("7/%s", day)] = &quotaCounter{quota: 7, period: meta.QuotaDay, key: day}
+
+	//没有到达后端时退回, 配额还可以再用
+	u, err := q.take(1, 2)
+	if err != nil {
+		t.Fatalf("take error:%v", err)
+	}
+	q.refund(u)
+	q.refund(u)
+
+	if c := q.counters[fmt.Sprintf("7/%s", day)]; c.pending != 0 || u.Remaining != 1 {
+		t.Fatalf("unexpected counter after refund:%+v, usage:%+v", c, u)
+	}
+
+	if _, err = q.take(1, 2); err != nil {
+		t.Fatalf("take after refund error:%v", err)
+	}
+
+	q.refund(nil)
+}
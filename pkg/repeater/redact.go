@@ -0,0 +1,53 @@
+package repeater
+
+import (
+	"fmt"
+
+	"dearcode.net/crab/http/server"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util/redact"
+)
+
+var (
+	// defaultRules 所有接口通用的脱敏规则, 还没找到接口时也使用它.
+	defaultRules = redact.New("", "", 0)
+)
+
+// loadRedactConfig 加载通用脱敏规则.
+func loadRedactConfig() {
+	c := config.Repeater.Redact
+	defaultRules = redact.New(c.Fields, c.Headers, c.MaxSize)
+}
+
+// getRedactRules 合并通用规则, 接口配置及注册时标记为敏感的字段.
+func (dc *dbCache) getRedactRules(iface *meta.Interface) (*redact.Rules, error) {
+	key := fmt.Sprintf("\x09%d", iface.ID)
+	if v := dc.cache.Get(key); v != nil {
+		return v.(*redact.Rules), nil
+	}
+
+	gen := dc.cache.Gen()
+
+	vars, err := dc.getVariable(iface.ID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	r := defaultRules.Merge(redact.New(iface.RedactFields, iface.RedactHeaders, iface.LogMaxSize))
+	for _, v := range vars {
+		if !v.Sensitive {
+			continue
+		}
+		if v.Postion == server.HEADER {
+			r.AddHeader(v.Name)
+			continue
+		}
+		r.AddField(v.Name)
+	}
+
+	dc.cache.AddSince(key, r, gen)
+	return r, nil
+}
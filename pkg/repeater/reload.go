@@ -0,0 +1,100 @@
+package repeater
+
+import (
+	"sync"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util/reload"
+)
+
+var (
+	// hotFields 不需要重启就能生效的配置, 其它的修改只记录, 重启后才生效.
+	hotFields = []string{
+		"DB", "ETCD", "Cache", "Log", "Token", "Network", "Mock", "Redact", "Retry", "Error",
+		"Mirror.Timeout", "Admin.Token", "Limit.MaxBodySize", "Shutdown", "Shed",
+	}
+
+	// reloaders 配置段修改后需要执行的动作, 没有的只修改配置, 使用时直接读取.
+	reloaders = map[string]func() error{
+		"DB":   func() error { return dc.reset() },
+		"ETCD": func() error { bs.etcd.SetEndpoints(config.Repeater.ETCD.Hosts); return nil },
+		"Cache": func() error {
+			dc.resetTimeout()
+			dc.cache.SetMaxSize(config.Repeater.Cache.MaxSize)
+			return nil
+		},
+		"Log":     func() error { setLogLevel(); return nil },
+		"Token":   loadTokenConfig,
+		"Network": loadNetworkConfig,
+		"Redact": func() error {
+			loadRedactConfig()
+			//接口的脱敏规则合并了通用规则, 需要重新生成
+			dc.cache.DeleteFunc("\x09", func(string, interface{}) bool { return true })
+			return nil
+		},
+	}
+
+	reloadMu sync.Mutex
+)
+
+// setLogLevel 配置了日志级别时使用它, 否则保持启动时的级别.
+func setLogLevel() {
+	if l := config.Repeater.Log.Level; l != "none" {
+		log.SetLevelByString(l)
+	}
+}
+
+// reset 使用新的数据库配置重新连接.
+func (dc *dbCache) reset() error {
+	dc.Lock()
+	defer dc.Unlock()
+
+	dc.closeAll()
+	return dc.conectDB()
+}
+
+// Reload 重新读取配置文件, 检查通过后替换可以在线修改的配置, 失败时恢复原来的配置.
+func Reload() (reload.Report, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	nc, err := config.Parse()
+	if err != nil {
+		return reload.Report{}, errors.Trace(err)
+	}
+
+	old := config.Repeater
+	r := reload.Apply(&config.Repeater, nc, hotFields)
+
+	if err = applyReload(r.Sections()); err != nil {
+		log.Errorf("reload config error:%s, rollback", errors.ErrorStack(err))
+		config.Repeater = old
+		if e := applyReload(r.Sections()); e != nil {
+			log.Errorf("rollback config error:%s", errors.ErrorStack(e))
+		}
+		return reload.Report{}, errors.Trace(err)
+	}
+
+	for _, f := range r.Applied {
+		log.Infof("reload config %s applied", f)
+	}
+	for _, f := range r.Restart {
+		log.Warningf("reload config %s changed, need restart", f)
+	}
+
+	return r, nil
+}
+
+func applyReload(sections []string) error {
+	for _, s := range sections {
+		if fn, ok := reloaders[s]; ok {
+			if err := fn(); err != nil {
+				return errors.Annotatef(err, "section:%s", s)
+			}
+		}
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"dearcode.net/crab/http/server"
@@ -17,6 +18,7 @@ import (
 
 	"dearcode.net/doodle/pkg/meta"
 	"dearcode.net/doodle/pkg/util"
+	"dearcode.net/doodle/pkg/util/trace"
 	"dearcode.net/doodle/pkg/util/uuid"
 )
 
@@ -42,13 +44,13 @@ func (r *repeater) validateValue(v *meta.Variable, val string) (bool, error) {
 		_, err := strconv.ParseInt(val, 10, 64)
 		if err != nil {
 			log.Infof("val:%s, ParseInt error:%s, in %s", val, err.Error(), v.Postion)
-			return false, fmt.Errorf("key:%s val:%s is not number, in %s", v.Name, val, v.Postion)
+			return false, errors.Annotatef(errInvalidArgument, "key:%s val:%s is not number, in %s", v.Name, val, v.Postion)
 		}
 		return false, nil
 	}
 
 	if val == "" {
-		return false, fmt.Errorf("key:%s not found in %s", v.Name, v.Postion)
+		return false, errors.Annotatef(errInvalidArgument, "key:%s not found in %s", v.Name, v.Postion)
 	}
 
 	return false, nil
@@ -56,15 +58,8 @@ func (r *repeater) validateValue(v *meta.Variable, val string) (bool, error) {
 
 // GetInterface 根据请求header获取对应接口
 func (r *repeater) GetInterface(req *http.Request, id string) (app *meta.Application, iface *meta.Interface, err error) {
-	token := req.Header.Get("Token")
-	if token == "" {
-		return nil, nil, errors.Trace(errNotFoundToken)
-	}
-
-	log.Infof("%s requset token is:%v", id, token)
-
-	if app, err = dc.getApp(token); err != nil {
-		log.Errorf("%s get app error,token is:%v", id, token)
+	app, claims, err := r.authenticate(req, id)
+	if err != nil {
 		return nil, nil, errors.Trace(err)
 	}
 	log.Infof("%s app is:%v, user email is:%v", id, app.Name, app.Email)
@@ -75,9 +70,20 @@ func (r *repeater) GetInterface(req *http.Request, id string) (app *meta.Applica
 	}
 	log.Infof("%s iface is:%v,user email is:%v", id, iface.Path, iface.Email)
 
-	if iface.Method != server.RESTful && req.Method != iface.Method.String() {
+	//新格式token限制了可以访问的服务或接口
+	if claims != nil && !claims.Allow(req.URL.Path) {
+		log.Errorf("%s app:%d token:%s scopes:%v, not allow:%s", id, app.ID, claims.ID, claims.Scopes, req.URL.Path)
+		return nil, nil, errors.Annotatef(errForbidden, "path:%s not in token scopes", req.URL.Path)
+	}
+
+	if !methodAllowed(iface.Method, req) {
 		log.Errorf("%s url:%v, invalid method:%v, need:%v,user email is:%v", id, req.URL, req.Method, iface.Method, iface.Email)
-		return nil, nil, fmt.Errorf("invalid method:%v, need:%v", req.Method, iface.Method)
+		return nil, nil, errors.Annotatef(errMethodNotAllowed, "invalid method:%v, need:%v", req.Method, iface.Method)
+	}
+
+	if err = checkNetwork(req, app, iface); err != nil {
+		log.Errorf("%s app:%d iface:%d network denied:%v", id, app.ID, iface.ID, err)
+		return nil, nil, errors.Trace(err)
 	}
 
 	//如果不需要验证权限，直接通过
@@ -133,6 +139,8 @@ func (r *repeater) Validate(req *http.Request, iface *meta.Interface) error {
 		return errors.Trace(err)
 	}
 
+	hasJSON := false
+
 	for _, v := range vars {
 		var val string
 		switch v.Postion {
@@ -142,6 +150,13 @@ func (r *repeater) Validate(req *http.Request, iface *meta.Interface) error {
 			val = req.FormValue(v.Name)
 		case server.HEADER:
 			val = req.Header.Get(v.Name)
+		case meta.PostionPath:
+			val = pathParam(iface.Params, v.Name)
+		case meta.PostionRequestJSON:
+			hasJSON = true
+			continue
+		default:
+			continue
 		}
 		del, err := r.validateValue(v, val)
 		if err != nil {
@@ -152,14 +167,35 @@ func (r *repeater) Validate(req *http.Request, iface *meta.Interface) error {
 		}
 	}
 
+	if hasJSON {
+		return r.validateBody(req, vars)
+	}
+
 	return nil
 }
 
-func (r *repeater) microAPPBackendURL(iface *meta.Interface, req *http.Request) (string, error) {
+// validateBody 根据注册的请求字段树验证json body, 不是json请求时验证url参数.
+func (r *repeater) validateBody(req *http.Request, vars []*meta.Variable) error {
+	ns := newSchema(vars, meta.PostionRequestJSON)
+	if !hasJSONBody(req) {
+		return validateQuery(req.URL.Query(), ns)
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+
+	return validateJSON(buf, ns)
+}
+
+func (r *repeater) microAPPBackendURL(id string, app *meta.Application, iface *meta.Interface, req *http.Request) (string, *meta.MicroAPP, error) {
 	apps, err := bs.getMicroAPPs(iface.Backend)
 	if err != nil {
-		return "", errors.Trace(err)
+		return "", nil, errors.Trace(err)
 	}
+	apps = canaryApps(id, app, iface, req, apps)
 	idx := time.Now().UnixNano() % int64(len(apps))
 	backend := fmt.Sprintf("http://%s:%d%s", apps[idx].Host, apps[idx].Port, iface.Path)
 	//生成url参数
@@ -167,13 +203,17 @@ func (r *repeater) microAPPBackendURL(iface *meta.Interface, req *http.Request)
 		backend += "?" + args
 	}
 
-	return backend, nil
+	return backend, &apps[idx], nil
 }
 
 // backendURL 如果是faas的，随机访问后端地址， 如果是传统的走域名直接访问.
-func (r *repeater) backendURL(iface *meta.Interface, req *http.Request) (string, error) {
+func (r *repeater) backendURL(id string, app *meta.Application, iface *meta.Interface, req *http.Request) (string, *meta.MicroAPP, error) {
 	if iface.Service.Version == 1 {
-		return r.microAPPBackendURL(iface, req)
+		return r.microAPPBackendURL(id, app, iface, req)
+	}
+
+	if iface.Params != nil {
+		return routeBackendURL(iface, req), nil, nil
 	}
 
 	uri := req.RequestURI
@@ -185,7 +225,7 @@ func (r *repeater) backendURL(iface *meta.Interface, req *http.Request) (string,
 	//清理二级目录
 	uri = strings.TrimPrefix(uri, iface.Path)
 	if len(uri) < 2 {
-		return iface.Backend, nil
+		return iface.Backend, nil, nil
 	}
 
 	if len(uri) > 2 && uri[1] == '?' {
@@ -196,18 +236,20 @@ func (r *repeater) backendURL(iface *meta.Interface, req *http.Request) (string,
 		uri = uri[1:]
 	}
 
-	return iface.Backend + uri, nil
+	return iface.Backend + uri, nil, nil
 }
 
-// buildRequest 生成后端请求request,清理无用的请求参数
-func (r *repeater) buildRequest(id string, iface *meta.Interface, req *http.Request) error {
-	backend, err := r.backendURL(iface, req)
+// buildRequest 生成后端请求request,清理无用的请求参数, 再执行接口配置的转换规则, faas接口返回选中的后端实例.
+func (r *repeater) buildRequest(id string, app *meta.Application, iface *meta.Interface, req *http.Request) (*meta.MicroAPP, error) {
+	path := req.URL.Path
+
+	backend, ma, err := r.backendURL(id, app, iface, req)
 	if err != nil {
-		return errors.Trace(err)
+		return nil, errors.Trace(err)
 	}
 
 	if req.URL, err = url.Parse(backend); err != nil {
-		return errors.Trace(err)
+		return nil, errors.Trace(err)
 	}
 
 	req.Host = req.URL.Host
@@ -216,44 +258,76 @@ func (r *repeater) buildRequest(id string, iface *meta.Interface, req *http.Requ
 	req.RequestURI = ""
 	req.Header.Set("Session", id)
 
-	return nil
-}
-
-func (r *repeater) requestBody(id string, req *http.Request) error {
-	//接口接收到请求的详细信息
-	buf, err := ioutil.ReadAll(req.Body)
+	ts, err := dc.getTransforms(iface.ID)
 	if err != nil {
-		return errors.Trace(err)
+		return nil, errors.Trace(err)
 	}
 
-	log.Infof("%s data:%v", id, string(buf))
-	//再还回去
-	req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	if len(ts) == 0 {
+		return ma, nil
+	}
 
-	return nil
+	if err = transformRequest(req, ts, &transformVars{session: id, app: app, req: req, path: path, params: iface.Params}); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return ma, nil
 }
 
-func (r *repeater) writeError(w http.ResponseWriter, err error) {
-	status := http.StatusInternalServerError
+// responseHeader 根据转换规则生成返回给调用方的header.
+func (r *repeater) responseHeader(id string, app *meta.Application, iface *meta.Interface, req *http.Request, path string, backend, h http.Header) {
+	ts, err := dc.getTransforms(iface.ID)
+	if err != nil {
+		log.Errorf("%s get transforms error:%s", id, errors.ErrorStack(err))
+		return
+	}
 
-	switch errors.Cause(err) {
-	case errForbidden:
-		status = http.StatusForbidden
-	case errInvalidPath, errInvalidToken, errNotFound:
-		status = http.StatusNotFound
-	case errNotFoundToken:
-		status = http.StatusUnauthorized
+	transformResponse(backend, h, ts, &transformVars{session: id, app: app, req: req, path: path, params: iface.Params})
+}
+
+func (r *repeater) requestBody(req *http.Request, l requestLimit) ([]byte, error) {
+	//接口接收到请求的详细信息, 找到接口后按脱敏规则记录
+	buf, err := l.readBody(req)
+	if err != nil {
+		return nil, errors.Trace(err)
 	}
 
-	w.WriteHeader(status)
-	w.Write([]byte(err.Error()))
+	//再还回去
+	req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+
+	return buf, nil
 }
 
 // ServeHTTP 入口
 func (r *repeater) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	atomic.AddInt64(&inflight, 1)
+	defer atomic.AddInt64(&inflight, -1)
+
+	if adminOnGateway(req) {
+		Admin.ServeHTTP(w, req)
+		return
+	}
+
 	id := uuid.String()
 	w.Header().Add("Session", id)
 
+	if origin := req.Header.Get("Origin"); origin != "" {
+		c, err := dc.getCors(req.URL.Path)
+		if err != nil {
+			log.Errorf("%s origin:%s get cors error:%s", id, origin, errors.ErrorStack(err))
+			r.writeError(w, err)
+			return
+		}
+
+		if isPreflight(req) {
+			log.Infof("%s preflight url:%v origin:%s", id, defaultRules.URL(req.URL), origin)
+			preflight(w, req, c)
+			return
+		}
+
+		corsHeader(w.Header(), c, origin)
+	}
+
 	defer func() {
 		if e := recover(); e != nil {
 			log.Errorf("%s recover %v", id, e)
@@ -262,48 +336,165 @@ func (r *repeater) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	}()
 
-	log.Infof("%s url:%v method:%v", id, req.URL, req.Method)
+	//调用方带了traceparent就接着用, 没有就新开一个trace
+	parent, _ := trace.Extract(req.Header)
+	span := trace.Start(parent, req.URL.Path, trace.KindServer)
+	span.SetAttr("session", id)
+	span.SetAttr("http.method", req.Method)
+	defer span.Finish(nil)
+
+	log.Infof("%s url:%v method:%v trace:%s", id, defaultRules.URL(req.URL), req.Method, span.Context.TraceIDString())
+
+	limit := getRequestLimit(req.URL.Path)
+	if err := limit.checkHeader(req); err != nil {
+		log.Errorf("%s check header error:%v", id, errors.ErrorStack(err))
+		r.writeError(w, err)
+		return
+	}
+
+	if isGRPC(req) {
+		r.serveGRPC(w, req, id, span, limit)
+		return
+	}
 
-	//解析并记录请求body
-	if err := r.requestBody(id, req); err != nil {
+	//解析请求body
+	body, err := r.requestBody(req, limit)
+	if err != nil {
 		log.Errorf("%v read body error:%v", id, errors.ErrorStack(err))
+		//body没读完, 连接不能再复用
+		w.Header().Set("Connection", "close")
 		r.writeError(w, err)
 		return
 	}
 
 	//查找对应接口信息
+	as := span.Child("auth", trace.KindInternal)
 	app, iface, err := r.GetInterface(req, id)
+	as.Finish(err)
 	if err != nil {
+		log.Infof("%s data:%v", id, defaultRules.Body(body))
+		log.Debugf("%s header:%v", id, defaultRules.Header(req.Header))
 		log.Errorf("%s error:%s", id, errors.ErrorStack(err))
 		r.writeError(w, err)
 		return
 	}
 	log.Infof("%s app:%s email:%s, interface:%s email:%s", id, app.Name, app.Email, iface.Name, iface.Email)
 
+	rules, err := dc.getRedactRules(iface)
+	if err != nil {
+		log.Errorf("%s get redact rules error:%s", id, errors.ErrorStack(err))
+		r.writeError(w, err)
+		return
+	}
+	log.Infof("%s data:%v", id, rules.Body(body))
+	log.Debugf("%s header:%v", id, rules.Header(req.Header))
+
+	span.SetAttr("app.id", strconv.FormatInt(app.ID, 10))
+	span.SetAttr("interface.id", strconv.FormatInt(iface.ID, 10))
+
 	//验证输入参数
-	if err = r.Validate(req, iface); err != nil {
+	vs := span.Child("validate", trace.KindInternal)
+	err = r.Validate(req, iface)
+	vs.Finish(err)
+	if err != nil {
 		log.Errorf("%s validate error:%s", id, errors.ErrorStack(err))
 		r.writeError(w, err)
 		return
 	}
 	log.Infof("%s validate success", id)
 
-	//生成后端请求
-	if err = r.buildRequest(id, iface, req); err != nil {
+	if mockEnabled(iface) {
+		span.SetAttr("mock", "true")
+		if err = r.mock(w, id, iface); err != nil {
+			log.Errorf("%s mock error:%s", id, errors.ErrorStack(err))
+			r.writeError(w, err)
+		}
+		return
+	}
+
+	//配额按应用及接口计算, 用完后直接拒绝, 先占用, 没有调用后端时退回
+	qu, err := quotas.take(app.ID, iface.ID)
+	if err != nil {
+		if qu != nil {
+			qu.header(w.Header())
+		}
+		log.Errorf("%s quota error:%s", id, errors.ErrorStack(err))
+		r.writeError(w, err)
+		return
+	}
+
+	//生成后端请求, 之后req.URL就是后端地址了
+	path := req.URL.Path
+	ma, err := r.buildRequest(id, app, iface, req)
+	if err != nil {
+		quotas.refund(qu)
 		log.Errorf("%s build request error:%s", id, errors.ErrorStack(err))
 		r.writeError(w, err)
 		return
 	}
-	log.Infof("%s backend url:%s method:%s begin", id, req.URL, iface.Method)
+	log.Infof("%s backend url:%s method:%s begin", id, rules.URL(req.URL), iface.Method)
+
+	//后端过载时先拒绝普通接口, 重要接口还可以使用保留的并发
+	release, err := r.shed.acquire(iface)
+	if err != nil {
+		quotas.refund(qu)
+		log.Errorf("%s shed error:%s", id, errors.ErrorStack(err))
+		w.Header().Set("Retry-After", "1")
+		r.writeError(w, err)
+		return
+	}
+
+	//panic时也要归还并发, 耗时按截止panic时计算
+	var used time.Duration
+	var overload bool
+	acquired := time.Now()
+	defer func() {
+		if used == 0 {
+			used = time.Since(acquired)
+		}
+		release(used, overload)
+	}()
+
+	if qu != nil {
+		qu.header(w.Header())
+	}
+
+	//后端收到的parent是backend这个span
+	bspan := span.Child("backend", trace.KindClient)
+	bspan.SetAttr("http.url", req.URL.String())
+	if ma != nil {
+		bspan.SetAttr("backend.version", ma.GitHash)
+	}
+	trace.Inject(req.Header, bspan.Context)
+
+	//影子后端的请求不影响调用方
+	mc := r.mirror(id, iface, req)
 
 	b := time.Now()
-	rb, code, err := util.DoRequest(req)
-	cost := time.Since(b) / time.Millisecond
+	rb, header, code, ma, err := r.doBackend(id, app, iface, req, ma)
+	used = time.Since(b)
+	overload = overloaded(code, err)
+	cost := used / time.Millisecond
+
+	if mc != nil {
+		mc.compare(iface.ID, code, rb, used, err)
+	}
+
+	if err == nil && code != http.StatusOK {
+		bspan.Finish(fmt.Errorf("invalid http status:%v", code))
+	} else {
+		bspan.Finish(err)
+	}
+
+	if ma != nil {
+		stats.version(iface.ID, ma.GitHash, int64(cost), err == nil && code == http.StatusOK)
+		log.Infof("%s backend version:%s time:%s", id, ma.GitHash, ma.GitTime)
+	}
 
 	if err != nil {
 		stats.failed(id, app.ID, iface.ID, err.Error())
 		log.Errorf("%s used:%dms end error:%s", id, cost, err.Error())
-		r.writeError(w, err)
+		r.writeError(w, errors.Wrap(err, errBackend))
 		return
 	}
 
@@ -312,8 +503,11 @@ func (r *repeater) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		log.Errorf("%s used:%dms end failed, code:%d", id, cost, code)
 	} else {
 		stats.success(app.ID, iface.ID, int64(cost))
-		log.Infof("%s used:%dms end success, response:%s", id, cost, rb)
+		log.Infof("%s used:%dms end success, response:%s", id, cost, rules.Body(rb))
 	}
+	log.Debugf("%s response header:%v", id, rules.Header(header))
+
+	r.responseHeader(id, app, iface, req, path, header, w.Header())
 
 	w.WriteHeader(code)
 	w.Write(rb)
@@ -0,0 +1,233 @@
+package repeater
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util"
+)
+
+const (
+	defaultRetryOn = "error,502,503,504"
+)
+
+// budgetEntry 一个后端在当前窗口内的请求数及重试数.
+type budgetEntry struct {
+	Start    int64
+	Requests int
+	Retries  int
+}
+
+// retryBudget 按后端限制重试次数, 后端整体异常时不再重试, 防止重试风暴.
+type retryBudget struct {
+	backends map[string]*budgetEntry
+	mu       sync.Mutex
+}
+
+func newRetryBudget() *retryBudget {
+	return &retryBudget{backends: make(map[string]*budgetEntry)}
+}
+
+// entry 获取后端当前窗口的记录, 窗口过期时重新计数, 调用方加锁.
+func (b *retryBudget) entry(backend string) *budgetEntry {
+	now := time.Now().Unix()
+	e, ok := b.backends[backend]
+	if !ok || now-e.Start >= int64(config.Repeater.Retry.BudgetWindow) {
+		e = &budgetEntry{Start: now}
+		b.backends[backend] = e
+	}
+	return e
+}
+
+// snapshot 复制每个后端当前窗口的请求数及重试数.
+func (b *retryBudget) snapshot() map[string]budgetEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	es := make(map[string]budgetEntry, len(b.backends))
+	for k, e := range b.backends {
+		es[k] = *e
+	}
+
+	return es
+}
+
+// request 记录一次请求.
+func (b *retryBudget) request(backend string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entry(backend).Requests++
+}
+
+// allow 还有预算时占用一次重试并返回true.
+func (b *retryBudget) allow(backend string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := config.Repeater.Retry
+	e := b.entry(backend)
+	if e.Retries >= c.BudgetMin+e.Requests*c.BudgetPercent/100 {
+		return false
+	}
+
+	e.Retries++
+	return true
+}
+
+// idempotent 幂等的方法才能默认重试.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// retryable 根据策略判断本次结果是否可以重试.
+func retryable(p *meta.BackendPolicy, method string, code int, err error) bool {
+	if !p.NonIdempotent && !idempotent(method) {
+		return false
+	}
+
+	on := p.RetryOn
+	if on == "" {
+		on = defaultRetryOn
+	}
+
+	timeout := false
+	if err != nil {
+		if ne, ok := errors.Cause(err).(net.Error); ok && ne.Timeout() {
+			timeout = true
+		}
+	}
+
+	for _, s := range strings.Split(on, ",") {
+		switch s = strings.TrimSpace(s); s {
+		case "error":
+			if err != nil {
+				return true
+			}
+		case "timeout":
+			if timeout {
+				return true
+			}
+		case "5xx":
+			if err == nil && code >= 500 {
+				return true
+			}
+		default:
+			if n, e := strconv.Atoi(s); e == nil && err == nil && n == code {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// backoff 第n次重试前的等待时间, 指数增长并加上随机抖动.
+func backoff(p *meta.BackendPolicy, n int) time.Duration {
+	if p.Backoff <= 0 {
+		return 0
+	}
+
+	d := p.Backoff << uint(n-1)
+	if p.MaxBackoff > 0 && (d > p.MaxBackoff || d <= 0) {
+		d = p.MaxBackoff
+	}
+
+	d = d/2 + rand.Intn(d/2+1)
+	return time.Duration(d) * time.Millisecond
+}
+
+// nextMicroAPP 重试时换一个没有调用过的实例, 都调用过了就随便选一个.
+func nextMicroAPP(id string, app *meta.Application, iface *meta.Interface, req *http.Request, tried map[string]bool) *meta.MicroAPP {
+	apps, err := bs.getMicroAPPs(iface.Backend)
+	if err != nil {
+		log.Errorf("%s get backend:%s error:%v", id, iface.Backend, err)
+		return nil
+	}
+	apps = canaryApps(id, app, iface, req, apps)
+
+	var free []meta.MicroAPP
+	for _, a := range apps {
+		if !tried[fmt.Sprintf("%s:%d", a.Host, a.Port)] {
+			free = append(free, a)
+		}
+	}
+
+	if len(free) == 0 {
+		free = apps
+	}
+
+	return &free[rand.Intn(len(free))]
+}
+
+func millisecond(n int) time.Duration {
+	return time.Duration(n) * time.Millisecond
+}
+
+// doBackend 按接口的超时及重试策略调用后端, faas接口重试时换一个实例.
+func (r *repeater) doBackend(id string, app *meta.Application, iface *meta.Interface, req *http.Request, ma *meta.MicroAPP) ([]byte, http.Header, int, *meta.MicroAPP, error) {
+	p, err := dc.getPolicy(iface.ID)
+	if err != nil {
+		log.Errorf("%s get policy interface:%d error:%s", id, iface.ID, errors.ErrorStack(err))
+	}
+	if p == nil {
+		p = &meta.BackendPolicy{Attempts: 1}
+	}
+
+	//重试时需要重新发送body
+	var body []byte
+	if req.Body != nil {
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, nil, 0, ma, errors.Trace(err)
+		}
+	}
+
+	r.budget.request(iface.Backend)
+	tried := make(map[string]bool)
+
+	for n := 1; ; n++ {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		rb, header, code, err := util.DoRequestTimeout(req, millisecond(p.ConnectTimeout), millisecond(p.Timeout))
+		if n >= p.Attempts || !retryable(p, req.Method, code, err) {
+			return rb, header, code, ma, err
+		}
+
+		if !r.budget.allow(iface.Backend) {
+			log.Warningf("%s backend:%s retry budget exhausted, code:%d, err:%v", id, iface.Backend, code, err)
+			return rb, header, code, ma, err
+		}
+
+		stats.retry(iface.ID, iface.Backend)
+
+		d := backoff(p, n)
+		log.Warningf("%s retry %d/%d after %v, url:%s, code:%d, err:%v", id, n+1, p.Attempts, d, req.URL.Host, code, err)
+		time.Sleep(d)
+
+		if ma != nil {
+			tried[req.URL.Host] = true
+			if na := nextMicroAPP(id, app, iface, req, tried); na != nil {
+				ma = na
+				req.URL.Host = fmt.Sprintf("%s:%d", ma.Host, ma.Port)
+				req.Host = req.URL.Host
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package repeater
+
+import (
+	"errors"
+	"testing"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+)
+
+func TestRetryable(t *testing.T) {
+	p := &meta.BackendPolicy{Attempts: 3}
+
+	cases := []struct {
+		method string
+		code   int
+		err    error
+		ok     bool
+	}{
+		{"GET", 0, errors.New("connection refused"), true},
+		{"GET", 503, nil, true},
+		{"GET", 500, nil, false},
+		{"GET", 200, nil, false},
+		{"POST", 503, nil, false},
+	}
+
+	for _, c := range cases {
+		if retryable(p, c.method, c.code, c.err) != c.ok {
+			t.Fatalf("method:%s code:%d err:%v expect:%v", c.method, c.code, c.err, c.ok)
+		}
+	}
+
+	p.RetryOn = "5xx"
+	p.NonIdempotent = true
+	if !retryable(p, "POST", 500, nil) {
+		t.Fatalf("POST 500 should retry with 5xx")
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	config.Repeater.Retry.BudgetMin = 1
+	config.Repeater.Retry.BudgetPercent = 10
+	config.Repeater.Retry.BudgetWindow = 60
+
+	b := newRetryBudget()
+	for i := 0; i < 10; i++ {
+		b.request("dbs")
+	}
+
+	//1个保底加10%的请求数
+	if !b.allow("dbs") || !b.allow("dbs") || b.allow("dbs") {
+		t.Fatalf("invalid budget:%+v", b.backends["dbs"])
+	}
+}
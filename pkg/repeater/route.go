@@ -0,0 +1,130 @@
+package repeater
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util/route"
+)
+
+// compileRoutes 编译服务下的路径模式, 不合法的记录日志后跳过, 不影响其它接口.
+func compileRoutes(service int64, rs []meta.Route) *route.Table {
+	t := route.New()
+	for _, r := range rs {
+		if err := t.Add(r.ID, r.Path); err != nil {
+			log.Errorf("service:%d interface:%d invalid route:%s, error:%v", service, r.ID, r.Path, err)
+		}
+	}
+	return t
+}
+
+// getRoutes 服务下按模式匹配的接口组成的路由表, manager推送的快照也放在这个缓存中.
+func (dc *dbCache) getRoutes(service int64) (*route.Table, error) {
+	key := fmt.Sprintf("\x0e%d", service)
+	if v := dc.cache.Get(key); v != nil {
+		return v.(*route.Table), nil
+	}
+
+	gen := dc.cache.Gen()
+
+	var rows *sql.Rows
+	var err error
+
+	if err = dc.dbQuery(func() error {
+		rows, err = dc.selRoutes.Query(service)
+		return err
+	}); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	defer rows.Close()
+
+	var rs []meta.Route
+
+	for rows.Next() {
+		var r meta.Route
+		if err = rows.Scan(&r.ID, &r.Path); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if route.IsPattern(r.Path) {
+			rs = append(rs, r)
+		}
+	}
+
+	t := compileRoutes(service, rs)
+	dc.cache.AddSince(key, t, gen)
+
+	return t, nil
+}
+
+// matchInterface 按路径模式查找接口, 捕获的参数放到Params中.
+func (dc *dbCache) matchInterface(service int64, path string, i *meta.Interface) error {
+	t, err := dc.getRoutes(service)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	id, ps, ok := t.Match(path)
+	if !ok {
+		return errors.Annotatef(errNotFound, "service:%d path:%s no route matched", service, path)
+	}
+
+	if err = dc.queryDB(dc.selIfaceByID, []interface{}{id}, ifaceDests(i)); err != nil {
+		return errors.Trace(err)
+	}
+
+	i.Params = make([]meta.PathParam, 0, len(ps))
+	for k, v := range ps {
+		i.Params = append(i.Params, meta.PathParam{Name: k, Value: v})
+	}
+	sort.Slice(i.Params, func(a, b int) bool { return i.Params[a].Name < i.Params[b].Name })
+
+	log.Debugf("service:%d path:%s match interface:%d params:%v", service, path, id, i.Params)
+	return nil
+}
+
+// pathParam 路径模式捕获的参数, 没有时返回空串.
+func pathParam(ps []meta.PathParam, name string) string {
+	for _, p := range ps {
+		if p.Name == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// escapePath 按段转义, 保留分隔符.
+func escapePath(p string) string {
+	ss := strings.Split(p, "/")
+	for i, s := range ss {
+		ss[i] = url.PathEscape(s)
+	}
+	return strings.Join(ss, "/")
+}
+
+// routeBackendURL 按模式匹配的接口, 后端地址中的{name}替换为路径参数,
+// 结尾/*匹配的剩余路径在后端地址没有使用{*}时拼到最后.
+func routeBackendURL(iface *meta.Interface, req *http.Request) string {
+	backend := iface.Backend
+	for _, p := range iface.Params {
+		backend = strings.Replace(backend, "{"+p.Name+"}", escapePath(p.Value), -1)
+	}
+
+	if rest := pathParam(iface.Params, route.Wildcard); rest != "" && !strings.Contains(iface.Backend, "{"+route.Wildcard+"}") {
+		backend = strings.TrimSuffix(backend, "/") + "/" + escapePath(rest)
+	}
+
+	if req.URL.RawQuery != "" {
+		backend += "?" + req.URL.RawQuery
+	}
+
+	return backend
+}
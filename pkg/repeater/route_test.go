@@ -0,0 +1,52 @@
+package repeater
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/util/route"
+)
+
+func TestRouteBackendURL(t *testing.T) {
+	cases := []struct {
+		backend string
+		params  []meta.PathParam
+		url     string
+		expect  string
+	}{
+		{"http://orders/v1/{id}/detail", []meta.PathParam{{Name: "id", Value: "12"}}, "/svc/orders/12?a=1", "http://orders/v1/12/detail?a=1"},
+		{"http://cdn/static/", []meta.PathParam{{Name: "*", Value: "css/a b.css"}}, "/svc/static/css/a%20b.css", "http://cdn/static/css/a%20b.css"},
+		{"http://cdn/{*}?v=1", []meta.PathParam{{Name: "*", Value: "a/b"}}, "/svc/static/a/b", "http://cdn/a/b?v=1"},
+		{"http://api/ping", []meta.PathParam{}, "/svc/v2/ping", "http://api/ping"},
+	}
+
+	for _, c := range cases {
+		iface := &meta.Interface{Backend: c.backend, Params: c.params}
+		if u := routeBackendURL(iface, httptest.NewRequest("GET", c.url, nil)); u != c.expect {
+			t.Fatalf("backend:%s expect:%s, got:%s", c.backend, c.expect, u)
+		}
+	}
+}
+
+func TestRouteEvent(t *testing.T) {
+	c := &dbCache{cache: newTTLCache(60)}
+	c.cache.Add("/svc/orders/1", &meta.Interface{ID: 7, Service: meta.Service{ID: 3}, Params: []meta.PathParam{{Name: "id", Value: "1"}}})
+	c.cache.Add("/svc/list", &meta.Interface{ID: 8, Service: meta.Service{ID: 3}})
+
+	c.evict(meta.Event{Table: "route", ID: 3, Routes: []meta.Route{{ID: 7, Path: "/orders/{oid}"}, {ID: 9, Path: "/bad/{"}}})
+	if c.cache.Get("/svc/orders/1") != nil || c.cache.Get("/svc/list") == nil {
+		t.Fatalf("route evict failed")
+	}
+
+	v := c.cache.Get(fmt.Sprintf("\x0e%d", 3))
+	if v == nil {
+		t.Fatalf("route snapshot not cached")
+	}
+
+	id, ps, ok := v.(*route.Table).Match("/orders/2")
+	if !ok || id != 7 || ps["oid"] != "2" {
+		t.Fatalf("match snapshot failed, id:%d params:%v", id, ps)
+	}
+}
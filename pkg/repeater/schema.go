@@ -0,0 +1,257 @@
+package repeater
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"dearcode.net/crab/http/server"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+const (
+	// maxSchemaLevel 防止自引用的类型无限展开.
+	maxSchemaLevel = 32
+)
+
+const (
+	kindAny     = "any"
+	kindString  = "string"
+	kindInteger = "integer"
+	kindNumber  = "number"
+	kindBool    = "bool"
+	kindArray   = "array"
+	kindObject  = "object"
+)
+
+// schemaNode json中的一个字段.
+type schemaNode struct {
+	name     string
+	typ      string
+	required bool
+	child    []*schemaNode
+}
+
+// newSchema 根据注册时的level及parent还原字段树, 子字段的parent是父字段的类型.
+func newSchema(vars []*meta.Variable, postion server.VariablePostion) []*schemaNode {
+	levels := make(map[int][]*meta.Variable)
+	for _, v := range vars {
+		if v.Postion == postion {
+			levels[v.Level] = append(levels[v.Level], v)
+		}
+	}
+
+	return schemaChildren(levels, 0, "")
+}
+
+func schemaChildren(levels map[int][]*meta.Variable, level int, parent string) []*schemaNode {
+	if level > maxSchemaLevel {
+		return nil
+	}
+
+	var ns []*schemaNode
+	for _, v := range levels[level] {
+		if v.Parent != parent {
+			continue
+		}
+		n := &schemaNode{name: v.Name, typ: v.Type, required: v.Required}
+		if k := schemaKind(v.Type); k == kindObject || k == kindArray {
+			n.child = schemaChildren(levels, level+1, v.Type)
+		}
+		ns = append(ns, n)
+	}
+
+	return ns
+}
+
+// schemaKind 把go的类型名或者页面上填的类型转成json类型.
+func schemaKind(typ string) string {
+	t := strings.TrimLeft(strings.TrimSpace(typ), "*")
+
+	switch t {
+	case "", "interface {}", "interface{}", "any":
+		return kindAny
+	case "string", "time.Time", "[]byte", "[]uint8":
+		return kindString
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "integer":
+		return kindInteger
+	case "float32", "float64", "number":
+		return kindNumber
+	case "bool", "boolean":
+		return kindBool
+	case "array":
+		return kindArray
+	case "object":
+		return kindObject
+	}
+
+	switch {
+	case strings.HasPrefix(t, "[]"):
+		return kindArray
+	case strings.HasPrefix(t, "map["), strings.Contains(t, "."):
+		return kindObject
+	}
+
+	return kindAny
+}
+
+// elemType 数组元素的类型.
+func elemType(typ string) string {
+	t := strings.TrimLeft(strings.TrimSpace(typ), "*")
+	if strings.HasPrefix(t, "[]") {
+		return t[2:]
+	}
+	return ""
+}
+
+func schemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// validateObject 验证对象中每个定义过的字段, 未定义的字段不检查.
+func validateObject(obj map[string]interface{}, ns []*schemaNode, path string) error {
+	for _, n := range ns {
+		p := schemaPath(path, n.name)
+		val, ok := obj[n.name]
+		if !ok || val == nil {
+			if n.required {
+				return errors.Annotatef(errInvalidArgument, "%s is required", p)
+			}
+			continue
+		}
+
+		if err := validateValue(val, n.typ, n.child, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateValue 按类型验证一个值, 数组元素使用同样的子字段.
+func validateValue(val interface{}, typ string, child []*schemaNode, path string) error {
+	kind := schemaKind(typ)
+
+	switch kind {
+	case kindString:
+		if _, ok := val.(string); ok {
+			return nil
+		}
+	case kindInteger:
+		if n, ok := val.(json.Number); ok {
+			if _, err := n.Int64(); err == nil {
+				return nil
+			}
+		}
+	case kindNumber:
+		if _, ok := val.(json.Number); ok {
+			return nil
+		}
+	case kindBool:
+		if _, ok := val.(bool); ok {
+			return nil
+		}
+	case kindArray:
+		vs, ok := val.([]interface{})
+		if !ok {
+			break
+		}
+		for i, v := range vs {
+			if v == nil {
+				continue
+			}
+			if err := validateValue(v, elemType(typ), child, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		return nil
+	case kindObject:
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			break
+		}
+		return validateObject(obj, child, path)
+	default:
+		return nil
+	}
+
+	return errors.Annotatef(errInvalidArgument, "%s must be %s", path, kind)
+}
+
+// validateJSON 验证请求body中的json.
+func validateJSON(body []byte, ns []*schemaNode) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		for _, n := range ns {
+			if n.required {
+				return errors.Annotatef(errInvalidArgument, "body is empty, %s is required", n.name)
+			}
+		}
+		return nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+
+	var obj map[string]interface{}
+	if err := d.Decode(&obj); err != nil {
+		return errors.Annotatef(errInvalidArgument, "body is not json object, %s", err.Error())
+	}
+
+	return validateObject(obj, ns, "")
+}
+
+// hasJSONBody 请求方法带body并且Content-Type是json时才验证body.
+func hasJSONBody(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return false
+	}
+
+	t, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return t == "application/json" || strings.HasSuffix(t, "+json")
+}
+
+// validateQuery 没有json body时顶层字段从url参数中取, 按字符串解析基本类型, 对象及数组只检查是否存在.
+func validateQuery(vals url.Values, ns []*schemaNode) error {
+	for _, n := range ns {
+		vs, ok := vals[n.name]
+		if !ok || len(vs) == 0 {
+			if n.required {
+				return errors.Annotatef(errInvalidArgument, "%s is required", n.name)
+			}
+			continue
+		}
+
+		var err error
+		kind := schemaKind(n.typ)
+
+		switch kind {
+		case kindInteger:
+			_, err = strconv.ParseInt(vs[0], 10, 64)
+		case kindNumber:
+			_, err = strconv.ParseFloat(vs[0], 64)
+		case kindBool:
+			_, err = strconv.ParseBool(vs[0])
+		}
+
+		if err != nil {
+			return errors.Annotatef(errInvalidArgument, "%s must be %s", n.name, kind)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package repeater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+func testSchemaVars() []*meta.Variable {
+	return []*meta.Variable{
+		{Postion: meta.PostionRequestJSON, Name: "ID", Type: "int", Required: true},
+		{Postion: meta.PostionRequestJSON, Name: "User", Type: "service.UserInfo", Required: true},
+		{Postion: meta.PostionRequestJSON, Name: "Tags", Type: "[]service.Tag"},
+		{Postion: meta.PostionRequestJSON, Name: "name", Type: "string", Level: 1, Parent: "service.UserInfo", Required: true},
+		{Postion: meta.PostionRequestJSON, Name: "email", Type: "string", Level: 1, Parent: "service.UserInfo"},
+		{Postion: meta.PostionRequestJSON, Name: "Value", Type: "float64", Level: 1, Parent: "[]service.Tag", Required: true},
+		{Postion: meta.PostionResponseJSON, Name: "Code", Type: "int", Required: true},
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	ns := newSchema(testSchemaVars(), meta.PostionRequestJSON)
+
+	cases := []struct {
+		body string
+		ok   bool
+	}{
+		{`{"ID":1,"User":{"name":"tian"}}`, true},
+		{`{"ID":1,"User":{"name":"tian"},"Tags":[{"Value":1.5},{"Value":2}]}`, true},
+		{`{"ID":1.5,"User":{"name":"tian"}}`, false},
+		{`{"ID":1}`, false},
+		{`{"ID":1,"User":{"email":"a@b.c"}}`, false},
+		{`{"ID":1,"User":{"name":"tian"},"Tags":[{"Value":"x"}]}`, false},
+		{`{"ID":1,"User":"tian"}`, false},
+		{`[1,2]`, false},
+		{``, false},
+	}
+
+	for _, c := range cases {
+		err := validateJSON([]byte(c.body), ns)
+		if c.ok && err != nil {
+			t.Fatalf("body:%s, unexpected error:%v", c.body, err)
+		}
+		if !c.ok {
+			if err == nil {
+				t.Fatalf("body:%s, expect error", c.body)
+			}
+			if errors.Cause(err) != errInvalidArgument {
+				t.Fatalf("body:%s, unexpected error type:%v", c.body, err)
+			}
+		}
+	}
+}
+
+func TestValidateJSONPath(t *testing.T) {
+	ns := newSchema(testSchemaVars(), meta.PostionRequestJSON)
+
+	err := validateJSON([]byte(`{"ID":1,"User":{"name":"tian"},"Tags":[{"Value":1},{}]}`), ns)
+	if err == nil {
+		t.Fatalf("expect error")
+	}
+
+	if msg := err.Error(); msg != "Tags[1].Value is required: invalid argument" {
+		t.Fatalf("unexpected message:%s", msg)
+	}
+}
+
+func TestHasJSONBody(t *testing.T) {
+	cases := []struct {
+		method string
+		ctype  string
+		ok     bool
+	}{
+		{http.MethodPost, "application/json", true},
+		{http.MethodPut, "application/json; charset=utf-8", true},
+		{http.MethodPatch, "application/merge-patch+json", true},
+		{http.MethodPost, "application/x-www-form-urlencoded", false},
+		{http.MethodPost, "", false},
+		{http.MethodGet, "application/json", false},
+		{http.MethodDelete, "application/json", false},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/svc/iface", nil)
+		if c.ctype != "" {
+			req.Header.Set("Content-Type", c.ctype)
+		}
+		if hasJSONBody(req) != c.ok {
+			t.Fatalf("method:%s content-type:%s expect:%v", c.method, c.ctype, c.ok)
+		}
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	ns := newSchema(testSchemaVars(), meta.PostionRequestJSON)
+
+	cases := []struct {
+		query string
+		ok    bool
+	}{
+		{"ID=1&User=tian", true},
+		{"ID=1&User=tian&Tags=a", true},
+		{"ID=x&User=tian", false},
+		{"ID=1", false},
+		{"", false},
+	}
+
+	for _, c := range cases {
+		vals, _ := url.ParseQuery(c.query)
+		err := validateQuery(vals, ns)
+		if c.ok && err != nil {
+			t.Fatalf("query:%s, unexpected error:%v", c.query, err)
+		}
+		if !c.ok && errors.Cause(err) != errInvalidArgument {
+			t.Fatalf("query:%s, expect invalid argument, got:%v", c.query, err)
+		}
+	}
+}
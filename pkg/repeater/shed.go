@@ -0,0 +1,135 @@
+package repeater
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+)
+
+const (
+	// levelImportant 重要接口, 过载时最后被拒绝.
+	levelImportant = 0
+)
+
+// shedEntry 一个后端的并发上限及当前状态.
+type shedEntry struct {
+	Limit    float64
+	Inflight int
+	// MinRTT 当前窗口内的最小延迟, 作为后端不忙时的基准.
+	MinRTT time.Duration
+	// Start 当前窗口的开始时间.
+	Start int64
+	// Drop 上次下调并发上限的时间, 一个延迟周期内只下调一次.
+	Drop time.Time
+	Shed int64
+}
+
+// loadShedder 按后端的延迟自适应调整并发上限, 过载时先拒绝普通接口, 给重要接口保留一部分并发.
+type loadShedder struct {
+	backends map[string]*shedEntry
+	mu       sync.Mutex
+}
+
+func newLoadShedder() *loadShedder {
+	return &loadShedder{backends: make(map[string]*shedEntry)}
+}
+
+// entry 获取后端的记录, 没有时使用初始上限, 调用方加锁.
+func (s *loadShedder) entry(backend string) *shedEntry {
+	e, ok := s.backends[backend]
+	if !ok {
+		e = &shedEntry{Limit: float64(config.Repeater.Shed.InitLimit)}
+		s.backends[backend] = e
+	}
+	return e
+}
+
+// snapshot 复制每个后端当前的并发上限及状态.
+func (s *loadShedder) snapshot() map[string]shedEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	es := make(map[string]shedEntry, len(s.backends))
+	for k, e := range s.backends {
+		es[k] = *e
+	}
+
+	return es
+}
+
+// acquire 没有超过接口等级可用的并发时占用一个, 调用结束后需要调用返回的release.
+func (s *loadShedder) acquire(iface *meta.Interface) (func(time.Duration, bool), error) {
+	c := config.Repeater.Shed
+	if !c.Enable {
+		return func(time.Duration, bool) {}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entry(iface.Backend)
+	max := e.Limit
+	if iface.Level != levelImportant {
+		max = e.Limit * float64(100-c.Reserve) / 100
+	}
+
+	if float64(e.Inflight) >= max {
+		e.Shed++
+		stats.limit(iface.ID, limitShed)
+		return nil, errors.Annotatef(errOverloaded, "backend:%s inflight:%d limit:%.1f level:%d", iface.Backend, e.Inflight, e.Limit, iface.Level)
+	}
+
+	e.Inflight++
+
+	return func(rtt time.Duration, overload bool) {
+		s.release(iface.Backend, rtt, overload)
+	}, nil
+}
+
+// release 延迟超过基准的Tolerance或者后端过载时按比例下调上限, 上限用了一半以上且正常时缓慢增加.
+func (s *loadShedder) release(backend string, rtt time.Duration, overload bool) {
+	c := config.Repeater.Shed
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entry(backend)
+	e.Inflight--
+
+	//窗口过期后重新测量, 后端扩容或者变慢后基准能跟上
+	if now := time.Now().Unix(); e.MinRTT == 0 || now-e.Start >= int64(c.Window) {
+		e.MinRTT, e.Start = rtt, now
+	} else if rtt < e.MinRTT {
+		e.MinRTT = rtt
+	}
+
+	switch {
+	case overload || rtt > e.MinRTT*time.Duration(c.Tolerance)/100:
+		if time.Since(e.Drop) > rtt {
+			e.Limit = math.Max(float64(c.MinLimit), e.Limit*float64(c.Backoff)/100)
+			e.Drop = time.Now()
+		}
+	case float64(e.Inflight+1)*2 >= e.Limit:
+		e.Limit = math.Min(float64(c.MaxLimit), e.Limit+1/e.Limit)
+	}
+}
+
+// overloaded 后端返回的结果是否说明它已经过载.
+func overloaded(code int, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch code {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
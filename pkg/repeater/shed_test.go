@@ -0,0 +1,63 @@
+package repeater
+
+import (
+	"testing"
+	"time"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+)
+
+func TestLoadShedder(t *testing.T) {
+	stats = newStatsCache(nil)
+	c := &config.Repeater.Shed
+	c.Enable, c.InitLimit, c.MinLimit, c.MaxLimit = true, 10, 2, 20
+	c.Tolerance, c.Backoff, c.Reserve, c.Window = 200, 50, 20, 60
+
+	s := newLoadShedder()
+	normal := &meta.Interface{ID: 1, Backend: "b", Level: 1}
+	important := &meta.Interface{ID: 2, Backend: "b", Level: levelImportant}
+
+	var rs []func(time.Duration, bool)
+	for i := 0; i < 8; i++ {
+		r, err := s.acquire(normal)
+		if err != nil {
+			t.Fatalf("acquire %d error:%v", i, err)
+		}
+		rs = append(rs, r)
+	}
+
+	//普通接口只能用80%
+	if _, err := s.acquire(normal); err == nil {
+		t.Fatalf("expect normal interface shed")
+	}
+
+	for i := 0; i < 2; i++ {
+		r, err := s.acquire(important)
+		if err != nil {
+			t.Fatalf("important acquire %d error:%v", i, err)
+		}
+		rs = append(rs, r)
+	}
+
+	if _, err := s.acquire(important); err == nil {
+		t.Fatalf("expect important interface shed at full limit")
+	}
+
+	if n := stats.limitEntrys()[limitKey{1, limitShed}]; n != 1 {
+		t.Fatalf("expect 1 shed record, got:%d", n)
+	}
+
+	//正常的响应使上限缓慢增加, 超过基准两倍的响应使上限减半
+	rs[0](10*time.Millisecond, false)
+	if e := s.snapshot()["b"]; e.Limit <= 10 || e.Limit > 11 {
+		t.Fatalf("unexpected limit after normal response:%+v", e)
+	}
+
+	rs[1](100*time.Millisecond, false)
+
+	e := s.snapshot()["b"]
+	if e.Limit <= 5 || e.Limit > 5.5 || e.Inflight != 8 || e.MinRTT != 10*time.Millisecond {
+		t.Fatalf("unexpected entry after slow response:%+v", e)
+	}
+}
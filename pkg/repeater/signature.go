@@ -0,0 +1,122 @@
+package repeater
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util/signature"
+	"dearcode.net/doodle/pkg/util/token"
+)
+
+// authenticate 客户端证书绑定了应用时使用证书认证, 请求头中有Token使用Token认证, 否则使用签名认证, 并检查应用是否允许对应的认证方式.
+func (r *repeater) authenticate(req *http.Request, id string) (*meta.Application, *token.Claims, error) {
+	//双向TLS, 客户端证书绑定了应用时直接使用
+	app, err := certApp(req)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	if app != nil {
+		log.Infof("%s app:%d use client certificate", id, app.ID)
+		return app, nil, nil
+	}
+
+	t := req.Header.Get("Token")
+	if t == "" {
+		if req.Header.Get(signature.HeaderSignature) == "" {
+			return nil, nil, errors.Trace(errNotFoundToken)
+		}
+
+		app, err := r.verifySignature(req, id)
+		if err != nil {
+			log.Errorf("%s verify signature error, app:%v, err:%v", id, req.Header.Get(signature.HeaderAppID), err)
+			return nil, nil, errors.Trace(err)
+		}
+
+		if !app.AuthMode.AllowSignature() {
+			return nil, nil, errors.Annotatef(errForbidden, "app:%d signature auth disabled", app.ID)
+		}
+
+		return app, nil, nil
+	}
+
+	log.Infof("%s requset token is:%v", id, defaultRules.Value("Token", t))
+
+	app, claims, err := dc.getAppByToken(id, t)
+	if err != nil {
+		log.Errorf("%s get app error,token is:%v", id, defaultRules.Value("Token", t))
+		return nil, nil, errors.Trace(err)
+	}
+
+	if !app.AuthMode.AllowToken() {
+		return nil, nil, errors.Annotatef(errForbidden, "app:%d token auth disabled", app.ID)
+	}
+
+	return app, claims, nil
+}
+
+// verifySignature 验证请求签名, 时间误差超过MaxSkew或者随机串重复使用都会失败.
+func (r *repeater) verifySignature(req *http.Request, id string) (*meta.Application, error) {
+	appID, err := strconv.ParseInt(req.Header.Get(signature.HeaderAppID), 10, 64)
+	if err != nil {
+		return nil, errors.Annotatef(errInvalidSign, "invalid app id:%s", req.Header.Get(signature.HeaderAppID))
+	}
+
+	ts, err := strconv.ParseInt(req.Header.Get(signature.HeaderTimestamp), 10, 64)
+	if err != nil {
+		return nil, errors.Annotatef(errInvalidSign, "invalid timestamp:%s", req.Header.Get(signature.HeaderTimestamp))
+	}
+
+	skew := time.Now().Unix() - ts
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > int64(config.Repeater.Signature.MaxSkew) {
+		return nil, errors.Annotatef(errInvalidSign, "timestamp:%d skew:%d", ts, skew)
+	}
+
+	nonce := req.Header.Get(signature.HeaderNonce)
+	if nonce == "" {
+		return nil, errors.Annotatef(errInvalidSign, "nonce not found")
+	}
+
+	app, err := dc.getApplicationByID(appID)
+	if err != nil {
+		if errors.Cause(err) == errNotFound {
+			return nil, errors.Annotatef(errInvalidSign, "app:%d not found", appID)
+		}
+		return nil, errors.Trace(err)
+	}
+
+	if app.Secret == "" {
+		return nil, errors.Annotatef(errInvalidSign, "app:%d secret not set", appID)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, errors.Trace(err)
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	s := signature.StringToSign(req.Method, req.URL.Path, req.URL.RawQuery, signature.BodyHash(body), ts, nonce)
+	if !signature.Equal(signature.Sign(app.Secret, s), req.Header.Get(signature.HeaderSignature)) {
+		log.Debugf("%s app:%d string to sign:%q", id, appID, s)
+		return nil, errors.Annotatef(errInvalidSign, "app:%d signature mismatch", appID)
+	}
+
+	//签名通过后再记录随机串, 防止伪造的请求占用
+	if !r.nonces.AddIfAbsent(strconv.FormatInt(appID, 10)+"."+nonce, ts) {
+		return nil, errors.Annotatef(errInvalidSign, "app:%d nonce:%s replayed", appID, nonce)
+	}
+
+	return app, nil
+}
@@ -0,0 +1,247 @@
+package repeater
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+)
+
+const (
+	spoolFile = "stats.spool"
+
+	kindAccess  = "access"
+	kindVersion = "version"
+	kindRetry   = "retry"
+	kindLatency = "latency"
+	kindError   = "error"
+	kindLimit   = "limit"
+)
+
+// spoolRecord 一条统计记录, 数据库不可用时按行写入spool文件.
+type spoolRecord struct {
+	Kind    string
+	Iface   int64
+	App     int64     `json:",omitempty"`
+	Count   int       `json:",omitempty"`
+	Err     int       `json:",omitempty"`
+	Cost    int64     `json:",omitempty"`
+	Version string    `json:",omitempty"`
+	Backend string    `json:",omitempty"`
+	Buckets string    `json:",omitempty"`
+	Limit   string    `json:",omitempty"`
+	Session string    `json:",omitempty"`
+	Info    string    `json:",omitempty"`
+	Time    string    `json:",omitempty"`
+	Ctime   time.Time `json:",omitempty"`
+}
+
+// insert 写入数据库.
+func (r spoolRecord) insert() error {
+	switch r.Kind {
+	case kindAccess:
+		return dc.insertStats(r.Iface, r.App, r.Count, r.Err, r.Cost, r.Time)
+	case kindVersion:
+		return dc.insertVersionStats(r.Iface, r.Version, r.Count, r.Err, r.Cost, r.Time)
+	case kindRetry:
+		return dc.insertRetryStats(r.Iface, r.Backend, r.Count, r.Time)
+	case kindLatency:
+		return dc.insertLatencyStats(r.Iface, r.App, r.Buckets, r.Time)
+	case kindError:
+		return dc.insertErrorStats(r.Session, r.Iface, r.App, r.Info, r.Ctime)
+	case kindLimit:
+		return dc.insertLimitStats(r.Iface, r.Limit, r.Count, r.Time)
+	}
+
+	log.Errorf("drop unknown stats record:%+v", r)
+	return nil
+}
+
+// writeRecord 写入数据库, 数据库可用但写入失败的记录重试也没用, 直接丢弃.
+func writeRecord(r spoolRecord) error {
+	err := r.insert()
+	if err == nil {
+		return nil
+	}
+
+	if dc.available() {
+		log.Errorf("drop stats record:%+v, error:%v", r, errors.ErrorStack(err))
+		return nil
+	}
+
+	return errors.Trace(err)
+}
+
+// spoolStats spool当前状态, 管理接口查看.
+type spoolStats struct {
+	Depth    int
+	Bytes    int64
+	MaxBytes int64
+	Dropped  int64
+	Replayed int64
+}
+
+// spool 只追加的本地文件, 数据库恢复后按顺序重放.
+type spool struct {
+	path string
+	st   spoolStats
+	mu   sync.Mutex
+}
+
+// newSpool dir为none时不落盘, 返回nil, 启动时加载上次没有重放完的记录.
+func newSpool(dir string, maxSize int) (*spool, error) {
+	if dir == "none" || dir == "" {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	s := &spool{path: filepath.Join(dir, spoolFile)}
+	s.st.MaxBytes = int64(maxSize) << 20
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Trace(err)
+	}
+
+	s.st.Bytes = int64(len(data))
+	s.st.Depth = bytes.Count(data, []byte("\n"))
+
+	if s.st.Depth > 0 {
+		log.Warningf("spool %s has %d records to replay", s.path, s.st.Depth)
+	}
+
+	return s, nil
+}
+
+// stats 返回当前状态.
+func (s *spool) stats() spoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.st
+}
+
+// append 追加记录, 超过文件上限的记录丢弃.
+func (s *spool) append(rs []spoolRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(rs) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		s.st.Dropped += int64(len(rs))
+		return errors.Trace(err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	n := 0
+
+	for _, r := range rs {
+		line, err := json.Marshal(r)
+		if err != nil {
+			log.Errorf("marshal stats record:%+v error:%v", r, err)
+			s.st.Dropped++
+			continue
+		}
+
+		if s.st.Bytes+int64(buf.Len()+len(line)+1) > s.st.MaxBytes {
+			s.st.Dropped++
+			continue
+		}
+
+		buf.Write(line)
+		buf.WriteByte('\n')
+		n++
+	}
+
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		s.st.Dropped += int64(n)
+		return errors.Trace(err)
+	}
+
+	if err = f.Sync(); err != nil {
+		return errors.Trace(err)
+	}
+
+	s.st.Bytes += int64(buf.Len())
+	s.st.Depth += n
+
+	log.Warningf("spool %d stats records, depth:%d, bytes:%d, dropped:%d", n, s.st.Depth, s.st.Bytes, s.st.Dropped)
+
+	return nil
+}
+
+// replay 按顺序重放, write失败时保留剩余的记录并返回错误.
+func (s *spool) replay(write func(spoolRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.st.Depth == 0 {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for off := 0; off < len(data); {
+		end := bytes.IndexByte(data[off:], '\n')
+		if end < 0 {
+			//写了一半的行, 丢弃
+			log.Errorf("drop incomplete spool record:%s", data[off:])
+			break
+		}
+
+		var r spoolRecord
+		if err = json.Unmarshal(data[off:off+end], &r); err != nil {
+			log.Errorf("drop invalid spool record:%s, error:%v", data[off:off+end], err)
+		} else if err = write(r); err != nil {
+			return s.truncate(data[off:], err)
+		}
+
+		off += end + 1
+		s.st.Depth--
+		s.st.Replayed++
+	}
+
+	if err = os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return errors.Trace(err)
+	}
+
+	log.Infof("spool replay finished, replayed:%d", s.st.Replayed)
+	s.st.Bytes = 0
+	s.st.Depth = 0
+
+	return nil
+}
+
+// truncate 只保留还没重放的部分, 先写临时文件再改名.
+func (s *spool) truncate(left []byte, cause error) error {
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, left, 0644); err != nil {
+		return errors.Trace(err)
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return errors.Trace(err)
+	}
+
+	s.st.Bytes = int64(len(left))
+	s.st.Depth = bytes.Count(left, []byte("\n"))
+
+	return errors.Trace(cause)
+}
@@ -0,0 +1,92 @@
+package repeater
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/juju/errors"
+)
+
+func TestSpoolReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newSpool(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs := []spoolRecord{
+		{Kind: kindAccess, Iface: 1, App: 2, Count: 3, Time: "2026-01-01 10:00"},
+		{Kind: kindRetry, Iface: 1, Backend: "127.0.0.1:80", Count: 1, Time: "2026-01-01 10:00"},
+		{Kind: kindAccess, Iface: 1, App: 2, Count: 4, Time: "2026-01-01 10:01"},
+	}
+
+	if err = s.append(rs); err != nil {
+		t.Fatal(err)
+	}
+
+	//重新加载后还能看到积压的记录
+	if s, err = newSpool(dir, 1); err != nil {
+		t.Fatal(err)
+	}
+	if st := s.stats(); st.Depth != 3 {
+		t.Fatalf("expect depth 3, get:%+v", st)
+	}
+
+	//第二条写入失败, 保留剩下的记录
+	var got []spoolRecord
+	err = s.replay(func(r spoolRecord) error {
+		if r.Kind == kindRetry {
+			return errors.New("db down")
+		}
+		got = append(got, r)
+		return nil
+	})
+	if err == nil || len(got) != 1 || s.stats().Depth != 2 {
+		t.Fatalf("expect partial replay, err:%v, got:%v, stats:%+v", err, got, s.stats())
+	}
+
+	got = nil
+	if err = s.replay(func(r spoolRecord) error {
+		got = append(got, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || got[0].Kind != kindRetry || got[1].Time != "2026-01-01 10:01" {
+		t.Fatalf("invalid replay order:%+v", got)
+	}
+
+	if st := s.stats(); st.Depth != 0 || st.Bytes != 0 {
+		t.Fatalf("expect empty spool, get:%+v", st)
+	}
+}
+
+func TestSpoolMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newSpool(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.st.MaxBytes = 100
+
+	rs := []spoolRecord{{Kind: kindAccess, Iface: 1, Time: "2026-01-01 10:00"}, {Kind: kindAccess, Iface: 2, Time: "2026-01-01 10:00"}}
+	if err = s.append(rs); err != nil {
+		t.Fatal(err)
+	}
+
+	if st := s.stats(); st.Depth != 1 || st.Dropped != 1 || st.Bytes > st.MaxBytes {
+		t.Fatalf("expect 1 dropped, get:%+v", st)
+	}
+}
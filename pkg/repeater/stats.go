@@ -1,12 +1,15 @@
 package repeater
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
 	"dearcode.net/crab/log"
+	"github.com/juju/errors"
 
 	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util/histogram"
 )
 
 type errorEntry struct {
@@ -23,20 +26,174 @@ type entry struct {
 	Count int
 	Err   int
 	Time  int64
+	// hist 成功请求的耗时分布
+	hist *histogram.Histogram
 }
 
 type ifaceEntry struct {
 	apps map[int64]*entry
 }
 
+// versionEntry 按后端实例版本合并的统计.
+type versionEntry struct {
+	Iface   int64
+	Version string
+	Count   int
+	Err     int
+	Time    int64
+}
+
+type versionKey struct {
+	iface   int64
+	version string
+}
+
+// retryKey 按接口及后端地址合并重试次数.
+type retryKey struct {
+	iface   int64
+	backend string
+}
+
+// limitKey 按接口及限制类型合并被拒绝的请求数.
+type limitKey struct {
+	iface int64
+	kind  string
+}
+
 type statsCache struct {
-	access map[int64]*ifaceEntry
-	errors []*errorEntry
+	access   map[int64]*ifaceEntry
+	versions map[versionKey]*versionEntry
+	retries  map[retryKey]int
+	limits   map[limitKey]int
+	errors   []*errorEntry
+	spool    *spool
+	done     chan struct{}
+	stopped  chan struct{}
 	sync.Mutex
 }
 
-func newStatsCache() *statsCache {
-	return &statsCache{access: make(map[int64]*ifaceEntry)}
+func newStatsCache(sp *spool) *statsCache {
+	return &statsCache{
+		access:   make(map[int64]*ifaceEntry),
+		versions: make(map[versionKey]*versionEntry),
+		retries:  make(map[retryKey]int),
+		limits:   make(map[limitKey]int),
+		spool:    sp,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// retry 记录一次重试.
+func (s *statsCache) retry(iface int64, backend string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.retries[retryKey{iface, backend}]++
+}
+
+// limit 记录一次超出限制被拒绝的请求, 找不到接口时iface为0.
+func (s *statsCache) limit(iface int64, kind string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.limits[limitKey{iface, kind}]++
+}
+
+// limitEntrys 读取被拒绝的请求数, 并清理
+func (s *statsCache) limitEntrys() map[limitKey]int {
+	s.Lock()
+	defer s.Unlock()
+
+	ls := s.limits
+	s.limits = make(map[limitKey]int)
+	return ls
+}
+
+// pendingStats 还没有写入数据库的统计.
+type pendingStats struct {
+	Access   []entry
+	Versions []versionEntry
+	Retries  map[string]int
+	Limits   map[string]int
+	Errors   int
+	Spool    *spoolStats `json:",omitempty"`
+}
+
+// pending 查看还没写入数据库的统计, 不清理.
+func (s *statsCache) pending() pendingStats {
+	s.Lock()
+	defer s.Unlock()
+
+	p := pendingStats{Retries: make(map[string]int), Limits: make(map[string]int), Errors: len(s.errors)}
+
+	for _, ie := range s.access {
+		for _, e := range ie.apps {
+			p.Access = append(p.Access, *e)
+		}
+	}
+
+	for _, e := range s.versions {
+		p.Versions = append(p.Versions, *e)
+	}
+
+	for k, n := range s.retries {
+		p.Retries[fmt.Sprintf("%d.%s", k.iface, k.backend)] = n
+	}
+
+	for k, n := range s.limits {
+		p.Limits[fmt.Sprintf("%d.%s", k.iface, k.kind)] = n
+	}
+
+	if s.spool != nil {
+		st := s.spool.stats()
+		p.Spool = &st
+	}
+
+	return p
+}
+
+// retryEntrys 读取重试次数, 并清理
+func (s *statsCache) retryEntrys() map[retryKey]int {
+	s.Lock()
+	defer s.Unlock()
+
+	rs := s.retries
+	s.retries = make(map[retryKey]int)
+	return rs
+}
+
+// version 记录后端实例版本的调用结果, 用来对比灰度版本与线上版本.
+func (s *statsCache) version(iface int64, version string, tm int64, success bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	k := versionKey{iface, version}
+	e, ok := s.versions[k]
+	if !ok {
+		e = &versionEntry{Iface: iface, Version: version}
+		s.versions[k] = e
+	}
+
+	e.Count++
+	if !success {
+		e.Err++
+	}
+	e.Time += tm
+}
+
+// versionEntrys 读取版本统计信息, 并清理
+func (s *statsCache) versionEntrys() []versionEntry {
+	s.Lock()
+	defer s.Unlock()
+
+	var es []versionEntry
+	for k, e := range s.versions {
+		es = append(es, *e)
+		delete(s.versions, k)
+	}
+
+	return es
 }
 
 func (s *statsCache) success(app, iface, tm int64) {
@@ -63,6 +220,7 @@ func (s *statsCache) add(id string, app, iface, tm int64, success bool, msg stri
 		e = &entry{
 			App:   app,
 			Iface: iface,
+			hist:  histogram.New(),
 		}
 		ie.apps[app] = e
 	}
@@ -71,6 +229,8 @@ func (s *statsCache) add(id string, app, iface, tm int64, success bool, msg stri
 	if !success {
 		s.errors = append(s.errors, &errorEntry{id, app, iface, msg, time.Now().Add(time.Hour * 8)})
 		e.Err++
+	} else {
+		e.hist.Add(tm)
 	}
 	e.Time += tm
 	log.Debugf("new log:%+v", *e)
@@ -104,21 +264,88 @@ func (s *statsCache) errorEntrys() []*errorEntry {
 	return errs
 }
 
-func (s *statsCache) run() {
-	t := time.NewTicker(time.Duration(config.Repeater.Cache.Timeout) * time.Second)
-	for {
-		<-t.C
-		for _, e := range s.entrys() {
-			if err := dc.insertStats(e.Iface, e.App, e.Count, e.Err, e.Time); err != nil {
-				log.Errorf("insertStats %v error:%v", e, err.Error())
-			}
+// records 取出所有统计并清理, 转成按顺序写入的记录.
+func (s *statsCache) records() []spoolRecord {
+	var rs []spoolRecord
+	tm := time.Now().Format("2006-01-02 15:04")
+
+	for _, e := range s.entrys() {
+		rs = append(rs, spoolRecord{Kind: kindAccess, Iface: e.Iface, App: e.App, Count: e.Count, Err: e.Err, Cost: e.Time, Time: tm})
+		if e.hist.Count() != 0 {
+			rs = append(rs, spoolRecord{Kind: kindLatency, Iface: e.Iface, App: e.App, Buckets: e.hist.String(), Time: tm})
 		}
+	}
+
+	for _, e := range s.versionEntrys() {
+		rs = append(rs, spoolRecord{Kind: kindVersion, Iface: e.Iface, Version: e.Version, Count: e.Count, Err: e.Err, Cost: e.Time, Time: tm})
+	}
 
-		for _, e := range s.errorEntrys() {
-			if err := dc.insertErrorStats(e.Session, e.Iface, e.App, e.Info, e.Time); err != nil {
-				log.Errorf("insertErrorStats %v error:%v", e, err.Error())
+	for k, n := range s.retryEntrys() {
+		rs = append(rs, spoolRecord{Kind: kindRetry, Iface: k.iface, Backend: k.backend, Count: n, Time: tm})
+	}
+
+	for k, n := range s.limitEntrys() {
+		rs = append(rs, spoolRecord{Kind: kindLimit, Iface: k.iface, Limit: k.kind, Count: n, Time: tm})
+	}
+
+	for _, e := range s.errorEntrys() {
+		rs = append(rs, spoolRecord{Kind: kindError, Session: e.Session, Iface: e.Iface, App: e.App, Info: e.Info, Ctime: e.Time})
+	}
+
+	return rs
+}
+
+// flush 写入数据库, 先重放spool中积压的记录保证顺序, 数据库不可用时写入spool.
+func (s *statsCache) flush() {
+	rs := s.records()
+
+	if s.spool != nil {
+		if err := s.spool.replay(writeRecord); err != nil {
+			log.Errorf("spool replay error:%v", err)
+			if err = s.spool.append(rs); err != nil {
+				log.Errorf("spool append error:%v", errors.ErrorStack(err))
 			}
+			return
 		}
 	}
 
+	for i, r := range rs {
+		err := writeRecord(r)
+		if err == nil {
+			continue
+		}
+
+		if s.spool == nil {
+			log.Errorf("insert stats %+v error:%v", r, err)
+			continue
+		}
+
+		log.Errorf("insert stats error:%v, write to spool", err)
+		if err = s.spool.append(rs[i:]); err != nil {
+			log.Errorf("spool append error:%v", errors.ErrorStack(err))
+		}
+		return
+	}
+}
+
+func (s *statsCache) run() {
+	t := time.NewTicker(time.Duration(config.Repeater.Cache.Timeout) * time.Second)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			s.flush()
+		case <-s.done:
+			s.flush()
+			close(s.stopped)
+			return
+		}
+	}
+}
+
+// stop 退出前写入剩余的统计, 数据库不可用时写入spool.
+func (s *statsCache) stop() {
+	close(s.done)
+	<-s.stopped
 }
@@ -0,0 +1,320 @@
+package repeater
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util/cert"
+)
+
+const (
+	defaultCertName = "default"
+)
+
+var (
+	certs = &certStore{reload: make(chan struct{}, 1)}
+)
+
+// certStore 按域名保存证书, 握手时根据SNI选择.
+type certStore struct {
+	names  map[string]*tls.Certificate
+	def    *tls.Certificate
+	reload chan struct{}
+	mu     sync.RWMutex
+}
+
+// GetCertificate 先精确匹配, 再匹配通配符, 都没有时使用默认证书.
+func (s *certStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+
+	if c, ok := s.names[name]; ok {
+		return c, nil
+	}
+
+	if c, ok := s.names[cert.Wildcard(name)]; ok {
+		return c, nil
+	}
+
+	if s.def != nil {
+		return s.def, nil
+	}
+
+	return nil, errors.Errorf("certificate not found, server name:%s", hello.ServerName)
+}
+
+// add 证书中的域名及指定的域名都指向这个证书, 先加的优先.
+func add(names map[string]*tls.Certificate, c *tls.Certificate, domains ...string) {
+	for _, n := range append(domains, cert.Names(c.Leaf)...) {
+		if n = strings.ToLower(n); n == "" {
+			continue
+		}
+		if _, ok := names[n]; !ok {
+			names[n] = c
+		}
+	}
+}
+
+// load 加载目录及数据库中的证书, 数据库中的优先, 加载成功后整体替换.
+func (s *certStore) load() error {
+	names := make(map[string]*tls.Certificate)
+	var def *tls.Certificate
+
+	cs, err := dc.getCertificates()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, c := range cs {
+		kp, err := cert.KeyPair([]byte(c.Cert), []byte(c.Key))
+		if err != nil {
+			log.Errorf("invalid certificate:%d domain:%s, error:%v", c.ID, c.Domain, err)
+			continue
+		}
+		add(names, kp, c.Domain)
+		if c.Domain == defaultCertName {
+			def = kp
+		}
+	}
+
+	if dir := config.Repeater.TLS.CertDir; dir != "none" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.crt"))
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		for _, f := range files {
+			kp, err := loadCertFile(f)
+			if err != nil {
+				log.Errorf("load certificate:%s error:%v", f, err)
+				continue
+			}
+
+			name := strings.TrimSuffix(filepath.Base(f), ".crt")
+			if name == defaultCertName {
+				if def == nil {
+					def = kp
+				}
+				continue
+			}
+			add(names, kp)
+		}
+	}
+
+	s.mu.Lock()
+	s.names = names
+	s.def = def
+	s.mu.Unlock()
+
+	log.Infof("load %d certificate names, default:%v", len(names), def != nil)
+
+	return nil
+}
+
+// loadCertFile 读取证书及同名的.key私钥.
+func loadCertFile(f string) (*tls.Certificate, error) {
+	cb, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	kb, err := ioutil.ReadFile(strings.TrimSuffix(f, ".crt") + ".key")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return cert.KeyPair(cb, kb)
+}
+
+// notify manager修改证书时立即重新加载.
+func (s *certStore) notify() {
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+// run 定时重新加载, 证书文件更新后不用重启.
+func (s *certStore) run() {
+	t := time.NewTicker(time.Duration(config.Repeater.TLS.Reload) * time.Second)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-s.reload:
+		}
+
+		if err := s.load(); err != nil {
+			log.Errorf("reload certificates error:%v", errors.ErrorStack(err))
+		}
+	}
+}
+
+// TLSConfig https监听使用的配置, 开启定时加载证书, 配置了ClientCA时验证客户端证书.
+func TLSConfig() (*tls.Config, error) {
+	if err := certs.load(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	go certs.run()
+
+	tc := &tls.Config{
+		GetCertificate: certs.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		// 通过ALPN协商http2, 由http.Server处理h2
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	if ca := config.Repeater.TLS.ClientCA; ca != "none" {
+		buf, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			return nil, errors.Errorf("invalid client ca:%s", ca)
+		}
+
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+		if config.Repeater.TLS.RequireClientCert {
+			tc.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return tc, nil
+}
+
+// RedirectHTTPS http请求重定向到https监听地址, 管理接口除外.
+func RedirectHTTPS() http.Handler {
+	_, port, _ := net.SplitHostPort(config.Repeater.TLS.Addr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if adminOnGateway(req) {
+			Admin.ServeHTTP(w, req)
+			return
+		}
+
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		code := http.StatusPermanentRedirect
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), code)
+	})
+}
+
+// certApp 客户端证书验证通过并且绑定了应用时, 返回对应的应用.
+func certApp(req *http.Request) (*meta.Application, error) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+
+	return dc.getAppByCert(cert.Fingerprint(req.TLS.VerifiedChains[0][0].Raw))
+}
+
+// getCertificates 数据库中所有启用的证书, 不缓存.
+func (dc *dbCache) getCertificates() ([]*meta.Certificate, error) {
+	var rows *sql.Rows
+	var err error
+
+	if err = dc.dbQuery(func() error {
+		rows, err = dc.selCertificate.Query()
+		return err
+	}); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	defer rows.Close()
+
+	var cs []*meta.Certificate
+
+	for rows.Next() {
+		var c meta.Certificate
+		if err = rows.Scan(&c.ID, &c.Domain, &c.Cert, &c.Key); err != nil {
+			return nil, errors.Trace(err)
+		}
+		cs = append(cs, &c)
+	}
+
+	return cs, nil
+}
+
+// getAppByCert 根据证书指纹查找应用, 没有绑定时返回nil.
+func (dc *dbCache) getAppByCert(fp string) (*meta.Application, error) {
+	key := "\x0c" + fp
+
+	var id int64
+	if v := dc.cache.Get(key); v != nil {
+		id = v.(int64)
+	} else {
+		gen := dc.cache.Gen()
+		if err := dc.queryDB(dc.selAppByCert, []interface{}{fp}, []interface{}{&id}); err != nil && errors.Cause(err) != errNotFound {
+			return nil, errors.Trace(err)
+		}
+		dc.cache.AddSince(key, id, gen)
+	}
+
+	if id == 0 {
+		return nil, nil
+	}
+
+	a, err := dc.getApplicationByID(id)
+	if err != nil {
+		return nil, errors.Annotatef(err, "cert:%s app:%d", fp, id)
+	}
+
+	return a, nil
+}
+
+// certInfo 证书摘要, 管理接口查看.
+type certInfo struct {
+	Subject     string
+	NotAfter    time.Time
+	Fingerprint string
+}
+
+// snapshot 每个域名当前使用的证书.
+func (s *certStore) snapshot() map[string]certInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info := func(c *tls.Certificate) certInfo {
+		return certInfo{Subject: c.Leaf.Subject.String(), NotAfter: c.Leaf.NotAfter, Fingerprint: cert.Fingerprint(c.Leaf.Raw)}
+	}
+
+	m := make(map[string]certInfo)
+	for n, c := range s.names {
+		m[n] = info(c)
+	}
+	if s.def != nil {
+		m[defaultCertName] = info(s.def)
+	}
+
+	return m
+}
@@ -0,0 +1,72 @@
+package repeater
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestCert(t *testing.T, names ...string) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, _ := x509.ParseCertificate(der)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestCertStore(t *testing.T) {
+	a := newTestCert(t, "a.example.com")
+	w := newTestCert(t, "*.example.com")
+	d := newTestCert(t, "localhost")
+
+	names := make(map[string]*tls.Certificate)
+	add(names, a)
+	add(names, w, "example.com")
+
+	s := &certStore{names: names, def: d}
+
+	cases := map[string]*tls.Certificate{
+		"a.example.com":   a,
+		"A.Example.com.":  a,
+		"b.example.com":   w,
+		"example.com":     w,
+		"x.b.example.com": d,
+		"":                d,
+	}
+
+	for name, expect := range cases {
+		c, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != expect {
+			t.Fatalf("name:%s get cert:%v", name, c.Leaf.DNSNames)
+		}
+	}
+
+	s.def = nil
+	if _, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.com"}); err == nil {
+		t.Fatalf("expect error without default certificate")
+	}
+}
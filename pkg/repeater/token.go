@@ -0,0 +1,155 @@
+package repeater
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+	"dearcode.net/doodle/pkg/repeater/config"
+	"dearcode.net/doodle/pkg/util/token"
+)
+
+const (
+	legacyTimeLayout = "2006-01-02 15:04:05"
+)
+
+var (
+	// legacyMu 配置可以重新加载, 读写legacyUntil时加锁.
+	legacyMu sync.RWMutex
+	// legacyUntil 老格式token的截止时间, 为空时一直可用.
+	legacyUntil time.Time
+)
+
+// loadTokenConfig 解析老格式token的迁移截止时间.
+func loadTokenConfig() error {
+	var t time.Time
+
+	if s := config.Repeater.Token.LegacyUntil; s != "none" {
+		var err error
+		if t, err = time.ParseInLocation(legacyTimeLayout, s, time.Local); err != nil {
+			return errors.Annotatef(err, "invalid Token.LegacyUntil:%s", s)
+		}
+	}
+
+	legacyMu.Lock()
+	legacyUntil = t
+	legacyMu.Unlock()
+
+	return nil
+}
+
+// legacyAllowed 迁移期内老格式的token还可以用.
+func legacyAllowed() bool {
+	legacyMu.RLock()
+	defer legacyMu.RUnlock()
+	return legacyUntil.IsZero() || time.Now().Before(legacyUntil)
+}
+
+// tokenSecret 根据kid获取签名密钥.
+func (dc *dbCache) tokenSecret(kid string) (string, error) {
+	key := "\x07" + kid
+	if v := dc.cache.Get(key); v != nil {
+		return v.(string), nil
+	}
+
+	gen := dc.cache.Gen()
+
+	var secret string
+	if err := dc.queryDB(dc.selTokenKey, []interface{}{kid}, []interface{}{&secret}); err != nil {
+		if errors.Cause(err) == errNotFound {
+			return "", errors.Annotatef(errInvalidToken, "kid:%s not found", kid)
+		}
+		return "", errors.Trace(err)
+	}
+
+	dc.cache.AddSince(key, secret, gen)
+	return secret, nil
+}
+
+// isRevoked 检查token是否在吊销列表中.
+func (dc *dbCache) isRevoked(jti string) (bool, error) {
+	key := "\x08" + jti
+	if v := dc.cache.Get(key); v != nil {
+		return v.(int64) != 0, nil
+	}
+
+	gen := dc.cache.Gen()
+
+	var id int64
+	if err := dc.queryDB(dc.selRevoke, []interface{}{jti}, []interface{}{&id}); err != nil && errors.Cause(err) != errNotFound {
+		return false, errors.Trace(err)
+	}
+
+	dc.cache.AddSince(key, id, gen)
+	return id != 0, nil
+}
+
+// getAppV2 验证新格式token, 返回对应的应用及token内容.
+func (dc *dbCache) getAppV2(t string) (*meta.Application, *token.Claims, error) {
+	c, err := token.Verify(t, time.Now(), dc.tokenSecret)
+	if err != nil {
+		if cause := errors.Cause(err); cause == token.ErrMalformed || cause == token.ErrSignature || cause == token.ErrExpired {
+			return nil, nil, errors.Annotatef(errInvalidToken, "%v", err)
+		}
+		return nil, nil, errors.Trace(err)
+	}
+
+	revoked, err := dc.isRevoked(c.ID)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	if revoked {
+		return nil, nil, errors.Annotatef(errInvalidToken, "app:%d, jti:%s revoked", c.AppID, c.ID)
+	}
+
+	a, err := dc.getApplicationByID(c.AppID)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	return a, c, nil
+}
+
+// getApplicationByID 不验证token, 直接根据id查询应用.
+func (dc *dbCache) getApplicationByID(id int64) (*meta.Application, error) {
+	key := fmt.Sprintf("\x06%d", id)
+	if v := dc.cache.Get(key); v != nil {
+		return v.(*meta.Application), nil
+	}
+
+	gen := dc.cache.Gen()
+
+	a := meta.Application{ID: id}
+	if err := dc.queryDB(dc.selAppByID, []interface{}{id}, []interface{}{&a.Name, &a.Email, &a.Secret, &a.AuthMode, &a.AllowCIDR}); err != nil {
+		return nil, errors.Trace(err)
+	}
+	parseAllowNets(&a)
+
+	dc.cache.AddSince(key, &a, gen)
+	return &a, nil
+}
+
+// getAppByToken 根据token格式选择验证方式, 老格式的token只在迁移期内可用.
+func (dc *dbCache) getAppByToken(id, t string) (*meta.Application, *token.Claims, error) {
+	if token.IsV2(t) {
+		return dc.getAppV2(t)
+	}
+
+	if !legacyAllowed() {
+		return nil, nil, errors.Annotatef(errInvalidToken, "legacy token not allowed after %s", config.Repeater.Token.LegacyUntil)
+	}
+
+	app, err := dc.getApp(t)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	log.Warningf("%s app:%d use legacy token", id, app.ID)
+
+	return app, nil, nil
+}
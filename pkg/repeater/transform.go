@@ -0,0 +1,169 @@
+package repeater
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+var (
+	// transformVarExp 模板变量, 如: {app.id}, {header.X-Real-IP}, {param.id}.
+	transformVarExp = regexp.MustCompile(`{([\w.-]+)}`)
+)
+
+// transformVars 转换规则模板中可用的变量.
+type transformVars struct {
+	session string
+	app     *meta.Application
+	req     *http.Request
+	// path 调用方请求的路径, req.URL已经换成后端地址了.
+	path string
+	// params 路径模式捕获的参数.
+	params []meta.PathParam
+}
+
+// expand 替换模板中的变量, 不认识的变量保持原样.
+func (tv *transformVars) expand(tpl string) string {
+	return transformVarExp.ReplaceAllStringFunc(tpl, func(s string) string {
+		key := s[1 : len(s)-1]
+		switch {
+		case key == "session":
+			return tv.session
+		case key == "path":
+			return tv.path
+		case key == "app.id":
+			return strconv.FormatInt(tv.app.ID, 10)
+		case key == "app.name":
+			return tv.app.Name
+		case strings.HasPrefix(key, "header."):
+			return tv.req.Header.Get(key[len("header."):])
+		case strings.HasPrefix(key, "query."):
+			return tv.req.URL.Query().Get(key[len("query."):])
+		case strings.HasPrefix(key, "param."):
+			return pathParam(tv.params, key[len("param."):])
+		}
+		return s
+	})
+}
+
+// transformValues 在header或者url参数上执行一条规则.
+func transformValues(vs map[string][]string, canonical func(string) string, t *meta.Transform, val string) {
+	name := canonical(t.Name)
+
+	switch t.Action {
+	case meta.TransformAdd:
+		vs[name] = append(vs[name], val)
+	case meta.TransformSet:
+		vs[name] = []string{val}
+	case meta.TransformRemove:
+		delete(vs, name)
+	case meta.TransformRename:
+		if v, ok := vs[name]; ok {
+			delete(vs, name)
+			vs[canonical(t.Value)] = v
+		}
+	}
+}
+
+func keepName(name string) string {
+	return name
+}
+
+// transformForm 修改form表单中的参数, 只处理x-www-form-urlencoded格式的body.
+func transformForm(req *http.Request, ts []*meta.Transform, tv *transformVars) error {
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return nil
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	form, err := url.ParseQuery(string(buf))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, t := range ts {
+		if t.Target == meta.TransformForm {
+			transformValues(form, keepName, t, tv.expand(t.Value))
+		}
+	}
+
+	body := form.Encode()
+	req.Body = ioutil.NopCloser(bytes.NewBufferString(body))
+	req.ContentLength = int64(len(body))
+
+	return nil
+}
+
+// transformRequest 按规则顺序修改发往后端的请求.
+func transformRequest(req *http.Request, ts []*meta.Transform, tv *transformVars) error {
+	query := req.URL.Query()
+	hasQuery, hasForm := false, false
+
+	for _, t := range ts {
+		val := tv.expand(t.Value)
+
+		switch t.Target {
+		case meta.TransformHeader:
+			transformValues(req.Header, textproto.CanonicalMIMEHeaderKey, t, val)
+		case meta.TransformQuery:
+			transformValues(query, keepName, t, val)
+			hasQuery = true
+		case meta.TransformForm:
+			hasForm = true
+		case meta.TransformPath:
+			if t.Action != meta.TransformSet {
+				log.Errorf("%s transform:%d path only support set, action:%v", tv.session, t.ID, t.Action)
+				continue
+			}
+			req.URL.Path = val
+			req.URL.RawPath = ""
+		}
+	}
+
+	if hasQuery {
+		req.URL.RawQuery = query.Encode()
+	}
+
+	if hasForm {
+		return transformForm(req, ts, tv)
+	}
+
+	return nil
+}
+
+// transformResponse 修改返回给调用方的header, 改名的规则从后端返回的header中取值.
+func transformResponse(backend, h http.Header, ts []*meta.Transform, tv *transformVars) {
+	for _, t := range ts {
+		if t.Target != meta.TransformResponseHeader {
+			continue
+		}
+
+		switch t.Action {
+		case meta.TransformAdd:
+			h.Add(t.Name, tv.expand(t.Value))
+		case meta.TransformSet:
+			h.Set(t.Name, tv.expand(t.Value))
+		case meta.TransformRemove:
+			h.Del(t.Name)
+		case meta.TransformRename:
+			if vs := backend.Values(t.Name); len(vs) > 0 {
+				h.Del(t.Name)
+				h[textproto.CanonicalMIMEHeaderKey(t.Value)] = vs
+			}
+		}
+	}
+}
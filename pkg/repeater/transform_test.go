@@ -0,0 +1,109 @@
+package repeater
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dearcode.net/doodle/pkg/meta"
+)
+
+func TestTransformExpand(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://backend:8080/v1/orders/7?q=abc", nil)
+	req.Header.Set("X-Real-IP", "10.0.0.1")
+
+	tv := &transformVars{
+		session: "s1",
+		app:     &meta.Application{ID: 3, Name: "shop"},
+		req:     req,
+		path:    "/orders/7",
+		params:  []meta.PathParam{{Name: "id", Value: "7"}},
+	}
+
+	cases := []struct {
+		tpl    string
+		expect string
+	}{
+		{"{session}", "s1"},
+		{"{path}", "/orders/7"},
+		{"{app.id}-{app.name}", "3-shop"},
+		{"{header.X-Real-IP}", "10.0.0.1"},
+		{"{query.q}", "abc"},
+		{"/v2/orders/{param.id}", "/v2/orders/7"},
+		{"{unknown}", "{unknown}"},
+	}
+
+	for _, c := range cases {
+		if v := tv.expand(c.tpl); v != c.expect {
+			t.Fatalf("tpl:%s expect:%s, got:%s", c.tpl, c.expect, v)
+		}
+	}
+}
+
+func TestTransformRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "http://backend:8080/v1/orders?q=abc&del=1", strings.NewReader("a=1&b=2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Old", "old")
+	req.Header.Set("X-Drop", "drop")
+
+	ts := []*meta.Transform{
+		{Target: meta.TransformHeader, Action: meta.TransformAdd, Name: "x-app", Value: "{app.id}"},
+		{Target: meta.TransformHeader, Action: meta.TransformRemove, Name: "X-Drop"},
+		{Target: meta.TransformHeader, Action: meta.TransformRename, Name: "X-Old", Value: "X-New"},
+		{Target: meta.TransformQuery, Action: meta.TransformSet, Name: "q", Value: "xyz"},
+		{Target: meta.TransformQuery, Action: meta.TransformRemove, Name: "del"},
+		{Target: meta.TransformForm, Action: meta.TransformRename, Name: "a", Value: "c"},
+		{Target: meta.TransformPath, Action: meta.TransformSet, Value: "/v2{path}"},
+		{Target: meta.TransformPath, Action: meta.TransformAdd, Value: "/ignored"},
+	}
+
+	tv := &transformVars{session: "s1", app: &meta.Application{ID: 3}, req: req, path: "/orders"}
+	if err := transformRequest(req, ts, tv); err != nil {
+		t.Fatalf("transform error:%v", err)
+	}
+
+	if req.Header.Get("X-App") != "3" || req.Header.Get("X-Drop") != "" || req.Header.Get("X-Old") != "" || req.Header.Get("X-New") != "old" {
+		t.Fatalf("invalid header:%v", req.Header)
+	}
+
+	//{path}是调用方请求的路径, 不是后端地址的路径
+	if req.URL.Path != "/v2/orders" {
+		t.Fatalf("invalid path:%s", req.URL.Path)
+	}
+
+	if req.URL.RawQuery != "q=xyz" {
+		t.Fatalf("invalid query:%s", req.URL.RawQuery)
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	if string(body) != "b=2&c=1" || req.ContentLength != int64(len(body)) {
+		t.Fatalf("invalid form:%s, length:%d", body, req.ContentLength)
+	}
+}
+
+func TestTransformResponse(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	tv := &transformVars{session: "s1", app: &meta.Application{ID: 3}, req: req, path: "/orders"}
+
+	backend := http.Header{}
+	backend.Set("X-Backend-Id", "b1")
+
+	h := http.Header{}
+	h.Set("X-Backend-Id", "b1")
+	h.Set("Server", "nginx")
+
+	ts := []*meta.Transform{
+		{Target: meta.TransformHeader, Action: meta.TransformSet, Name: "X-Request", Value: "ignored"},
+		{Target: meta.TransformResponseHeader, Action: meta.TransformSet, Name: "X-Session", Value: "{session}"},
+		{Target: meta.TransformResponseHeader, Action: meta.TransformRemove, Name: "Server"},
+		{Target: meta.TransformResponseHeader, Action: meta.TransformRename, Name: "X-Backend-Id", Value: "X-Upstream"},
+	}
+
+	transformResponse(backend, h, ts, tv)
+
+	if h.Get("X-Session") != "s1" || h.Get("Server") != "" || h.Get("X-Backend-Id") != "" || h.Get("X-Upstream") != "b1" || h.Get("X-Request") != "" {
+		t.Fatalf("invalid response header:%v", h)
+	}
+}
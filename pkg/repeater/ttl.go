@@ -0,0 +1,203 @@
+package repeater
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ttlCache 按添加时间过期的缓存, 和crab/cache一样, 另外支持删除及调整超时时间.
+type ttlCache struct {
+	timeout int64
+	// max 最多缓存的个数, 超过时删除最早添加的, 0不限制.
+	max  int
+	vars map[string]*ttlEntry
+	ll   *list.List
+	// gen 每次删除加1, 从数据库读取前记下, 添加时不一样说明读取期间有删除, 读到的值可能已经过期.
+	gen uint64
+	mu  sync.Mutex
+}
+
+type ttlEntry struct {
+	key  string
+	last int64
+	val  interface{}
+	le   *list.Element
+}
+
+// newTTLCache 超时单位是秒.
+func newTTLCache(timeout int64) *ttlCache {
+	return &ttlCache{timeout: timeout, vars: make(map[string]*ttlEntry), ll: list.New()}
+}
+
+// SetTimeout 修改超时时间, 对已经缓存的key同样生效.
+func (c *ttlCache) SetTimeout(timeout int64) {
+	atomic.StoreInt64(&c.timeout, timeout)
+}
+
+// SetMaxSize 修改最多缓存的个数, 下次添加时生效.
+func (c *ttlCache) SetMaxSize(max int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.max = max
+}
+
+// Gen 当前的删除计数, 配合AddSince使用.
+func (c *ttlCache) Gen() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gen
+}
+
+// Get 获取未过期的值, 没有返回nil.
+func (c *ttlCache) Get(key string) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict()
+
+	if v, ok := c.vars[key]; ok {
+		return v.val
+	}
+
+	return nil
+}
+
+// Add 添加或者更新, 更新时同样刷新时间.
+func (c *ttlCache) Add(key string, val interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.add(key, val)
+}
+
+// AddSince gen之后没有删除过时添加, 否则不添加并返回false, 避免把删除前读到的旧值加回缓存.
+func (c *ttlCache) AddSince(key string, val interface{}, gen uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gen != gen {
+		return false
+	}
+
+	c.add(key, val)
+	return true
+}
+
+// AddIfAbsent key不存在时添加并返回true, 已存在返回false.
+func (c *ttlCache) AddIfAbsent(key string, val interface{}) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict()
+
+	if _, ok := c.vars[key]; ok {
+		return false
+	}
+
+	c.add(key, val)
+	return true
+}
+
+// Delete 删除指定key.
+func (c *ttlCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if v, ok := c.vars[key]; ok {
+		c.remove(v)
+	}
+}
+
+// DeleteFunc 删除所有前缀匹配, 并且match返回true的key, 返回删除个数.
+func (c *ttlCache) DeleteFunc(prefix string, match func(key string, val interface{}) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	n := 0
+	for k, v := range c.vars {
+		if strings.HasPrefix(k, prefix) && match(k, v.val) {
+			c.remove(v)
+			n++
+		}
+	}
+
+	return n
+}
+
+// ttlItem 缓存内容, 查看缓存时使用.
+type ttlItem struct {
+	Key   string
+	Age   int64
+	Value interface{}
+}
+
+// Snapshot 返回所有前缀匹配且未过期的缓存.
+func (c *ttlCache) Snapshot(prefix string) []ttlItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict()
+
+	now := time.Now().Unix()
+	var items []ttlItem
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		v := e.Value.(*ttlEntry)
+		if strings.HasPrefix(v.key, prefix) {
+			items = append(items, ttlItem{Key: v.key, Age: now - v.last, Value: v.val})
+		}
+	}
+
+	return items
+}
+
+// Purge 清空.
+func (c *ttlCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.vars = make(map[string]*ttlEntry)
+	c.ll.Init()
+}
+
+// add 添加或者更新并移到最前面, 超过个数上限时删除最早添加的, 调用方加锁.
+func (c *ttlCache) add(key string, val interface{}) {
+	now := time.Now().Unix()
+
+	if v, ok := c.vars[key]; ok {
+		v.val, v.last = val, now
+		c.ll.MoveToFront(v.le)
+		return
+	}
+
+	v := &ttlEntry{key: key, val: val, last: now}
+	v.le = c.ll.PushFront(v)
+	c.vars[key] = v
+
+	for c.max > 0 && c.ll.Len() > c.max {
+		c.remove(c.ll.Back().Value.(*ttlEntry))
+	}
+}
+
+func (c *ttlCache) remove(v *ttlEntry) {
+	c.ll.Remove(v.le)
+	delete(c.vars, v.key)
+}
+
+func (c *ttlCache) evict() {
+	last := time.Now().Unix() - atomic.LoadInt64(&c.timeout)
+	for b := c.ll.Back(); b != nil; b = c.ll.Back() {
+		e := b.Value.(*ttlEntry)
+		if last < e.last {
+			break
+		}
+		c.remove(e)
+	}
+}
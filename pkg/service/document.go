@@ -173,6 +173,7 @@ type field struct {
 	Name      string
 	Type      string
 	Required  bool
+	Sensitive bool              `json:",omitempty"`
 	Child     map[string]*field `json:",omitempty"`
 	Comment   string
 	anonymous bool
@@ -274,6 +275,10 @@ func newField(sf reflect.StructField) *field {
 		f.Required = true
 	}
 
+	if s := sf.Tag.Get("sensitive"); s == "true" {
+		f.Sensitive = true
+	}
+
 	if n := strings.Split(sf.Tag.Get("json"), ",")[0]; n != "" {
 		f.Name = n
 	}
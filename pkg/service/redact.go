@@ -0,0 +1,85 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"dearcode.net/doodle/pkg/util/redact"
+)
+
+var (
+	// methodRules 每个请求类型的脱敏规则, 通用规则加上sensitive标签的字段.
+	methodRules sync.Map
+)
+
+// rulesOf 获取请求类型对应的脱敏规则.
+func rulesOf(t reflect.Type) *redact.Rules {
+	if v, ok := methodRules.Load(t); ok {
+		return v.(*redact.Rules)
+	}
+
+	r := redact.New(*redactField, *redactHead, *logMaxSize)
+	sensitiveFields(t, r, 0)
+
+	v, _ := methodRules.LoadOrStore(t, r)
+	return v.(*redact.Rules)
+}
+
+// sensitiveFields 和文档中一样按json名称递归查找带sensitive:"true"标签的字段.
+func sensitiveFields(t reflect.Type, r *redact.Rules, depth int) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || depth > 8 {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Tag.Get("sensitive") == "true" {
+			name := sf.Name
+			if n := strings.Split(sf.Tag.Get("json"), ",")[0]; n != "" {
+				name = n
+			}
+			r.AddField(name)
+		}
+		sensitiveFields(sf.Type, r, depth+1)
+	}
+}
+
+// logBody 生成日志中的内容, 跳过请求头.
+func logBody(v reflect.Value) []byte {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		buf, _ := json.Marshal(v.Interface())
+		return buf
+	}
+
+	m := make(map[string]interface{})
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" || sf.Type.String() == "service.RequestHeader" {
+			continue
+		}
+
+		name := sf.Name
+		if n := strings.Split(sf.Tag.Get("json"), ",")[0]; n == "-" {
+			continue
+		} else if n != "" {
+			name = n
+		}
+
+		m[name] = v.Field(i).Interface()
+	}
+
+	buf, _ := json.Marshal(m)
+	return buf
+}
@@ -18,12 +18,15 @@ import (
 	"github.com/juju/errors"
 
 	"dearcode.net/doodle/pkg/service/debug"
+	"dearcode.net/doodle/pkg/util/trace"
 )
 
 // RequestHeader 默认请求头.
 type RequestHeader struct {
 	Session string
 	Request http.Request
+	// Trace 本次请求的span, 调用其它服务时用Trace.Child生成子span, 并用trace.Inject写入请求头.
+	Trace *trace.Span `json:"-"`
 }
 
 // String session id.
@@ -50,6 +53,12 @@ var (
 	logLevel    = flag.String("logLevel", "debug", "log level: fatal, error, warning, debug, info.")
 	logFile     = flag.String("logFile", "", "log file name.")
 	etcdAddrs   = flag.String("etcd", "", "etcd Endpoints, like 192.168.180.104:12379,192.168.180.104:22379,192.168.180.104:32379.")
+	traceExp    = flag.String("traceExporter", trace.ExporterNone, "trace exporter: none, file, otlp.")
+	traceAddr   = flag.String("traceEndpoint", "http://127.0.0.1:4318", "otlp http collector address.")
+	traceFile   = flag.String("traceFile", "./trace.log", "trace file name, used by file exporter.")
+	redactField = flag.String("redactFields", "password,passwd,secret,token,idcard", "field names or json paths masked in log, separated by comma.")
+	redactHead  = flag.String("redactHeaders", "Token,Authorization,Cookie,X-Signature", "header names masked in log, separated by comma.")
+	logMaxSize  = flag.Int("logMaxSize", 4096, "max body size in log, 0 means no limit.")
 	maxWaitTime = time.Hour * 24
 )
 
@@ -78,6 +87,10 @@ func (s *Service) Init() {
 
 	log.SetLevelByString(*logLevel)
 
+	if err := trace.Init(path.Base(os.Args[0]), *traceExp, *traceAddr, *traceFile); err != nil {
+		log.Errorf("trace init error:%v", errors.ErrorStack(err))
+	}
+
 	server.RegisterPrefix(&debug.Debug{}, "/debug/pprof/")
 	server.RegisterPrefix(&debug.Version{}, "/debug/version/")
 	server.RegisterPrefix(&s.doc, "/document/")
@@ -142,6 +155,7 @@ func (s *Service) Start() {
 
 	sig := <-shutdown
 	keepalive.stop()
+	trace.Stop()
 	log.Warningf("%v recv signal %v, close:%v", os.Getpid(), sig, ln.Close())
 
 	log.Warningf("%v wait timeout:%v.", os.Getpid(), maxWaitTime)
@@ -1,10 +1,13 @@
 package service
 
 import (
+	"fmt"
 	"net/http"
 	"reflect"
 
 	"dearcode.net/crab/http/server"
+	"dearcode.net/crab/log"
+	"dearcode.net/doodle/pkg/util/trace"
 	"dearcode.net/doodle/pkg/util/uuid"
 	"github.com/hokaccha/go-prettyjson"
 )
@@ -17,32 +20,59 @@ func transport(w http.ResponseWriter, r *http.Request, m reflect.Method) {
 	reqVal := reflect.New(reqType)
 	respVal := reflect.New(respType)
 
+	//接着网关或者调用方的trace继续
+	parent, _ := trace.Extract(r.Header)
+	span := trace.Start(parent, r.URL.Path, trace.KindServer)
+	span.SetAttr("http.method", r.Method)
+	r = r.WithContext(trace.NewContext(r.Context(), span))
+
+	session := r.Header.Get("Session")
+	if session == "" {
+		session = uuid.String()
+	}
+	span.SetAttr("session", session)
+
 	header := reqVal.Elem().FieldByName("RequestHeader")
 	if header.IsValid() {
-		s := r.Header.Get("Session")
-		if s == "" {
-			s = uuid.String()
-		}
-		header.FieldByName("Session").SetString(s)
+		header.FieldByName("Session").SetString(session)
 		header.FieldByName("Request").Set(reflect.ValueOf(*r))
 	}
 
 	//先解析url中参数
 	if err := server.ParseVars(r, reqVal.Interface()); err != nil {
+		span.Finish(err)
 		server.SendErrorDetail(w, http.StatusBadRequest, nil, err.Error())
 		return
 	}
 
+	//放在解析参数之后, 防止被请求中的同名参数覆盖
+	if header.IsValid() {
+		header.FieldByName("Trace").Set(reflect.ValueOf(span))
+	}
+
 	switch r.Method {
 	case http.MethodGet, http.MethodDelete, http.MethodPost, http.MethodPut:
 	default:
+		span.Finish(fmt.Errorf("unspport method %v", r.Method))
 		server.SendResponse(w, http.StatusBadRequest, "unspport method %v", r.Method)
 		return
 	}
 
+	//只在debug级别记录内容, 避免每次请求都序列化
+	debug := log.GetLogLevel() >= log.LogDebug
+	if debug {
+		log.Debugf("%s %s %s request:%s", session, r.Method, r.URL.Path, rulesOf(reqType).Body(logBody(reqVal)))
+	}
+
 	argv := []reflect.Value{reflect.New(m.Type.In(0)).Elem(), reqVal.Elem(), respVal}
 	m.Func.Call(argv)
 
+	span.Finish(responseError(respVal))
+
+	if debug {
+		log.Debugf("%s %s %s response:%s", session, r.Method, r.URL.Path, rulesOf(respType).Body(logBody(respVal)))
+	}
+
 	if _, ok := r.URL.Query()["_v"]; ok {
 		b, _ := prettyjson.Marshal(respVal.Interface())
 		w.Write(b)
@@ -53,6 +83,25 @@ func transport(w http.ResponseWriter, r *http.Request, m reflect.Method) {
 	server.SendData(w, respVal.Interface())
 }
 
+// responseError 返回头中的状态不是成功时, 记录到span中.
+func responseError(respVal reflect.Value) error {
+	if respVal.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	header := respVal.Elem().FieldByName("ResponseHeader")
+	if !header.IsValid() {
+		return nil
+	}
+
+	status := header.FieldByName("Status").Int()
+	if status == 0 || status == http.StatusOK {
+		return nil
+	}
+
+	return fmt.Errorf("status:%d, message:%s", status, header.FieldByName("Message").String())
+}
+
 func (s *Service) handler(w http.ResponseWriter, r *http.Request) {
 	m, ok := s.router.get(r.Method, r.URL.Path)
 
@@ -0,0 +1,70 @@
+package cert
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Fingerprint 证书DER编码的sha256, 16进制小写.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// ParsePEM 解析PEM格式证书, 多个证书时只取第一个.
+func ParsePEM(s string) (*x509.Certificate, error) {
+	b, _ := pem.Decode([]byte(s))
+	if b == nil || b.Type != "CERTIFICATE" {
+		return nil, errors.New("invalid pem certificate")
+	}
+
+	c, err := x509.ParseCertificate(b.Bytes)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return c, nil
+}
+
+// KeyPair 解析证书及私钥, 并填充Leaf.
+func KeyPair(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	c, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if c.Leaf, err = x509.ParseCertificate(c.Certificate[0]); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &c, nil
+}
+
+// Names 证书中的域名, 没有SAN时使用CN, 全部小写.
+func Names(c *x509.Certificate) []string {
+	var ns []string
+	for _, n := range c.DNSNames {
+		ns = append(ns, strings.ToLower(n))
+	}
+
+	if len(ns) == 0 && c.Subject.CommonName != "" {
+		ns = append(ns, strings.ToLower(c.Subject.CommonName))
+	}
+
+	return ns
+}
+
+// Wildcard 域名对应的通配符, a.b.com返回*.b.com, 没有上级域名时返回空.
+func Wildcard(host string) string {
+	i := strings.IndexByte(host, '.')
+	if i < 0 || i == len(host)-1 {
+		return ""
+	}
+	return "*" + host[i:]
+}
@@ -0,0 +1,20 @@
+package cert
+
+import (
+	"testing"
+)
+
+func TestWildcard(t *testing.T) {
+	cases := map[string]string{
+		"a.b.com":   "*.b.com",
+		"b.com":     "*.com",
+		"localhost": "",
+		"a.":        "",
+	}
+
+	for host, expect := range cases {
+		if w := Wildcard(host); w != expect {
+			t.Fatalf("host:%s expect:%s, get:%s", host, expect, w)
+		}
+	}
+}
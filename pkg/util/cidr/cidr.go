@@ -0,0 +1,95 @@
+package cidr
+
+import (
+	"net"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// List 网段列表.
+type List []*net.IPNet
+
+// Parse 解析逗号分隔的网段, 单个IP按/32或/128处理, 空串返回空列表.
+func Parse(s string) (List, error) {
+	var l List
+
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, errors.Errorf("invalid ip:%s", v)
+			}
+			if ip.To4() != nil {
+				v += "/32"
+			} else {
+				v += "/128"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid cidr:%s", v)
+		}
+		l = append(l, n)
+	}
+
+	return l, nil
+}
+
+// Contains ip是否在任意一个网段内.
+func (l List) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range l {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String 逗号分隔的网段.
+func (l List) String() string {
+	ss := make([]string, 0, len(l))
+	for _, n := range l {
+		ss = append(ss, n.String())
+	}
+	return strings.Join(ss, ",")
+}
+
+// ClientIP 获取请求方真实IP, 只有直连地址是可信代理时才使用X-Forwarded-For,
+// 从右往左跳过可信代理, 第一个不可信的地址就是客户端.
+func ClientIP(remoteAddr, forwardedFor string, trusted List) net.IP {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !trusted.Contains(ip) || forwardedFor == "" {
+		return ip
+	}
+
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			//格式不对, 不能再相信更左边的内容
+			return ip
+		}
+		if !trusted.Contains(hop) {
+			return hop
+		}
+		ip = hop
+	}
+
+	return ip
+}
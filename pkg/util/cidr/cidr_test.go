@@ -0,0 +1,35 @@
+package cidr
+
+import (
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := Parse("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		remote string
+		xff    string
+		ip     string
+	}{
+		{"1.1.1.1:80", "2.2.2.2", "1.1.1.1"},
+		{"10.1.1.1:80", "", "10.1.1.1"},
+		{"10.1.1.1:80", "2.2.2.2", "2.2.2.2"},
+		{"10.1.1.1:80", "3.3.3.3, 2.2.2.2, 192.168.1.1", "2.2.2.2"},
+		{"10.1.1.1:80", "10.2.2.2, 192.168.1.1", "10.2.2.2"},
+		{"10.1.1.1:80", "2.2.2.2, xx", "10.1.1.1"},
+	}
+
+	for _, c := range cases {
+		if ip := ClientIP(c.remote, c.xff, trusted); ip.String() != c.ip {
+			t.Fatalf("remote:%s xff:%s expect:%s, get:%v", c.remote, c.xff, c.ip, ip)
+		}
+	}
+
+	if _, err = Parse("10.0.0.0/33"); err == nil {
+		t.Fatalf("expect invalid cidr error")
+	}
+}
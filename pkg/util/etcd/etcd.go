@@ -23,6 +23,11 @@ type Client struct {
 var (
 	//networkTimeout 超时.
 	networkTimeout = time.Second * 3
+
+	// ErrCompacted 要监控的版本已经被压缩, 中间的事件丢失了.
+	ErrCompacted = errors.New("revision compacted")
+	// ErrWatchClosed 监控被关闭.
+	ErrWatchClosed = errors.New("watch closed")
 )
 
 func etcdAddrs(addr ...string) []string {
@@ -126,6 +131,51 @@ func (e *Client) WatchPrefix(key string, ec chan clientv3.Event) {
 	}
 }
 
+// WatchPrefixRev 从rev开始监控指定前缀, rev为0时从当前版本开始.
+// 监控建立后调用created, 每个事件调用handle, 中断时返回下次应该开始的版本.
+func (e *Client) WatchPrefixRev(key string, rev int64, created func(), handle func(clientv3.Event)) (int64, error) {
+	watcher := clientv3.NewWatcher(e.client)
+	defer watcher.Close()
+
+	//没有leader时直接中断, 不要一直等着
+	ctx, cancel := context.WithCancel(clientv3.WithRequireLeader(e.client.Ctx()))
+	defer cancel()
+
+	opts := []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithCreatedNotify()}
+	if rev > 0 {
+		opts = append(opts, clientv3.WithRev(rev))
+	}
+
+	for resp := range watcher.Watch(ctx, key, opts...) {
+		if resp.CompactRevision != 0 {
+			return resp.CompactRevision, errors.Annotatef(ErrCompacted, "key:%s, rev:%d, compact:%d", key, rev, resp.CompactRevision)
+		}
+
+		if err := resp.Err(); err != nil {
+			return rev, errors.Annotatef(err, "key:%s, rev:%d", key, rev)
+		}
+
+		if resp.Created {
+			if rev == 0 {
+				rev = resp.Header.Revision + 1
+			}
+			created()
+			continue
+		}
+
+		for _, ev := range resp.Events {
+			handle(*ev)
+			rev = ev.Kv.ModRevision + 1
+		}
+	}
+
+	if err := e.client.Ctx().Err(); err != nil {
+		return rev, errors.Trace(err)
+	}
+
+	return rev, errors.Annotatef(ErrWatchClosed, "key:%s, rev:%d", key, rev)
+}
+
 // Put 写.
 func (e *Client) Put(key, val string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), networkTimeout)
@@ -173,6 +223,12 @@ func (e *Client) Keepalive(key, val string) (clientv3.Lease, error) {
 
 }
 
+// SetEndpoints 修改etcd地址, 已有的连接在下次重连时使用新地址.
+func (e *Client) SetEndpoints(addr ...string) {
+	log.Infof("set endpoints:%v", addr)
+	e.client.SetEndpoints(etcdAddrs(addr...)...)
+}
+
 // Close 关闭客户端
 func (e *Client) Close() {
 	log.Debugf("client close")
@@ -0,0 +1,147 @@
+package graceful
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+)
+
+const (
+	// envListeners 父进程传过来的监听名, 逗号分隔, 按顺序对应从3开始的fd.
+	envListeners = "DOODLE_LISTENERS"
+	// envParent 父进程pid, 新进程准备好后通知它退出.
+	envParent = "DOODLE_PARENT"
+	// firstFD ExtraFiles从3开始, 0,1,2是标准输入输出.
+	firstFD = 3
+)
+
+var (
+	mu          sync.Mutex
+	names       []string
+	listeners   = make(map[string]*net.TCPListener)
+	inherited   map[string]*os.File
+	inheritOnce sync.Once
+)
+
+// inherit 取出父进程传过来的fd, 清掉环境变量, 避免再传给下一个进程.
+func inherit() {
+	inherited = make(map[string]*os.File)
+
+	s := os.Getenv(envListeners)
+	if s == "" {
+		return
+	}
+	os.Unsetenv(envListeners)
+
+	for i, name := range strings.Split(s, ",") {
+		inherited[name] = os.NewFile(uintptr(firstFD+i), name)
+	}
+}
+
+// Listen 优先使用父进程传过来的同名监听, 没有时监听addr, 修改监听地址需要完整重启.
+func Listen(name, addr string) (net.Listener, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	inheritOnce.Do(inherit)
+
+	var ln net.Listener
+	var err error
+
+	if f, ok := inherited[name]; ok {
+		delete(inherited, name)
+		ln, err = net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Annotatef(err, "inherit listener:%s", name)
+		}
+		log.Infof("inherit listener:%s addr:%v", name, ln.Addr())
+	} else if ln, err = net.Listen("tcp", addr); err != nil {
+		return nil, errors.Annotatef(err, "listen:%s addr:%s", name, addr)
+	}
+
+	tl, ok := ln.(*net.TCPListener)
+	if !ok {
+		ln.Close()
+		return nil, errors.Errorf("listener:%s is not tcp", name)
+	}
+
+	if _, ok = listeners[name]; !ok {
+		names = append(names, name)
+	}
+	listeners[name] = tl
+
+	return tl, nil
+}
+
+// Restart 使用相同参数启动新进程, 并把所有监听传给它, 新进程调用Ready后当前进程会收到SIGUSR1.
+func Restart() (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, name := range names {
+		f, err := listeners[name].File()
+		if err != nil {
+			return 0, errors.Annotatef(err, "listener:%s", name)
+		}
+		files = append(files, f)
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(), envListeners+"="+strings.Join(names, ","), fmt.Sprintf("%s=%d", envParent, os.Getpid()))
+
+	if err = cmd.Start(); err != nil {
+		return 0, errors.Annotatef(err, "start:%s", path)
+	}
+
+	//新进程启动失败时回收, 成功时当前进程先退出
+	go cmd.Wait()
+
+	log.Infof("restart new process:%d, listeners:%v", cmd.Process.Pid, names)
+	return cmd.Process.Pid, nil
+}
+
+// Ready 新进程开始服务后通知父进程停止接收连接, 不是由Restart启动的什么都不做.
+func Ready() error {
+	s := os.Getenv(envParent)
+	if s == "" {
+		return nil
+	}
+	os.Unsetenv(envParent)
+
+	pid, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.Annotatef(err, "invalid parent:%s", s)
+	}
+
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	log.Infof("notify parent:%d to drain", pid)
+	return errors.Trace(p.Signal(syscall.SIGUSR1))
+}
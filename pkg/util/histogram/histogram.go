@@ -0,0 +1,196 @@
+package histogram
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// Size 桶个数, 最后一个桶保存所有超出上限的值.
+	Size = 82
+	// perDouble 每翻一倍分几个桶, 相对误差大概在19%以内.
+	perDouble = 4
+)
+
+var (
+	// bounds 每个桶的上限(不含), 单位毫秒, 按2^(i/4)增长, 最大约17分钟.
+	bounds [Size]float64
+)
+
+func init() {
+	for i := 0; i < Size-1; i++ {
+		bounds[i] = math.Pow(2, float64(i)/perDouble)
+	}
+	bounds[Size-1] = math.Inf(1)
+}
+
+// Histogram 固定对数刻度的耗时直方图, 可以直接相加合并.
+type Histogram struct {
+	counts [Size]int64
+}
+
+// Bucket 非空的桶, 画图用.
+type Bucket struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int64   `json:"count"`
+}
+
+// New 创建空的直方图.
+func New() *Histogram {
+	return &Histogram{}
+}
+
+func index(v int64) int {
+	if v < 1 {
+		return 0
+	}
+	i := int(math.Log2(float64(v))*perDouble) + 1
+	if i >= Size {
+		return Size - 1
+	}
+	// 浮点误差修正
+	for i > 0 && float64(v) < bounds[i-1] {
+		i--
+	}
+	for i < Size-1 && float64(v) >= bounds[i] {
+		i++
+	}
+	return i
+}
+
+func lower(i int) float64 {
+	if i == 0 {
+		return 0
+	}
+	return bounds[i-1]
+}
+
+// Add 添加一个耗时, 单位毫秒.
+func (h *Histogram) Add(ms int64) {
+	h.counts[index(ms)]++
+}
+
+// Merge 合并另一个直方图.
+func (h *Histogram) Merge(o *Histogram) {
+	for i, n := range o.counts {
+		h.counts[i] += n
+	}
+}
+
+// Count 总数.
+func (h *Histogram) Count() int64 {
+	var n int64
+	for _, c := range h.counts {
+		n += c
+	}
+	return n
+}
+
+// Quantile 计算分位数, q取值0-1, 在桶内线性插值, 没有数据返回0.
+func (h *Histogram) Quantile(q float64) float64 {
+	total := h.Count()
+	if total == 0 {
+		return 0
+	}
+
+	if q < 0 {
+		q = 0
+	}
+	if q > 1 {
+		q = 1
+	}
+
+	rank := q * float64(total)
+	var sum float64
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		if sum+float64(c) >= rank {
+			lo, hi := lower(i), bounds[i]
+			if math.IsInf(hi, 1) {
+				return lo
+			}
+			return lo + (hi-lo)*(rank-sum)/float64(c)
+		}
+		sum += float64(c)
+	}
+
+	return bounds[Size-2]
+}
+
+// Buckets 返回所有非空的桶.
+func (h *Histogram) Buckets() []Bucket {
+	var bs []Bucket
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		upper := bounds[i]
+		if math.IsInf(upper, 1) {
+			upper = -1
+		}
+		bs = append(bs, Bucket{Lower: lower(i), Upper: upper, Count: c})
+	}
+	return bs
+}
+
+// String 只保存非空的桶, 格式: 下标:个数,下标:个数.
+func (h *Histogram) String() string {
+	var ss []string
+	for i, c := range h.counts {
+		if c != 0 {
+			ss = append(ss, fmt.Sprintf("%d:%d", i, c))
+		}
+	}
+	return strings.Join(ss, ",")
+}
+
+// Parse 解析String的结果.
+func Parse(s string) (*Histogram, error) {
+	h := New()
+	if s = strings.TrimSpace(s); s == "" {
+		return h, nil
+	}
+
+	for _, kv := range strings.Split(s, ",") {
+		p := strings.SplitN(kv, ":", 2)
+		if len(p) != 2 {
+			return nil, errors.Errorf("invalid bucket:%s", kv)
+		}
+		i, err := strconv.Atoi(p[0])
+		if err != nil || i < 0 || i >= Size {
+			return nil, errors.Errorf("invalid bucket index:%s", kv)
+		}
+		c, err := strconv.ParseInt(p[1], 10, 64)
+		if err != nil || c < 0 {
+			return nil, errors.Errorf("invalid bucket count:%s", kv)
+		}
+		h.counts[i] += c
+	}
+
+	return h, nil
+}
+
+// ParseQuantiles 解析百分位列表, 如: 50,95,99.9, 返回排好序的0-1之间的值.
+func ParseQuantiles(s string) ([]float64, error) {
+	var qs []float64
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 100 {
+			return nil, errors.Errorf("invalid quantile:%s", v)
+		}
+		qs = append(qs, f/100)
+	}
+	sort.Float64s(qs)
+	return qs, nil
+}
@@ -0,0 +1,46 @@
+package histogram
+
+import (
+	"testing"
+)
+
+func TestQuantile(t *testing.T) {
+	a, b := New(), New()
+	for i := int64(1); i <= 1000; i++ {
+		if i%2 == 0 {
+			a.Add(i)
+		} else {
+			b.Add(i)
+		}
+	}
+
+	h, err := Parse(a.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Merge(b)
+
+	if h.Count() != 1000 {
+		t.Fatalf("expect 1000, get:%d", h.Count())
+	}
+
+	for _, q := range []float64{0.5, 0.95, 0.99} {
+		expect := q * 1000
+		if v := h.Quantile(q); v < expect*0.8 || v > expect*1.2 {
+			t.Fatalf("q:%v expect about %v, get:%v", q, expect, v)
+		}
+	}
+
+	if New().Quantile(0.5) != 0 {
+		t.Fatalf("empty histogram should be 0")
+	}
+}
+
+func TestIndex(t *testing.T) {
+	for _, v := range []int64{0, 1, 2, 3, 7, 8, 100, 1 << 19, 1 << 40} {
+		i := index(v)
+		if float64(v) < lower(i) || float64(v) >= bounds[i] {
+			t.Fatalf("v:%d index:%d [%v, %v)", v, i, lower(i), bounds[i])
+		}
+	}
+}
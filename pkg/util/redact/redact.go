@@ -0,0 +1,207 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Mask 替换敏感内容.
+	Mask = "******"
+)
+
+// Rules 日志脱敏规则, 字段按名称或者点分隔的json路径匹配, 不区分大小写.
+type Rules struct {
+	fields  map[string]bool
+	headers map[string]bool
+	// MaxSize 日志中body的最大长度, 0不限制.
+	MaxSize int
+}
+
+// New 字段及头都是逗号分隔.
+func New(fields, headers string, maxSize int) *Rules {
+	r := &Rules{fields: make(map[string]bool), headers: make(map[string]bool), MaxSize: maxSize}
+
+	for _, f := range strings.Split(fields, ",") {
+		r.AddField(f)
+	}
+
+	for _, h := range strings.Split(headers, ",") {
+		r.AddHeader(h)
+	}
+
+	return r
+}
+
+// AddField 添加需要脱敏的字段名或路径.
+func (r *Rules) AddField(name string) {
+	if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+		r.fields[name] = true
+	}
+}
+
+// AddHeader 添加需要脱敏的头.
+func (r *Rules) AddHeader(name string) {
+	if name = strings.TrimSpace(name); name != "" {
+		r.headers[http.CanonicalHeaderKey(name)] = true
+	}
+}
+
+// Merge 合并规则生成新的规则, o中MaxSize大于0时覆盖.
+func (r *Rules) Merge(o *Rules) *Rules {
+	n := New("", "", r.MaxSize)
+	for _, s := range []*Rules{r, o} {
+		for k := range s.fields {
+			n.fields[k] = true
+		}
+		for k := range s.headers {
+			n.headers[k] = true
+		}
+	}
+
+	if o.MaxSize > 0 {
+		n.MaxSize = o.MaxSize
+	}
+
+	return n
+}
+
+func (r *Rules) matchField(name, path string) bool {
+	return r.fields[strings.ToLower(name)] || r.fields[strings.ToLower(path)]
+}
+
+// Value 头在规则中时返回掩码.
+func (r *Rules) Value(header, val string) string {
+	if val != "" && r.headers[http.CanonicalHeaderKey(header)] {
+		return Mask
+	}
+	return val
+}
+
+// Header 复制一份脱敏后的头.
+func (r *Rules) Header(h http.Header) http.Header {
+	n := make(http.Header, len(h))
+	for k, vs := range h {
+		if r.headers[http.CanonicalHeaderKey(k)] {
+			n[k] = []string{Mask}
+			continue
+		}
+		n[k] = vs
+	}
+	return n
+}
+
+// URL 对url参数脱敏.
+func (r *Rules) URL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+
+	n := *u
+	q, ok := r.form(u.RawQuery)
+	if !ok {
+		q = r.unknown([]byte(u.RawQuery))
+	}
+	n.RawQuery = q
+	return n.String()
+}
+
+// Body 对json或者form格式的body脱敏, 并按MaxSize截断, 有字段规则时无法解析的body只记录长度.
+func (r *Rules) Body(buf []byte) string {
+	return r.truncate(r.body(buf))
+}
+
+func (r *Rules) body(buf []byte) string {
+	b := bytes.TrimSpace(buf)
+	if len(b) == 0 {
+		return ""
+	}
+
+	if b[0] == '{' || b[0] == '[' {
+		var v interface{}
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return r.unknown(buf)
+		}
+
+		nb, err := json.Marshal(r.walk(v, ""))
+		if err != nil {
+			return r.unknown(buf)
+		}
+		return string(nb)
+	}
+
+	if bytes.IndexByte(b, '=') > 0 && bytes.IndexAny(b, " \n\t") == -1 {
+		if s, ok := r.form(string(b)); ok {
+			return s
+		}
+	}
+
+	return r.unknown(buf)
+}
+
+// unknown 不知道敏感字段在哪, 没有字段规则时原样返回, 否则用掩码及长度代替.
+func (r *Rules) unknown(buf []byte) string {
+	if len(r.fields) == 0 {
+		return string(buf)
+	}
+	return fmt.Sprintf("%s(%d bytes)", Mask, len(buf))
+}
+
+// walk 递归替换命中的字段, 数组不增加路径层级.
+func (r *Rules) walk(v interface{}, path string) interface{} {
+	switch nv := v.(type) {
+	case map[string]interface{}:
+		for k, sv := range nv {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			if r.matchField(k, p) {
+				nv[k] = Mask
+				continue
+			}
+			nv[k] = r.walk(sv, p)
+		}
+	case []interface{}:
+		for i, sv := range nv {
+			nv[i] = r.walk(sv, path)
+		}
+	}
+
+	return v
+}
+
+// form 对form格式的参数脱敏, 解析失败返回false.
+func (r *Rules) form(s string) (string, bool) {
+	vs, err := url.ParseQuery(s)
+	if err != nil {
+		return "", false
+	}
+
+	masked := false
+	for k := range vs {
+		if r.matchField(k, k) {
+			vs[k] = []string{Mask}
+			masked = true
+		}
+	}
+
+	if !masked {
+		return s, true
+	}
+
+	return vs.Encode(), true
+}
+
+func (r *Rules) truncate(s string) string {
+	if r.MaxSize <= 0 || len(s) <= r.MaxSize {
+		return s
+	}
+	return fmt.Sprintf("%s...(%d bytes)", s[:r.MaxSize], len(s))
+}
@@ -0,0 +1,54 @@
+package redact
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBody(t *testing.T) {
+	r := New("password, user.idcard", "Token", 0)
+
+	s := r.Body([]byte(`{"name":"doodle","Password":"123","user":{"idcard":"110","list":[{"password":"456"}]},"idcard":"220"}`))
+	for _, v := range []string{"123", "110", "456"} {
+		if strings.Contains(s, v) {
+			t.Fatalf("%s not masked:%s", v, s)
+		}
+	}
+
+	if !strings.Contains(s, "220") || !strings.Contains(s, "doodle") {
+		t.Fatalf("invalid masked:%s", s)
+	}
+
+	if s = r.Body([]byte("name=doodle&password=123")); strings.Contains(s, "123") {
+		t.Fatalf("form not masked:%s", s)
+	}
+
+	u, _ := url.Parse("http://127.0.0.1/dbs/query?password=123&id=1")
+	if s = r.URL(u); strings.Contains(s, "123") {
+		t.Fatalf("url not masked:%s", s)
+	}
+
+	h := http.Header{}
+	h.Set("token", "abc")
+	if nh := r.Header(h); nh.Get("Token") != Mask || h.Get("Token") != "abc" {
+		t.Fatalf("invalid header:%v, src:%v", nh, h)
+	}
+
+	for _, b := range []string{`{"password":"123"`, "password: 123", "password=12%3"} {
+		if s = r.Body([]byte(b)); s != fmt.Sprintf("%s(%d bytes)", Mask, len(b)) {
+			t.Fatalf("unknown body:%s, got:%s", b, s)
+		}
+	}
+
+	if s = New("", "", 0).Body([]byte("password: 123")); s != "password: 123" {
+		t.Fatalf("body without rules changed:%s", s)
+	}
+
+	r.MaxSize = 8
+	if s = r.Body([]byte(`{"id":123456}`)); s != `{"id":12...(13 bytes)` {
+		t.Fatalf("invalid truncate:%s", s)
+	}
+}
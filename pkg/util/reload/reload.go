@@ -0,0 +1,139 @@
+package reload
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"dearcode.net/crab/log"
+)
+
+// Report 一次重新加载的结果, 字段格式为Section.Field.
+type Report struct {
+	// Applied 已经生效的字段.
+	Applied []string
+	// Restart 修改了但需要重启才能生效的字段.
+	Restart []string
+}
+
+// Changed 按字段比较新旧配置, 返回值不同的字段, 嵌套的结构按Section.Field展开.
+func Changed(old, new interface{}) []string {
+	return changed("", reflect.Indirect(reflect.ValueOf(old)), reflect.Indirect(reflect.ValueOf(new)))
+}
+
+func changed(prefix string, ov, nv reflect.Value) []string {
+	if ov.Kind() != reflect.Struct {
+		if reflect.DeepEqual(ov.Interface(), nv.Interface()) {
+			return nil
+		}
+		return []string{prefix}
+	}
+
+	var fs []string
+	for i := 0; i < ov.NumField(); i++ {
+		f := ov.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		fs = append(fs, changed(name, ov.Field(i), nv.Field(i))...)
+	}
+
+	return fs
+}
+
+// Match 字段是否在列表中, 列表中可以是整个配置段或者具体字段.
+func Match(field string, list []string) bool {
+	for _, l := range list {
+		if field == l || strings.HasPrefix(field, l+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Set 把src中的字段复制到dst, dst必须是指针, 字段格式与Changed返回的一致.
+func Set(dst, src interface{}, field string) {
+	dv, sv := reflect.ValueOf(dst).Elem(), reflect.Indirect(reflect.ValueOf(src))
+	for _, name := range strings.Split(field, ".") {
+		dv, sv = dv.FieldByName(name), sv.FieldByName(name)
+	}
+	dv.Set(sv)
+}
+
+// Apply 比较新旧配置, hot中的字段复制到cur中生效, 其它修改的字段记录为需要重启.
+func Apply(cur, next interface{}, hot []string) Report {
+	var r Report
+
+	for _, f := range Changed(cur, next) {
+		if !Match(f, hot) {
+			r.Restart = append(r.Restart, f)
+			continue
+		}
+		Set(cur, next, f)
+		r.Applied = append(r.Applied, f)
+	}
+
+	return r
+}
+
+// Sections 字段所在的配置段, 去掉重复的, 按出现顺序.
+func (r Report) Sections() []string {
+	var ss []string
+	seen := make(map[string]bool)
+
+	for _, f := range r.Applied {
+		s := strings.SplitN(f, ".", 2)[0]
+		if !seen[s] {
+			seen[s] = true
+			ss = append(ss, s)
+		}
+	}
+
+	return ss
+}
+
+// Watch 收到SIGHUP或者配置文件的修改时间变化时调用fn, interval为0时只响应SIGHUP.
+func Watch(path string, interval time.Duration, fn func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	var mtime time.Time
+
+	if interval > 0 {
+		if fi, err := os.Stat(path); err == nil {
+			mtime = fi.ModTime()
+		}
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case s := <-hup:
+			log.Infof("recv signal %v, reload config:%s", s, path)
+			fn()
+		case <-tick:
+			fi, err := os.Stat(path)
+			if err != nil {
+				log.Errorf("stat config:%s error:%v", path, err)
+				continue
+			}
+			if fi.ModTime().Equal(mtime) {
+				continue
+			}
+			mtime = fi.ModTime()
+			log.Infof("config:%s modified, reload", path)
+			fn()
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package reload
+
+import (
+	"reflect"
+	"testing"
+)
+
+type dbConfig struct {
+	IP   string
+	Port int
+}
+
+type testConfig struct {
+	DB    dbConfig
+	Cache struct {
+		Timeout int
+	}
+	Addr string
+}
+
+func TestApply(t *testing.T) {
+	cur := testConfig{DB: dbConfig{IP: "10.0.0.1", Port: 3306}, Addr: ":8000"}
+	cur.Cache.Timeout = 60
+
+	next := cur
+	next.DB.IP = "10.0.0.2"
+	next.Cache.Timeout = 120
+	next.Addr = ":9000"
+
+	r := Apply(&cur, &next, []string{"DB", "Cache.Timeout"})
+
+	if !reflect.DeepEqual(r.Applied, []string{"DB.IP", "Cache.Timeout"}) {
+		t.Fatalf("unexpected applied:%v", r.Applied)
+	}
+	if !reflect.DeepEqual(r.Restart, []string{"Addr"}) {
+		t.Fatalf("unexpected restart:%v", r.Restart)
+	}
+	if !reflect.DeepEqual(r.Sections(), []string{"DB", "Cache"}) {
+		t.Fatalf("unexpected sections:%v", r.Sections())
+	}
+
+	if cur.DB.IP != "10.0.0.2" || cur.Cache.Timeout != 120 || cur.Addr != ":8000" {
+		t.Fatalf("unexpected config:%+v", cur)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	list := []string{"DB", "Limit.MaxBodySize"}
+
+	for f, expect := range map[string]bool{
+		"DB.IP":               true,
+		"DBX.IP":              false,
+		"Limit.MaxBodySize":   true,
+		"Limit.MaxHeaderSize": false,
+	} {
+		if Match(f, list) != expect {
+			t.Fatalf("field:%s expect:%v", f, expect)
+		}
+	}
+}
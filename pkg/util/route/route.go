@@ -0,0 +1,189 @@
+package route
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Wildcard 结尾/*匹配到的剩余路径对应的参数名.
+const Wildcard = "*"
+
+var (
+	paramExp = regexp.MustCompile(`^{(\w+)}$`)
+)
+
+// IsPattern 路径是否为模式, ~开头为正则, 包含{name}或*的按段匹配, 其它都是精确路径.
+func IsPattern(p string) bool {
+	return strings.HasPrefix(p, "~") || strings.ContainsAny(p, "{*")
+}
+
+// node 按路径段组成的树, 每个节点上字面量优先, 其次参数, 再次单段通配, 最后是结尾的前缀匹配.
+type node struct {
+	children map[string]*node
+	param    *node
+	wild     *node
+	// leaf 路径在这里结束的接口.
+	leaf *leaf
+	// prefix 以/*结尾, 匹配剩余任意路径的接口.
+	prefix *leaf
+}
+
+type leaf struct {
+	id    int64
+	names []string
+}
+
+type regexRoute struct {
+	id  int64
+	exp *regexp.Regexp
+}
+
+// Table 编译好的路径模式, 只读, 修改需要重新生成.
+type Table struct {
+	root    node
+	regexps []regexRoute
+}
+
+// New 空路由表.
+func New() *Table {
+	return &Table{}
+}
+
+// Add 添加一个接口的路径模式, 同一路径重复添加时使用先添加的.
+func (t *Table) Add(id int64, pattern string) error {
+	if strings.HasPrefix(pattern, "~") {
+		exp, err := regexp.Compile("^(?:" + pattern[1:] + ")$")
+		if err != nil {
+			return errors.Annotatef(err, "invalid regexp:%s", pattern)
+		}
+		t.regexps = append(t.regexps, regexRoute{id, exp})
+		return nil
+	}
+
+	if !strings.HasPrefix(pattern, "/") {
+		return errors.Errorf("pattern:%s must start with /", pattern)
+	}
+
+	n := &t.root
+	var names []string
+	segs := strings.Split(pattern[1:], "/")
+
+	for i, s := range segs {
+		switch {
+		case s == Wildcard && i == len(segs)-1:
+			if n.prefix == nil {
+				n.prefix = &leaf{id, append(names, Wildcard)}
+			}
+			return nil
+		case s == Wildcard:
+			if n.wild == nil {
+				n.wild = &node{}
+			}
+			n = n.wild
+		case paramExp.MatchString(s):
+			names = append(names, s[1:len(s)-1])
+			if n.param == nil {
+				n.param = &node{}
+			}
+			n = n.param
+		case strings.ContainsAny(s, "{}*"):
+			return errors.Errorf("pattern:%s invalid segment:%s", pattern, s)
+		default:
+			if n.children == nil {
+				n.children = make(map[string]*node)
+			}
+			c, ok := n.children[s]
+			if !ok {
+				c = &node{}
+				n.children[s] = c
+			}
+			n = c
+		}
+	}
+
+	if n.leaf == nil {
+		n.leaf = &leaf{id, names}
+	}
+
+	return nil
+}
+
+// Match 查找路径对应的接口, 返回接口id及捕获的参数.
+func (t *Table) Match(path string) (int64, map[string]string, bool) {
+	if strings.HasPrefix(path, "/") {
+		if l, vals := t.root.match(strings.Split(path[1:], "/"), nil); l != nil {
+			return l.id, l.params(vals), true
+		}
+	}
+
+	for _, r := range t.regexps {
+		m := r.exp.FindStringSubmatch(path)
+		if m == nil {
+			continue
+		}
+
+		ps := make(map[string]string)
+		for i, name := range r.exp.SubexpNames() {
+			if name != "" {
+				ps[name] = m[i]
+			}
+		}
+		return r.id, ps, true
+	}
+
+	return 0, nil, false
+}
+
+// match 深度优先查找, 字面量没有匹配成功时回退到参数及通配.
+func (n *node) match(segs []string, vals []string) (*leaf, []string) {
+	if len(segs) == 0 {
+		if n.leaf != nil {
+			return n.leaf, vals
+		}
+		if n.prefix != nil {
+			return n.prefix, append(vals, "")
+		}
+		return nil, nil
+	}
+
+	s, rest := segs[0], segs[1:]
+
+	if c, ok := n.children[s]; ok {
+		if l, vs := c.match(rest, vals); l != nil {
+			return l, vs
+		}
+	}
+
+	if n.param != nil && s != "" {
+		if l, vs := n.param.match(rest, append(vals, s)); l != nil {
+			return l, vs
+		}
+	}
+
+	if n.wild != nil && s != "" {
+		if l, vs := n.wild.match(rest, vals); l != nil {
+			return l, vs
+		}
+	}
+
+	if n.prefix != nil {
+		return n.prefix, append(vals, strings.Join(segs, "/"))
+	}
+
+	return nil, nil
+}
+
+func (l *leaf) params(vals []string) map[string]string {
+	ps := make(map[string]string, len(l.names))
+	for i, name := range l.names {
+		ps[name] = vals[i]
+	}
+	return ps
+}
+
+// Validate 检查路径模式是否合法.
+func Validate(pattern string) error {
+	return New().Add(0, pattern)
+}
@@ -0,0 +1,66 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	rt := New()
+	for id, p := range map[int64]string{
+		1: "/orders/{id}",
+		2: "/orders/list",
+		3: "/orders/{id}/items/{item}",
+		4: "/static/*",
+		5: "/users/*/profile",
+		6: `~/v(?P<version>\d+)/ping`,
+		7: "/static/special",
+	} {
+		if err := rt.Add(id, p); err != nil {
+			t.Fatalf("add %s error:%v", p, err)
+		}
+	}
+
+	cases := []struct {
+		path   string
+		id     int64
+		params map[string]string
+	}{
+		{"/orders/12", 1, map[string]string{"id": "12"}},
+		{"/orders/list", 2, map[string]string{}},
+		{"/orders/12/items/3", 3, map[string]string{"id": "12", "item": "3"}},
+		{"/static/css/a.css", 4, map[string]string{"*": "css/a.css"}},
+		{"/static", 4, map[string]string{"*": ""}},
+		{"/static/special", 7, map[string]string{}},
+		{"/static/special/x", 4, map[string]string{"*": "special/x"}},
+		{"/users/tom/profile", 5, map[string]string{}},
+		{"/v2/ping", 6, map[string]string{"version": "2"}},
+		{"/orders", 0, nil},
+		{"/orders/12/items", 0, nil},
+		{"/users/tom", 0, nil},
+	}
+
+	for _, c := range cases {
+		id, ps, ok := rt.Match(c.path)
+		if ok != (c.id != 0) || id != c.id {
+			t.Fatalf("path:%s expect:%d, got:%d %v", c.path, c.id, id, ok)
+		}
+		if ok && !reflect.DeepEqual(ps, c.params) {
+			t.Fatalf("path:%s expect params:%v, got:%v", c.path, c.params, ps)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, p := range []string{"/a/{b}", "/a/*", "/*/b", "~^/a/[0-9]+$"} {
+		if err := Validate(p); err != nil {
+			t.Fatalf("pattern:%s error:%v", p, err)
+		}
+	}
+
+	for _, p := range []string{"a/{b}", "/a/{b", "/a/x*", "~/a/(", "/a/{b-c}"} {
+		if err := Validate(p); err == nil {
+			t.Fatalf("pattern:%s expect error", p)
+		}
+	}
+}
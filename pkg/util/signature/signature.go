@@ -0,0 +1,79 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// HeaderAppID 应用id.
+	HeaderAppID = "X-App-Id"
+	// HeaderTimestamp 签名时间, unix秒.
+	HeaderTimestamp = "X-Timestamp"
+	// HeaderNonce 随机串, 同一个应用在有效期内不能重复.
+	HeaderNonce = "X-Nonce"
+	// HeaderSignature 签名结果.
+	HeaderSignature = "X-Signature"
+)
+
+// BodyHash body的sha256, 16进制小写.
+func BodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// StringToSign 待签名的串, 每项一行: 方法, 路径, url参数, body哈希, 时间戳, 随机串.
+func StringToSign(method, path, query, bodyHash string, timestamp int64, nonce string) string {
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		query,
+		bodyHash,
+		strconv.FormatInt(timestamp, 10),
+		nonce,
+	}, "\n")
+}
+
+// Sign hmac-sha256签名, base64编码.
+func Sign(secret, s string) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(s))
+	return base64.StdEncoding.EncodeToString(m.Sum(nil))
+}
+
+// Equal 比较签名, 防止时序攻击.
+func Equal(a, b string) bool {
+	return hmac.Equal([]byte(a), []byte(b))
+}
+
+// SignRequest 给请求签名并设置签名相关的头, 调用方使用.
+func SignRequest(req *http.Request, appID int64, secret string, timestamp int64, nonce string) error {
+	var body []byte
+
+	if req.Body != nil {
+		buf, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+		body = buf
+	}
+
+	s := StringToSign(req.Method, req.URL.Path, req.URL.RawQuery, BodyHash(body), timestamp, nonce)
+
+	req.Header.Set(HeaderAppID, strconv.FormatInt(appID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, Sign(secret, s))
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package signature
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestSignRequest(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://127.0.0.1/dbs/query?id=1", bytes.NewBufferString(`{"name":"doodle"}`))
+	if err := SignRequest(req, 7, "secret", 1500000000, "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	if string(body) != `{"name":"doodle"}` {
+		t.Fatalf("body not restored:%s", body)
+	}
+
+	s := StringToSign("post", "/dbs/query", "id=1", BodyHash(body), 1500000000, "abc")
+	if !Equal(Sign("secret", s), req.Header.Get(HeaderSignature)) {
+		t.Fatalf("signature mismatch")
+	}
+
+	if Equal(Sign("other", s), req.Header.Get(HeaderSignature)) {
+		t.Fatalf("signature should mismatch with other secret")
+	}
+}
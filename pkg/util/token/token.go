@@ -0,0 +1,143 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// Version 新格式token的前缀, 格式为: v2.kid.payload.signature.
+	Version = "v2"
+)
+
+var (
+	// ErrMalformed token格式错误.
+	ErrMalformed = errors.New("malformed token")
+	// ErrSignature 签名不匹配.
+	ErrSignature = errors.New("invalid token signature")
+	// ErrExpired token已过期.
+	ErrExpired = errors.New("token expired")
+)
+
+// Claims token中的内容.
+type Claims struct {
+	// AppID 应用id.
+	AppID int64 `json:"app"`
+	// IssuedAt 签发时间, unix秒.
+	IssuedAt int64 `json:"iat"`
+	// ExpiresAt 过期时间, unix秒.
+	ExpiresAt int64 `json:"exp"`
+	// Scopes 允许访问的服务路径或者服务路径/接口路径, 为空时不限制.
+	Scopes []string `json:"scp,omitempty"`
+	// ID token的唯一id, 吊销时使用.
+	ID string `json:"jti"`
+}
+
+// Allow 检查资源是否在scope内, 资源格式为服务路径/接口路径.
+func (c *Claims) Allow(resource string) bool {
+	if len(c.Scopes) == 0 {
+		return true
+	}
+
+	resource = strings.Trim(resource, "/")
+	for _, s := range c.Scopes {
+		s = strings.Trim(s, "/")
+		if s == "*" || s == resource || strings.HasPrefix(resource, s+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewID 生成token id.
+func NewID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// NewSecret 生成签名用的密钥.
+func NewSecret() string {
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// IsV2 是否为新格式的token.
+func IsV2(t string) bool {
+	return strings.HasPrefix(t, Version+".")
+}
+
+func sign(secret, data string) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// Sign 使用kid对应的密钥签发token.
+func Sign(kid, secret string, c Claims) (string, error) {
+	if kid == "" || strings.Contains(kid, ".") {
+		return "", errors.Annotatef(ErrMalformed, "invalid kid:%s", kid)
+	}
+
+	buf, err := json.Marshal(&c)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	data := Version + "." + kid + "." + base64.RawURLEncoding.EncodeToString(buf)
+
+	return data + "." + sign(secret, data), nil
+}
+
+// Parse 解析token, 只检查格式, 不验证签名.
+func Parse(t string) (kid string, c Claims, err error) {
+	ss := strings.Split(t, ".")
+	if len(ss) != 4 || ss[0] != Version || ss[1] == "" {
+		return "", c, errors.Trace(ErrMalformed)
+	}
+
+	buf, err := base64.RawURLEncoding.DecodeString(ss[2])
+	if err != nil {
+		return "", c, errors.Annotatef(ErrMalformed, "decode payload:%v", err)
+	}
+
+	if err = json.Unmarshal(buf, &c); err != nil {
+		return "", c, errors.Annotatef(ErrMalformed, "unmarshal payload:%v", err)
+	}
+
+	return ss[1], c, nil
+}
+
+// Verify 验证签名及有效期, secret根据kid返回对应的密钥.
+func Verify(t string, now time.Time, secret func(kid string) (string, error)) (*Claims, error) {
+	kid, c, err := Parse(t)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	key, err := secret(kid)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	idx := strings.LastIndex(t, ".")
+	if !hmac.Equal([]byte(sign(key, t[:idx])), []byte(t[idx+1:])) {
+		return nil, errors.Annotatef(ErrSignature, "kid:%s", kid)
+	}
+
+	if c.ExpiresAt != 0 && now.Unix() >= c.ExpiresAt {
+		return nil, errors.Annotatef(ErrExpired, "app:%d, jti:%s, exp:%d", c.AppID, c.ID, c.ExpiresAt)
+	}
+
+	return &c, nil
+}
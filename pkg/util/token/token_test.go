@@ -0,0 +1,78 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+func TestSignVerify(t *testing.T) {
+	keys := map[string]string{"k1": NewSecret(), "k2": NewSecret()}
+	secret := func(kid string) (string, error) {
+		if k, ok := keys[kid]; ok {
+			return k, nil
+		}
+		return "", errors.NotFoundf("kid:%s", kid)
+	}
+
+	now := time.Now()
+	c := Claims{AppID: 7, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix(), Scopes: []string{"dbs"}, ID: NewID()}
+
+	tk, err := Sign("k1", keys["k1"], c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsV2(tk) {
+		t.Fatalf("invalid token:%s", tk)
+	}
+
+	nc, err := Verify(tk, now, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nc.AppID != 7 || nc.ID != c.ID {
+		t.Fatalf("invalid claims:%+v", nc)
+	}
+
+	if _, err = Verify(tk, now.Add(time.Hour), secret); errors.Cause(err) != ErrExpired {
+		t.Fatalf("expect expired, get:%v", err)
+	}
+
+	//换成其它密钥签名
+	forged, _ := Sign("k1", keys["k2"], c)
+	if _, err = Verify(forged, now, secret); errors.Cause(err) != ErrSignature {
+		t.Fatalf("expect signature error, get:%v", err)
+	}
+
+	if _, err = Verify("v2.k1.xx", now, secret); errors.Cause(err) != ErrMalformed {
+		t.Fatalf("expect malformed, get:%v", err)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	c := Claims{Scopes: []string{"dbs", "user/info"}}
+
+	cases := []struct {
+		resource string
+		ok       bool
+	}{
+		{"/dbs/handler/Fore", true},
+		{"dbs", true},
+		{"/dbsx/query", false},
+		{"/user/info", true},
+		{"/user/list", false},
+	}
+
+	for _, cs := range cases {
+		if c.Allow(cs.resource) != cs.ok {
+			t.Fatalf("resource:%s expect:%v", cs.resource, cs.ok)
+		}
+	}
+
+	if all := (&Claims{}); !all.Allow("/any/path") {
+		t.Fatalf("empty scopes should allow all")
+	}
+}
@@ -0,0 +1,348 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dearcode.net/crab/log"
+	"github.com/juju/errors"
+)
+
+const (
+	// ExporterNone 不导出.
+	ExporterNone = "none"
+	// ExporterFile 每个span一行json写到本地文件, 开发环境用.
+	ExporterFile = "file"
+	// ExporterOTLP 通过OTLP/HTTP(json)发送到collector.
+	ExporterOTLP = "otlp"
+)
+
+const (
+	queueSize     = 4096
+	batchSize     = 256
+	flushInterval = time.Second
+	otlpTimeout   = time.Second * 5
+	otlpPath      = "/v1/traces"
+)
+
+type exporter interface {
+	export([]*Span) error
+	close() error
+}
+
+type batcher struct {
+	service string
+	exp     exporter
+	queue   chan *Span
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+var (
+	mu     sync.Mutex
+	global *batcher
+)
+
+// Init 初始化导出方式, service为上报的服务名, kind为none时不导出.
+func Init(service, kind, endpoint, file string) error {
+	var exp exporter
+
+	switch kind {
+	case "", ExporterNone:
+		return nil
+	case ExporterFile:
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return errors.Annotatef(err, "open trace file:%s", file)
+		}
+		exp = &fileExporter{f: f}
+	case ExporterOTLP:
+		exp = &otlpExporter{
+			service: service,
+			url:     strings.TrimSuffix(endpoint, "/") + otlpPath,
+			client:  &http.Client{Timeout: otlpTimeout},
+		}
+	default:
+		return errors.Errorf("unknown trace exporter:%s", kind)
+	}
+
+	b := &batcher{
+		service: service,
+		exp:     exp,
+		queue:   make(chan *Span, queueSize),
+		stop:    make(chan struct{}),
+	}
+
+	mu.Lock()
+	old := global
+	global = b
+	mu.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+
+	b.wg.Add(1)
+	go b.run()
+
+	log.Infof("trace exporter:%s, endpoint:%s, file:%s", kind, endpoint, file)
+
+	return nil
+}
+
+// Stop 导出剩余的span并关闭.
+func Stop() {
+	mu.Lock()
+	b := global
+	global = nil
+	mu.Unlock()
+
+	if b != nil {
+		b.close()
+	}
+}
+
+// export 放入队列, 队列满了直接丢弃, 不能影响请求.
+func export(s *Span) {
+	mu.Lock()
+	b := global
+	mu.Unlock()
+
+	if b == nil {
+		return
+	}
+
+	select {
+	case b.queue <- s:
+	default:
+		log.Warningf("trace queue full, drop span:%s %s", s.Context.TraceIDString(), s.Name)
+	}
+}
+
+func (b *batcher) run() {
+	defer b.wg.Done()
+
+	t := time.NewTicker(flushInterval)
+	defer t.Stop()
+
+	var ss []*Span
+
+	flush := func() {
+		if len(ss) == 0 {
+			return
+		}
+		if err := b.exp.export(ss); err != nil {
+			log.Errorf("export %d spans error:%v", len(ss), errors.ErrorStack(err))
+		}
+		ss = nil
+	}
+
+	for {
+		select {
+		case s := <-b.queue:
+			if ss = append(ss, s); len(ss) >= batchSize {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		case <-b.stop:
+			for {
+				select {
+				case s := <-b.queue:
+					ss = append(ss, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *batcher) close() {
+	close(b.stop)
+	b.wg.Wait()
+	if err := b.exp.close(); err != nil {
+		log.Errorf("close trace exporter error:%v", err)
+	}
+}
+
+type fileSpan struct {
+	TraceID  string            `json:"traceId"`
+	SpanID   string            `json:"spanId"`
+	ParentID string            `json:"parentSpanId,omitempty"`
+	Name     string            `json:"name"`
+	Kind     Kind              `json:"kind"`
+	Start    time.Time         `json:"start"`
+	CostMS   float64           `json:"costMs"`
+	Attrs    map[string]string `json:"attributes,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type fileExporter struct {
+	f *os.File
+}
+
+func (e *fileExporter) export(ss []*Span) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	for _, s := range ss {
+		fs := fileSpan{
+			TraceID: s.Context.TraceIDString(),
+			SpanID:  s.Context.SpanIDString(),
+			Name:    s.Name,
+			Kind:    s.Kind,
+			Start:   s.Start,
+			CostMS:  float64(s.End.Sub(s.Start)) / float64(time.Millisecond),
+			Attrs:   s.Attrs,
+			Error:   s.Err,
+		}
+		if s.ParentID != [8]byte{} {
+			fs.ParentID = hex.EncodeToString(s.ParentID[:])
+		}
+		if err := enc.Encode(&fs); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	_, err := e.f.Write(buf.Bytes())
+	return errors.Trace(err)
+}
+
+func (e *fileExporter) close() error {
+	return e.f.Close()
+}
+
+// 以下为OTLP/HTTP json格式, traceId及spanId用16进制, 时间用字符串形式的纳秒.
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID      string     `json:"traceId"`
+	SpanID       string     `json:"spanId"`
+	ParentSpanID string     `json:"parentSpanId,omitempty"`
+	TraceState   string     `json:"traceState,omitempty"`
+	Name         string     `json:"name"`
+	Kind         Kind       `json:"kind"`
+	Start        string     `json:"startTimeUnixNano"`
+	End          string     `json:"endTimeUnixNano"`
+	Attributes   []otlpAttr `json:"attributes,omitempty"`
+	Status       otlpStatus `json:"status"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttr `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpExporter struct {
+	service string
+	url     string
+	client  *http.Client
+}
+
+func newOTLPSpan(s *Span) otlpSpan {
+	o := otlpSpan{
+		TraceID:    s.Context.TraceIDString(),
+		SpanID:     s.Context.SpanIDString(),
+		TraceState: s.Context.State,
+		Name:       s.Name,
+		Kind:       s.Kind,
+		Start:      strconv.FormatInt(s.Start.UnixNano(), 10),
+		End:        strconv.FormatInt(s.End.UnixNano(), 10),
+	}
+
+	if s.ParentID != [8]byte{} {
+		o.ParentSpanID = hex.EncodeToString(s.ParentID[:])
+	}
+
+	for k, v := range s.Attrs {
+		o.Attributes = append(o.Attributes, otlpAttr{Key: k, Value: otlpValue{StringValue: v}})
+	}
+
+	// 1:OK, 2:ERROR.
+	o.Status.Code = 1
+	if s.Err != "" {
+		o.Status.Code = 2
+		o.Status.Message = s.Err
+	}
+
+	return o
+}
+
+func (e *otlpExporter) export(ss []*Span) error {
+	spans := make([]otlpSpan, 0, len(ss))
+	for _, s := range ss {
+		spans = append(spans, newOTLPSpan(s))
+	}
+
+	req := otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttr{{Key: "service.name", Value: otlpValue{StringValue: e.service}}},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "dearcode.net/doodle"},
+				Spans: spans,
+			}},
+		}},
+	}
+
+	buf, err := json.Marshal(&req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Trace(fmt.Errorf("collector %s invalid http status:%d", e.url, resp.StatusCode))
+	}
+
+	return nil
+}
+
+func (e *otlpExporter) close() error {
+	return nil
+}
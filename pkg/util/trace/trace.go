@@ -0,0 +1,210 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderParent W3C trace context中的traceparent.
+	HeaderParent = "traceparent"
+	// HeaderState W3C trace context中的tracestate.
+	HeaderState = "tracestate"
+)
+
+// Kind span类型, 与OTLP中定义的一致.
+type Kind int
+
+const (
+	// KindInternal 内部调用.
+	KindInternal Kind = 1
+	// KindServer 收到的请求.
+	KindServer Kind = 2
+	// KindClient 发出的请求.
+	KindClient Kind = 3
+)
+
+const (
+	flagSampled = 0x01
+)
+
+// SpanContext 跨进程传递的trace信息.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+	State   string
+}
+
+// IsValid traceID及spanID都不能为全0.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceIDString 16进制的traceID.
+func (sc SpanContext) TraceIDString() string {
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+// SpanIDString 16进制的spanID.
+func (sc SpanContext) SpanIDString() string {
+	return hex.EncodeToString(sc.SpanID[:])
+}
+
+// String 生成traceparent头.
+func (sc SpanContext) String() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceIDString(), sc.SpanIDString(), sc.Flags)
+}
+
+// Parse 解析traceparent及tracestate, 格式不对返回false.
+func Parse(parent, state string) (SpanContext, bool) {
+	var sc SpanContext
+
+	ss := strings.Split(strings.TrimSpace(parent), "-")
+	if len(ss) < 4 || len(ss[0]) != 2 || ss[0] == "ff" {
+		return sc, false
+	}
+
+	// 版本00必须是4段, 更高的版本只取前4段.
+	if ss[0] == "00" && len(ss) != 4 {
+		return sc, false
+	}
+
+	if !decodeHex(ss[1], sc.TraceID[:]) || !decodeHex(ss[2], sc.SpanID[:]) {
+		return sc, false
+	}
+
+	var flags [1]byte
+	if !decodeHex(ss[3], flags[:]) {
+		return sc, false
+	}
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return sc, false
+	}
+
+	sc.State = strings.TrimSpace(state)
+
+	return sc, true
+}
+
+// decodeHex 只接受小写的16进制, 长度必须正好.
+func decodeHex(s string, dst []byte) bool {
+	if len(s) != len(dst)*2 || strings.ToLower(s) != s {
+		return false
+	}
+
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// Extract 从请求头中取trace信息.
+func Extract(h http.Header) (SpanContext, bool) {
+	return Parse(h.Get(HeaderParent), h.Get(HeaderState))
+}
+
+// Inject 把trace信息写入请求头.
+func Inject(h http.Header, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+
+	h.Set(HeaderParent, sc.String())
+	if sc.State != "" {
+		h.Set(HeaderState, sc.State)
+	} else {
+		h.Del(HeaderState)
+	}
+}
+
+// Span 一段调用.
+type Span struct {
+	Name     string
+	Kind     Kind
+	Context  SpanContext
+	ParentID [8]byte
+	Start    time.Time
+	End      time.Time
+	Attrs    map[string]string
+	Err      string
+
+	mu       sync.Mutex
+	finished bool
+}
+
+// Start 开始一个span, parent无效时生成新的trace.
+func Start(parent SpanContext, name string, kind Kind) *Span {
+	s := &Span{
+		Name:  name,
+		Kind:  kind,
+		Start: time.Now(),
+		Attrs: make(map[string]string),
+	}
+
+	if parent.IsValid() {
+		s.Context.TraceID = parent.TraceID
+		s.Context.Flags = parent.Flags
+		s.Context.State = parent.State
+		s.ParentID = parent.SpanID
+	} else {
+		rand.Read(s.Context.TraceID[:])
+		s.Context.Flags = flagSampled
+	}
+
+	rand.Read(s.Context.SpanID[:])
+
+	return s
+}
+
+// Child 开始一个子span.
+func (s *Span) Child(name string, kind Kind) *Span {
+	return Start(s.Context, name, kind)
+}
+
+// SetAttr 添加属性.
+func (s *Span) SetAttr(key, val string) {
+	s.mu.Lock()
+	s.Attrs[key] = val
+	s.mu.Unlock()
+}
+
+// Finish 结束span并导出, err不为空时记录为失败, 重复调用只导出一次.
+func (s *Span) Finish(err error) {
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.finished = true
+	s.End = time.Now()
+	if err != nil {
+		s.Err = err.Error()
+	}
+	s.mu.Unlock()
+
+	if s.Context.Flags&flagSampled == 0 {
+		return
+	}
+
+	export(s)
+}
+
+type spanKey struct{}
+
+// NewContext 把span放到context中.
+func NewContext(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// FromContext 从context中取span, 没有返回nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
@@ -0,0 +1,63 @@
+package trace
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		parent string
+		ok     bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-ext", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-ext", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false},
+		{"", false},
+	}
+
+	for _, c := range cases {
+		if _, ok := Parse(c.parent, ""); ok != c.ok {
+			t.Fatalf("parent:%s, expect:%v", c.parent, c.ok)
+		}
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	h := http.Header{}
+	h.Set(HeaderParent, parent)
+	h.Set(HeaderState, "congo=t61rcWkgMzE")
+
+	sc, ok := Extract(h)
+	if !ok {
+		t.Fatalf("extract %s failed", parent)
+	}
+
+	if sc.String() != parent {
+		t.Fatalf("expect:%s, get:%s", parent, sc.String())
+	}
+
+	s := Start(sc, "backend", KindClient)
+	if s.Context.TraceID != sc.TraceID || s.ParentID != sc.SpanID || s.Context.SpanID == sc.SpanID {
+		t.Fatalf("invalid child span:%+v", s.Context)
+	}
+
+	out := http.Header{}
+	Inject(out, s.Context)
+
+	nsc, ok := Extract(out)
+	if !ok || nsc != s.Context {
+		t.Fatalf("inject:%v, extract:%+v", out, nsc)
+	}
+
+	if root := Start(SpanContext{}, "root", KindServer); !root.Context.IsValid() || root.ParentID != [8]byte{} {
+		t.Fatalf("invalid root span:%+v", root)
+	}
+}
@@ -43,15 +43,33 @@ const (
 
 // DoRequest 直接发送请求
 func DoRequest(req *http.Request) ([]byte, int, error) {
+	data, _, code, err := DoRequestWithHeader(req)
+	return data, code, err
+}
+
+// DoRequestWithHeader 直接发送请求, 同时返回后端的返回头.
+func DoRequestWithHeader(req *http.Request) ([]byte, http.Header, int, error) {
+	return DoRequestTimeout(req, 0, 0)
+}
+
+// DoRequestTimeout 指定连接超时及整个请求的超时, 为0时使用默认的超时时间.
+func DoRequestTimeout(req *http.Request, connect, timeout time.Duration) ([]byte, http.Header, int, error) {
+	if connect <= 0 {
+		connect = backendTimeout
+	}
+	if timeout <= 0 {
+		timeout = backendTimeout
+	}
+
 	client := http.Client{
 		Transport: &http.Transport{
 			Dial: func(netw, addr string) (net.Conn, error) {
-				c, err := net.DialTimeout(netw, addr, backendTimeout)
+				c, err := net.DialTimeout(netw, addr, connect)
 				if err != nil {
 					log.Errorf("DialTimeout %s:%s", netw, addr)
 					return nil, errors.Trace(err)
 				}
-				deadline := time.Now().Add(backendTimeout)
+				deadline := time.Now().Add(timeout)
 				if err = c.SetDeadline(deadline); err != nil {
 					log.Errorf("SetDeadline %s:%s", netw, addr)
 					return nil, errors.Trace(err)
@@ -63,15 +81,15 @@ func DoRequest(req *http.Request) ([]byte, int, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, 0, errors.Trace(err)
+		return nil, nil, 0, errors.Trace(err)
 	}
 	defer resp.Body.Close()
 
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, 0, errors.Trace(err)
+		return nil, nil, 0, errors.Trace(err)
 	}
-	return data, resp.StatusCode, nil
+	return data, resp.Header, resp.StatusCode, nil
 }
 
 // Request 调用远程http服务.
@@ -122,6 +140,15 @@ func DecodeRequestValue(req *http.Request, result interface{}) error {
 			rv.Field(i).SetInt(vi)
 		case reflect.String:
 			rv.Field(i).SetString(val)
+		case reflect.Bool:
+			if val == "" {
+				break
+			}
+			vb, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("key:%v value:%v format error", key, val)
+			}
+			rv.Field(i).SetBool(vb)
 		}
 	}
 
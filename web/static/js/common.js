@@ -157,6 +157,11 @@ function chEnWordCount(str) {
 }  
 
 //英文字母和数字
+//路径模式, 如/orders/{id}, /static/*, ~开头的是正则
+function isPathPattern(str) {
+    return /^~|[{*]/.test(str);
+}
+
 function checkPath(str) {
     if(!str.match(/^[A-Za-z0-9]{4,40}$/)) {
         return false;